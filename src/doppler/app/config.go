@@ -37,6 +37,22 @@ type GRPC struct {
 	KeyFile  string
 }
 
+type AppAccounting struct {
+	Capacity        int
+	TopN            int
+	IntervalSeconds uint
+	Port            uint32
+}
+
+type Quarantine struct {
+	Enabled       bool
+	RateMultiple  float64
+	MinRate       float64
+	SampleRate    uint64
+	WindowSeconds uint
+	ExemptAppIDs  []string
+}
+
 type Config struct {
 	BlackListIps                    []iprange.IPRange
 	ContainerMetricTTLSeconds       int
@@ -70,6 +86,13 @@ type Config struct {
 	WebsocketWriteTimeoutSeconds    int
 	Zone                            string
 	PPROFPort                       uint32
+	HealthPort                      uint32
+	PrometheusPort                  uint32
+	DrainMinimumSeconds             uint
+	DrainTimeoutSeconds             uint
+	SinkFlushTimeoutSeconds         uint
+	AppAccounting                   AppAccounting
+	Quarantine                      Quarantine
 }
 
 func (c *Config) validate() (err error) {
@@ -177,5 +200,45 @@ func Parse(confData []byte) (*Config, error) {
 		config.GRPC.Port = 8082
 	}
 
+	if config.DrainTimeoutSeconds == 0 {
+		config.DrainTimeoutSeconds = 20
+	}
+
+	if config.DrainMinimumSeconds > config.DrainTimeoutSeconds {
+		config.DrainTimeoutSeconds = config.DrainMinimumSeconds
+	}
+
+	if config.SinkFlushTimeoutSeconds == 0 {
+		config.SinkFlushTimeoutSeconds = 5
+	}
+
+	if config.AppAccounting.Capacity == 0 {
+		config.AppAccounting.Capacity = 1000
+	}
+
+	if config.AppAccounting.TopN == 0 {
+		config.AppAccounting.TopN = 10
+	}
+
+	if config.AppAccounting.IntervalSeconds == 0 {
+		config.AppAccounting.IntervalSeconds = 60
+	}
+
+	if config.Quarantine.RateMultiple == 0 {
+		config.Quarantine.RateMultiple = 10
+	}
+
+	if config.Quarantine.MinRate == 0 {
+		config.Quarantine.MinRate = 100
+	}
+
+	if config.Quarantine.SampleRate == 0 {
+		config.Quarantine.SampleRate = 10
+	}
+
+	if config.Quarantine.WindowSeconds == 0 {
+		config.Quarantine.WindowSeconds = 10
+	}
+
 	return config, nil
 }
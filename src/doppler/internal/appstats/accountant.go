@@ -0,0 +1,297 @@
+// Package appstats tracks how many envelopes and bytes each app sends into
+// and receives out of Doppler.
+package appstats
+
+import (
+	"encoding/json"
+	"metric"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	Ingress = "ingress"
+	Egress  = "egress"
+)
+
+// numShards is the number of independently locked shards apps are spread
+// across so that recording envelopes for different apps does not contend.
+const numShards = 16
+
+// maxTaggedApps is the number of apps whose ID is used as the value of the
+// app_id metric tag. Counts of any other app are tagged otherApps so that
+// the number of series is bounded.
+const (
+	maxTaggedApps = 100
+	otherApps     = "other"
+)
+
+// Accountant counts envelopes and bytes per app over a window. Apps are
+// spread across shards by their ID. Memory is bounded by tracking only the
+// heaviest apps of each shard.
+type Accountant struct {
+	capacity int
+	topN     int
+	interval time.Duration
+	shards   [numShards]shard
+
+	mu     sync.Mutex
+	last   [numShards]*windowStats
+	tagged map[string]struct{}
+}
+
+type shard struct {
+	mu     sync.Mutex
+	window *windowStats
+}
+
+type windowStats struct {
+	envelopes map[string]*SpaceSaving
+	bytes     map[string]*SpaceSaving
+}
+
+func newWindowStats(capacity int) *windowStats {
+	return &windowStats{
+		envelopes: map[string]*SpaceSaving{
+			Ingress: NewSpaceSaving(capacity),
+			Egress:  NewSpaceSaving(capacity),
+		},
+		bytes: map[string]*SpaceSaving{
+			Ingress: NewSpaceSaving(capacity),
+			Egress:  NewSpaceSaving(capacity),
+		},
+	}
+}
+
+// NewAccountant returns an Accountant that tracks up to capacity apps per
+// direction in each shard and emits the topN apps at the end of each
+// interval.
+func NewAccountant(capacity, topN int, interval time.Duration) *Accountant {
+	a := &Accountant{
+		capacity: capacity,
+		topN:     topN,
+		interval: interval,
+		tagged:   make(map[string]struct{}),
+	}
+	for i := range a.shards {
+		a.shards[i].window = newWindowStats(capacity)
+		a.last[i] = newWindowStats(capacity)
+	}
+	return a
+}
+
+// SendTo records an envelope received for an app. It lets the Accountant be
+// registered with the message router alongside the other senders.
+func (a *Accountant) SendTo(appID string, e *events.Envelope) {
+	a.record(Ingress, appID, 1, e.Size())
+}
+
+// RecordEgress records envelopes sent to consumers of an app.
+func (a *Accountant) RecordEgress(appID string, envelopes, bytes int) {
+	a.record(Egress, appID, envelopes, bytes)
+}
+
+func (a *Accountant) record(direction, appID string, envelopes, bytes int) {
+	s := &a.shards[shardIndex(appID)]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.window.envelopes[direction].Add(appID, uint64(envelopes))
+	s.window.bytes[direction].Add(appID, uint64(bytes))
+}
+
+// shardIndex hashes an app ID with FNV-1a.
+func shardIndex(appID string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(appID); i++ {
+		h ^= uint32(appID[i])
+		h *= 16777619
+	}
+	return int(h % numShards)
+}
+
+// Start completes a window every interval. It blocks forever.
+func (a *Accountant) Start() {
+	for range time.Tick(a.interval) {
+		a.Rotate()
+	}
+}
+
+// Rotate completes the current window and emits the envelope and byte counts
+// of its heaviest apps as counters. Only the first maxTaggedApps apps to be
+// among the heaviest get their own app_id tag value. The counts of later
+// apps are summed under the app_id other.
+func (a *Accountant) Rotate() {
+	var last [numShards]*windowStats
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mu.Lock()
+		last[i] = s.window
+		s.window = newWindowStats(a.capacity)
+		s.mu.Unlock()
+	}
+
+	a.mu.Lock()
+	a.last = last
+	a.mu.Unlock()
+
+	for _, direction := range []string{Ingress, Egress} {
+		for appID, value := range a.byTag(top(last, direction, true, a.topN)) {
+			// metric-documentation-v2: (loggregator.doppler.app_envelopes) Number
+			// of envelopes for each of the heaviest apps of a window, tagged by
+			// direction. Egress counts envelopes written to gRPC subscriptions,
+			// firehose and app websockets and syslog drains. At most 100 apps
+			// are tagged by their ID, the counts of other apps are tagged with
+			// the app_id other.
+			metric.IncCounter("app_envelopes",
+				metric.WithIncrement(value),
+				metric.WithVersion(2, 0),
+				metric.WithTag("app_id", appID),
+				metric.WithTag("direction", direction),
+			)
+		}
+
+		for appID, value := range a.byTag(top(last, direction, false, a.topN)) {
+			// metric-documentation-v2: (loggregator.doppler.app_bytes) Number of
+			// bytes for each of the heaviest apps of a window, tagged by
+			// direction. At most 100 apps are tagged by their ID, the counts of
+			// other apps are tagged with the app_id other.
+			metric.IncCounter("app_bytes",
+				metric.WithIncrement(value),
+				metric.WithVersion(2, 0),
+				metric.WithTag("app_id", appID),
+				metric.WithTag("direction", direction),
+			)
+		}
+	}
+}
+
+// byTag sums the counts by the value of their app_id tag.
+func (a *Accountant) byTag(counts []Count) map[string]uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	values := make(map[string]uint64, len(counts))
+	for _, c := range counts {
+		values[a.metricTag(c.Key)] += c.Value
+	}
+	return values
+}
+
+// metricTag returns the value of the app_id tag for an app. It must be
+// called with a.mu held.
+func (a *Accountant) metricTag(appID string) string {
+	if _, ok := a.tagged[appID]; ok {
+		return appID
+	}
+	if len(a.tagged) >= maxTaggedApps {
+		return otherApps
+	}
+	a.tagged[appID] = struct{}{}
+	return appID
+}
+
+// top returns the n heaviest apps across shards by envelopes or bytes. As
+// every app is tracked by a single shard the heaviest apps overall are
+// among the heaviest apps of each shard.
+func top(stats [numShards]*windowStats, direction string, envelopes bool, n int) []Count {
+	var counts []Count
+	for _, w := range stats {
+		ss := w.bytes[direction]
+		if envelopes {
+			ss = w.envelopes[direction]
+		}
+		counts = append(counts, ss.Top(n)...)
+	}
+
+	sort.Sort(byValue(counts))
+	if n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// AppUsage is the usage of an app during the last completed window. Counts
+// are estimates that may be overstated by up to their error.
+type AppUsage struct {
+	AppID          string `json:"app_id"`
+	Envelopes      uint64 `json:"envelopes"`
+	EnvelopesError uint64 `json:"envelopes_error"`
+	Bytes          uint64 `json:"bytes"`
+	BytesError     uint64 `json:"bytes_error"`
+}
+
+// Top returns the n apps with the most envelopes in the given direction
+// during the last completed window.
+func (a *Accountant) Top(direction string, n int) []AppUsage {
+	if direction != Ingress && direction != Egress {
+		return nil
+	}
+
+	a.mu.Lock()
+	last := a.last
+	a.mu.Unlock()
+
+	usage := []AppUsage{}
+	for _, c := range top(last, direction, true, n) {
+		u := AppUsage{
+			AppID:          c.Key,
+			Envelopes:      c.Value,
+			EnvelopesError: c.Error,
+		}
+		if b, ok := last[shardIndex(c.Key)].bytes[direction].Get(c.Key); ok {
+			u.Bytes = b.Value
+			u.BytesError = b.Error
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+type topResponse struct {
+	Direction       string     `json:"direction"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+	Apps            []AppUsage `json:"apps"`
+}
+
+// ServeHTTP serves the heaviest apps of the last completed window as JSON.
+// The direction query parameter selects ingress (the default) or egress and
+// n limits the number of apps (10 by default).
+func (a *Accountant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = Ingress
+	}
+	if direction != Ingress && direction != Egress {
+		http.Error(w, "direction must be ingress or egress", http.StatusBadRequest)
+		return
+	}
+
+	n := 10
+	if param := r.URL.Query().Get("n"); param != "" {
+		var err error
+		n, err = strconv.Atoi(param)
+		if err != nil || n < 1 {
+			http.Error(w, "n must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body, err := json.Marshal(topResponse{
+		Direction:       direction,
+		IntervalSeconds: a.interval.Seconds(),
+		Apps:            a.Top(direction, n),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
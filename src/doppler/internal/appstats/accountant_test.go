@@ -0,0 +1,152 @@
+package appstats_test
+
+import (
+	"doppler/internal/appstats"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Accountant", func() {
+	var (
+		accountant *appstats.Accountant
+		envelope   *events.Envelope
+	)
+
+	BeforeEach(func() {
+		accountant = appstats.NewAccountant(10, 5, time.Minute)
+		envelope = &events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_LogMessage.Enum(),
+			LogMessage: &events.LogMessage{
+				Message:     []byte("some-message"),
+				MessageType: events.LogMessage_OUT.Enum(),
+				Timestamp:   proto.Int64(1),
+				AppId:       proto.String("some-app-id"),
+			},
+		}
+	})
+
+	It("reports nothing until a window completes", func() {
+		accountant.SendTo("some-app-id", envelope)
+
+		Expect(accountant.Top(appstats.Ingress, 10)).To(BeEmpty())
+	})
+
+	It("reports ingress per app for the last window", func() {
+		accountant.SendTo("some-app-id", envelope)
+		accountant.SendTo("some-app-id", envelope)
+		accountant.SendTo("other-app-id", envelope)
+		accountant.Rotate()
+
+		Expect(accountant.Top(appstats.Ingress, 10)).To(Equal([]appstats.AppUsage{
+			{AppID: "some-app-id", Envelopes: 2, Bytes: uint64(2 * envelope.Size())},
+			{AppID: "other-app-id", Envelopes: 1, Bytes: uint64(envelope.Size())},
+		}))
+		Expect(accountant.Top(appstats.Egress, 10)).To(BeEmpty())
+	})
+
+	It("reports egress per app for the last window", func() {
+		accountant.RecordEgress("some-app-id", 3, 300)
+		accountant.Rotate()
+
+		Expect(accountant.Top(appstats.Egress, 10)).To(Equal([]appstats.AppUsage{
+			{AppID: "some-app-id", Envelopes: 3, Bytes: 300},
+		}))
+	})
+
+	It("starts a new window on rotate", func() {
+		accountant.RecordEgress("some-app-id", 3, 300)
+		accountant.Rotate()
+		accountant.Rotate()
+
+		Expect(accountant.Top(appstats.Egress, 10)).To(BeEmpty())
+	})
+
+	It("reports the heaviest apps across all apps", func() {
+		for i := 1; i <= 20; i++ {
+			accountant.RecordEgress(fmt.Sprintf("app-%02d", i), i, i*100)
+		}
+		accountant.Rotate()
+
+		Expect(accountant.Top(appstats.Egress, 3)).To(Equal([]appstats.AppUsage{
+			{AppID: "app-20", Envelopes: 20, Bytes: 2000},
+			{AppID: "app-19", Envelopes: 19, Bytes: 1900},
+			{AppID: "app-18", Envelopes: 18, Bytes: 1800},
+		}))
+	})
+
+	It("bounds the number of app_id tag values it emits", func() {
+		for i := 0; i < 150; i++ {
+			accountant.RecordEgress(fmt.Sprintf("bounded-app-%03d", i), 1, 100)
+			accountant.Rotate()
+		}
+
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		registry.ServeHTTP(recorder, req)
+
+		var series int
+		for _, line := range strings.Split(recorder.Body.String(), "\n") {
+			if strings.HasPrefix(line, "app_envelopes{") && strings.Contains(line, "bounded-app-") {
+				series++
+			}
+		}
+		Expect(series).To(Equal(100))
+		Expect(recorder.Body.String()).To(ContainSubstring(`app_envelopes{app_id="other",direction="egress"`))
+	})
+
+	Describe("ServeHTTP", func() {
+		BeforeEach(func() {
+			accountant.RecordEgress("some-app-id", 3, 300)
+			accountant.RecordEgress("other-app-id", 1, 100)
+			accountant.Rotate()
+		})
+
+		It("serves the top apps as JSON", func() {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/apps/top?direction=egress&n=1", nil)
+			accountant.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var body struct {
+				Direction       string              `json:"direction"`
+				IntervalSeconds float64             `json:"interval_seconds"`
+				Apps            []appstats.AppUsage `json:"apps"`
+			}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Direction).To(Equal("egress"))
+			Expect(body.IntervalSeconds).To(Equal(60.0))
+			Expect(body.Apps).To(Equal([]appstats.AppUsage{
+				{AppID: "some-app-id", Envelopes: 3, Bytes: 300},
+			}))
+		})
+
+		It("rejects an unknown direction", func() {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/apps/top?direction=sideways", nil)
+			accountant.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects an invalid n", func() {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/apps/top?n=-1", nil)
+			accountant.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,26 @@
+package appstats_test
+
+import (
+	"metric"
+	"prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+var registry *prometheus.Registry
+
+func TestAppstats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Appstats Suite")
+}
+
+var _ = BeforeSuite(func() {
+	registry = prometheus.NewRegistry("")
+	metric.Setup(
+		metric.WithAddr("127.0.0.1:0"),
+		metric.WithPrometheus(registry),
+	)
+})
@@ -0,0 +1,134 @@
+package appstats
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Count is the estimated count for a key. The true count is between
+// Value-Error and Value.
+type Count struct {
+	Key   string
+	Value uint64
+	Error uint64
+}
+
+// SpaceSaving estimates the heaviest keys of a stream using a fixed number
+// of counters. When every counter is in use, the key with the smallest count
+// is replaced and its count is carried over as the error of the new key. It
+// is not safe for concurrent use.
+type SpaceSaving struct {
+	capacity int
+	entries  map[string]*entry
+	heap     entryHeap
+}
+
+// NewSpaceSaving returns a SpaceSaving that tracks at most capacity keys.
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &SpaceSaving{
+		capacity: capacity,
+		entries:  make(map[string]*entry),
+	}
+}
+
+// Add adds weight to the count of key.
+func (s *SpaceSaving) Add(key string, weight uint64) {
+	if e, ok := s.entries[key]; ok {
+		e.count += weight
+		heap.Fix(&s.heap, e.index)
+		return
+	}
+
+	if len(s.entries) < s.capacity {
+		e := &entry{key: key, count: weight}
+		heap.Push(&s.heap, e)
+		s.entries[key] = e
+		return
+	}
+
+	min := s.heap[0]
+	delete(s.entries, min.key)
+	min.key = key
+	min.err = min.count
+	min.count += weight
+	s.entries[key] = min
+	heap.Fix(&s.heap, 0)
+}
+
+// Get returns the estimated count of key and whether it is tracked.
+func (s *SpaceSaving) Get(key string) (Count, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return Count{}, false
+	}
+	return e.toCount(), true
+}
+
+// Top returns the n keys with the highest counts, highest first.
+func (s *SpaceSaving) Top(n int) []Count {
+	counts := make([]Count, 0, len(s.entries))
+	for _, e := range s.entries {
+		counts = append(counts, e.toCount())
+	}
+
+	sort.Sort(byValue(counts))
+	if n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+type entry struct {
+	key   string
+	count uint64
+	err   uint64
+	index int
+}
+
+func (e *entry) toCount() Count {
+	return Count{
+		Key:   e.key,
+		Value: e.count,
+		Error: e.err,
+	}
+}
+
+type entryHeap []*entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+type byValue []Count
+
+func (c byValue) Len() int      { return len(c) }
+func (c byValue) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+
+func (c byValue) Less(i, j int) bool {
+	if c[i].Value != c[j].Value {
+		return c[i].Value > c[j].Value
+	}
+	return c[i].Key < c[j].Key
+}
@@ -0,0 +1,61 @@
+package appstats_test
+
+import (
+	"doppler/internal/appstats"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SpaceSaving", func() {
+	It("counts keys exactly while under capacity", func() {
+		s := appstats.NewSpaceSaving(3)
+		s.Add("a", 5)
+		s.Add("b", 2)
+		s.Add("a", 1)
+
+		Expect(s.Top(10)).To(Equal([]appstats.Count{
+			{Key: "a", Value: 6},
+			{Key: "b", Value: 2},
+		}))
+	})
+
+	It("returns at most n keys", func() {
+		s := appstats.NewSpaceSaving(3)
+		s.Add("a", 3)
+		s.Add("b", 2)
+		s.Add("c", 1)
+
+		Expect(s.Top(2)).To(Equal([]appstats.Count{
+			{Key: "a", Value: 3},
+			{Key: "b", Value: 2},
+		}))
+	})
+
+	It("replaces the smallest key when full", func() {
+		s := appstats.NewSpaceSaving(2)
+		s.Add("a", 10)
+		s.Add("b", 2)
+		s.Add("c", 1)
+
+		_, ok := s.Get("b")
+		Expect(ok).To(BeFalse())
+
+		c, ok := s.Get("c")
+		Expect(ok).To(BeTrue())
+		Expect(c).To(Equal(appstats.Count{Key: "c", Value: 3, Error: 2}))
+	})
+
+	It("keeps heavy keys when many light keys are added", func() {
+		s := appstats.NewSpaceSaving(5)
+		for i := 0; i < 1000; i++ {
+			s.Add("heavy", 10)
+			s.Add(string(rune('a'+i%26)), 1)
+		}
+
+		top := s.Top(1)
+		Expect(top).To(HaveLen(1))
+		Expect(top[0].Key).To(Equal("heavy"))
+		Expect(top[0].Value - top[0].Error).To(BeNumerically(">=", 10000))
+	})
+})
@@ -2,7 +2,9 @@ package firehose_group
 
 import (
 	"doppler/internal/groupedsinks/sink_wrapper"
+	"doppler/internal/sampling"
 	"doppler/internal/sinks"
+	"metric"
 	"sync"
 
 	"github.com/cloudfoundry/sonde-go/events"
@@ -14,12 +16,14 @@ type FirehoseGroup interface {
 	RemoveSink(fsink sinks.Sink) bool
 	RemoveAllSinks()
 	IsEmpty() bool
-	BroadcastMessage(msg *events.Envelope)
+	BroadcastMessage(msg *events.Envelope) bool
+	SetSampleRate(rate uint32)
 }
 
 type firehoseGroup struct {
 	sinkWrappers      []*sink_wrapper.SinkWrapper
 	lastUsedSinkIndex int
+	sampleRate        uint32
 	sync.RWMutex
 }
 
@@ -80,7 +84,18 @@ func (group *firehoseGroup) IsEmpty() bool {
 	return group.length() == 0
 }
 
-func (group *firehoseGroup) BroadcastMessage(msg *events.Envelope) {
+// SetSampleRate makes the group deliver only 1 in rate envelopes. Every
+// envelope is delivered when rate is 0 or 1.
+func (group *firehoseGroup) SetSampleRate(rate uint32) {
+	group.Lock()
+	defer group.Unlock()
+
+	group.sampleRate = rate
+}
+
+// BroadcastMessage writes the message to one of the group's sinks. It
+// returns false if the message was sampled out.
+func (group *firehoseGroup) BroadcastMessage(msg *events.Envelope) bool {
 	group.Lock()
 	defer group.Unlock()
 
@@ -90,9 +105,21 @@ func (group *firehoseGroup) BroadcastMessage(msg *events.Envelope) {
 		group.lastUsedSinkIndex = 0
 	}
 
-	group.sinkWrappers[group.lastUsedSinkIndex].InputChan <- msg
+	sinkWrapper := group.sinkWrappers[group.lastUsedSinkIndex]
+	if !sampling.Keep(msg, group.sampleRate) {
+		// metric-documentation-v2: (loggregator.doppler.sampled_out) Number
+		// of envelopes not sent to a sampled firehose subscription
+		metric.IncCounter("sampled_out",
+			metric.WithVersion(2, 0),
+			metric.WithTag("subscription_id", sinkWrapper.Sink.AppID()),
+		)
+		return false
+	}
+
+	sinkWrapper.InputChan <- msg
 
 	group.lastUsedSinkIndex += 1
+	return true
 }
 
 func (group *firehoseGroup) length() int {
@@ -1,11 +1,13 @@
 package firehose_group_test
 
 import (
+	"doppler/internal/sampling"
 	"doppler/internal/sinks"
 
 	"github.com/cloudfoundry/dropsonde/emitter"
 	"github.com/cloudfoundry/dropsonde/factories"
 	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
 
 	"doppler/internal/groupedsinks/firehose_group"
 
@@ -68,6 +70,31 @@ var _ = Describe("FirehoseGroup", func() {
 		Expect(nextChannelToReceive).To(Receive(&msg))
 	})
 
+	It("sends only sampled messages when a sample rate is set", func() {
+		receiveChan := make(chan *events.Envelope, 100)
+		sink := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
+
+		group := firehose_group.NewFirehoseGroup()
+		group.AddSink(&sink, receiveChan)
+		group.SetSampleRate(10)
+
+		var kept int
+		for i := 0; i < 100; i++ {
+			msg := &events.Envelope{
+				Origin:    proto.String("origin"),
+				EventType: events.Envelope_CounterEvent.Enum(),
+				Timestamp: proto.Int64(int64(i)),
+			}
+			if sampling.Keep(msg, 10) {
+				kept++
+			}
+			group.BroadcastMessage(msg)
+		}
+
+		Expect(kept).To(BeNumerically("<", 100))
+		Expect(receiveChan).To(HaveLen(kept))
+	})
+
 	It("does not send messages to unregistered sinks", func() {
 		receiveChan1 := make(chan *events.Envelope, 10)
 		receiveChan2 := make(chan *events.Envelope, 10)
@@ -64,6 +64,12 @@ func (group *GroupedSinks) RegisterFirehoseSink(in chan<- *events.Envelope, sink
 		fgroup = group.firehoses[subscriptionId]
 	}
 
+	// Every sink of a subscription is expected to ask for the same sample
+	// rate. The most recent one wins if they do not.
+	if s, ok := sink.(*websocket.WebsocketSink); ok {
+		fgroup.SetSampleRate(s.SampleRate())
+	}
+
 	return fgroup.AddSink(sink, in)
 }
 
@@ -83,19 +89,29 @@ func (group *GroupedSinks) IsFirehoseRegistered(sink sinks.Sink) bool {
 	return fgroup.Exists(sink)
 }
 
-func (group *GroupedSinks) Broadcast(appId string, msg *events.Envelope) {
+// Broadcast writes the message to the sinks of the app and to every
+// firehose subscription. It returns the number of sinks written to that
+// deliver the message to a consumer, which excludes the recent logs and
+// container metrics caches.
+func (group *GroupedSinks) Broadcast(appId string, msg *events.Envelope) int {
 	group.RLock()
 	defer group.RUnlock()
 
+	var writes int
 	for _, wrapper := range group.apps[appId] {
 		select {
 		case wrapper.InputChan <- msg:
+			switch wrapper.Sink.(type) {
+			case *dump.DumpSink, *containermetric.ContainerMetricSink:
+			default:
+				writes++
+			}
 		default:
 			log.Printf("unable to write to app sink: %s", appId)
 		}
 	}
 
-	group.BroadcastMessageToFirehoses(msg)
+	return writes + group.BroadcastMessageToFirehoses(msg)
 }
 
 func (group *GroupedSinks) BroadcastError(appId string, errorMsg *events.Envelope) {
@@ -115,10 +131,16 @@ func (group *GroupedSinks) BroadcastError(appId string, errorMsg *events.Envelop
 	group.BroadcastMessageToFirehoses(errorMsg)
 }
 
-func (group *GroupedSinks) BroadcastMessageToFirehoses(msg *events.Envelope) {
+// BroadcastMessageToFirehoses writes the message to every firehose
+// subscription and returns the number of subscriptions written to.
+func (group *GroupedSinks) BroadcastMessageToFirehoses(msg *events.Envelope) int {
+	var writes int
 	for _, fgroup := range group.firehoses {
-		fgroup.BroadcastMessage(msg)
+		if fgroup.BroadcastMessage(msg) {
+			writes++
+		}
 	}
+	return writes
 }
 
 func (group *GroupedSinks) CountFor(appId string) int {
@@ -140,6 +140,19 @@ var _ = Describe("GroupedSink", func() {
 
 			Eventually(c).Should(BeClosed())
 		})
+
+		It("returns the number of sinks that deliver the message to a consumer", func() {
+			appId := "123"
+			syslogSink := syslog.NewSyslogSink(appId, &url.URL{Host: "url"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
+			groupedSinks.RegisterAppSink(make(chan *events.Envelope, 1), syslogSink)
+			dumpSink := dump.NewDumpSink(appId, 10, time.Second)
+			groupedSinks.RegisterAppSink(make(chan *events.Envelope, 1), dumpSink)
+			firehoseSink := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
+			groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope, 1), firehoseSink)
+
+			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+			Expect(groupedSinks.Broadcast(appId, msg)).To(Equal(2))
+		})
 	})
 
 	Describe("BroadcastError", func() {
@@ -4,6 +4,7 @@ import (
 	"diodes"
 	"errors"
 	"log"
+	"metric"
 	"plumbing"
 	"sync/atomic"
 	"time"
@@ -91,9 +92,15 @@ func (m *DopplerServer) RecentLogs(ctx context.Context, req *plumbing.RecentLogs
 
 func (m *DopplerServer) emitMetrics() {
 	for range time.Tick(metricsInterval) {
-		// metrics:v1 (grpcManager.subscriptions) Number of v1 egress gRPC
-		// subscriptions
+		// metric-documentation-v1: (grpcManager.subscriptions) DEPRECATED Number
+		// of v1 egress gRPC subscriptions. Replaced by
+		// loggregator.doppler.subscriptions.
 		metrics.SendValue("grpcManager.subscriptions", float64(atomic.LoadInt64(&m.numSubscriptions)), "subscriptions")
+		// metric-documentation-v2: (loggregator.doppler.subscriptions) Number of
+		// v1 egress gRPC subscriptions
+		metric.SetGauge("subscriptions", float64(atomic.LoadInt64(&m.numSubscriptions)), "subscriptions",
+			metric.WithVersion(2, 0),
+		)
 	}
 }
 
@@ -366,3 +366,27 @@ func (m *mockBatchCounterChainer) Add(value uint64) {
 	m.AddCalled <- true
 	m.AddInput.Value <- value
 }
+
+type mockEgressRecorder struct {
+	RecordEgressCalled chan bool
+	RecordEgressInput  struct {
+		AppID     chan string
+		Envelopes chan int
+		Bytes     chan int
+	}
+}
+
+func newMockEgressRecorder() *mockEgressRecorder {
+	m := &mockEgressRecorder{}
+	m.RecordEgressCalled = make(chan bool, 100)
+	m.RecordEgressInput.AppID = make(chan string, 100)
+	m.RecordEgressInput.Envelopes = make(chan int, 100)
+	m.RecordEgressInput.Bytes = make(chan int, 100)
+	return m
+}
+func (m *mockEgressRecorder) RecordEgress(appID string, envelopes, bytes int) {
+	m.RecordEgressCalled <- true
+	m.RecordEgressInput.AppID <- appID
+	m.RecordEgressInput.Envelopes <- envelopes
+	m.RecordEgressInput.Bytes <- bytes
+}
@@ -1,7 +1,9 @@
 package v1
 
 import (
+	"doppler/internal/sampling"
 	"math/rand"
+	"metric"
 	"plumbing"
 	"sync"
 
@@ -10,9 +12,27 @@ import (
 
 type shardID string
 
+// EgressRecorder records envelopes written to the subscriptions for an app.
+type EgressRecorder interface {
+	RecordEgress(appID string, envelopes, bytes int)
+}
+
 type Router struct {
 	lock          sync.RWMutex
 	subscriptions map[filter]map[shardID][]DataSetter
+	sampleRates   map[shardID]uint32
+	egress        EgressRecorder
+}
+
+// RouterOption configures a Router.
+type RouterOption func(*Router)
+
+// WithEgressRecorder records every envelope written to a subscription with
+// the given recorder.
+func WithEgressRecorder(e EgressRecorder) RouterOption {
+	return func(r *Router) {
+		r.egress = e
+	}
 }
 
 type filterType uint8
@@ -28,10 +48,15 @@ type filter struct {
 	envelopeType filterType
 }
 
-func NewRouter() *Router {
-	return &Router{
+func NewRouter(opts ...RouterOption) *Router {
+	r := &Router{
 		subscriptions: make(map[filter]map[shardID][]DataSetter),
+		sampleRates:   make(map[shardID]uint32),
+	}
+	for _, o := range opts {
+		o(r)
 	}
+	return r
 }
 
 func (r *Router) Register(req *plumbing.SubscriptionRequest, dataSetter DataSetter) (cleanup func()) {
@@ -44,13 +69,26 @@ func (r *Router) Register(req *plumbing.SubscriptionRequest, dataSetter DataSett
 }
 
 func (r *Router) SendTo(appID string, envelope *events.Envelope) {
+	writes, size := r.send(appID, envelope)
+
+	// Egress is recorded without holding the lock so that a slow recorder
+	// does not block subscriptions from registering.
+	if r.egress != nil && writes > 0 {
+		r.egress.RecordEgress(appID, writes, writes*size)
+	}
+}
+
+// send writes the envelope to every matching subscription and returns the
+// number of subscriptions written to and the size of the marshalled
+// envelope.
+func (r *Router) send(appID string, envelope *events.Envelope) (int, int) {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
 	data := r.marshal(envelope)
 
 	if data == nil {
-		return
+		return 0, 0
 	}
 
 	nonTypedFilter := filter{
@@ -58,30 +96,45 @@ func (r *Router) SendTo(appID string, envelope *events.Envelope) {
 		envelopeType: noType,
 	}
 
+	var writes int
 	for id, setters := range r.subscriptions[nonTypedFilter] {
-		r.writeToShard(id, setters, data)
+		writes += r.writeToShard(id, setters, data)
 	}
 
 	typedFilter := r.createTypedFilter(appID, envelope)
 	for id, setters := range r.subscriptions[typedFilter] {
-		r.writeToShard(id, setters, data)
+		writes += r.writeToShard(id, setters, data)
 	}
 
 	var noFilter filter
 	for id, setters := range r.subscriptions[noFilter] {
-		r.writeToShard(id, setters, data)
+		if !sampling.Keep(envelope, r.sampleRates[id]) {
+			// metric-documentation-v2: (loggregator.doppler.sampled_out) Number
+			// of envelopes not sent to a sampled firehose subscription
+			metric.IncCounter("sampled_out",
+				metric.WithVersion(2, 0),
+				metric.WithTag("subscription_id", string(id)),
+			)
+			continue
+		}
+		writes += r.writeToShard(id, setters, data)
 	}
+
+	return writes, len(data)
 }
 
-func (r *Router) writeToShard(id shardID, setters []DataSetter, data []byte) {
+// writeToShard writes data to the setters of a shard and returns the number
+// of setters written to.
+func (r *Router) writeToShard(id shardID, setters []DataSetter, data []byte) int {
 	if id == "" {
 		for _, setter := range setters {
 			setter.Set(data)
 		}
-		return
+		return len(setters)
 	}
 
 	setters[rand.Intn(len(setters))].Set(data)
+	return 1
 }
 
 func (r *Router) createTypedFilter(appID string, envelope *events.Envelope) filter {
@@ -107,6 +160,12 @@ func (r *Router) registerSetter(req *plumbing.SubscriptionRequest, dataSetter Da
 	}
 
 	m[shardID(req.ShardID)] = append(m[shardID(req.ShardID)], dataSetter)
+
+	// Every firehose subscription of a shard is expected to ask for the same
+	// sample rate. The most recent one wins if they do not.
+	if req.GetFilter() == nil {
+		r.sampleRates[shardID(req.ShardID)] = req.SampleRate
+	}
 }
 
 func (r *Router) buildCleanup(req *plumbing.SubscriptionRequest, dataSetter DataSetter) func() {
@@ -128,6 +187,9 @@ func (r *Router) buildCleanup(req *plumbing.SubscriptionRequest, dataSetter Data
 		}
 
 		delete(r.subscriptions[f], shardID(req.ShardID))
+		if req.GetFilter() == nil {
+			delete(r.sampleRates, shardID(req.ShardID))
+		}
 
 		if len(r.subscriptions[f]) == 0 {
 			delete(r.subscriptions, f)
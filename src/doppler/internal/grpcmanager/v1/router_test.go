@@ -2,6 +2,7 @@ package v1_test
 
 import (
 	"doppler/internal/grpcmanager/v1"
+	"doppler/internal/sampling"
 	"plumbing"
 
 	. "github.com/apoydence/eachers"
@@ -31,6 +32,8 @@ var _ = Describe("Router", func() {
 		counterEnvelopeBytes []byte
 		logEnvelopeBytes     []byte
 
+		mockEgressRecorder *mockEgressRecorder
+
 		router *v1.Router
 	)
 
@@ -59,7 +62,8 @@ var _ = Describe("Router", func() {
 		logEnvelopeBytes, err = logEnvelope.Marshal()
 		Expect(err).ToNot(HaveOccurred())
 
-		router = v1.NewRouter()
+		mockEgressRecorder = newMockEgressRecorder()
+		router = v1.NewRouter(v1.WithEgressRecorder(mockEgressRecorder))
 	})
 
 	Describe("data routing", func() {
@@ -169,6 +173,26 @@ var _ = Describe("Router", func() {
 				)
 			})
 
+			It("records the envelopes written for the app", func() {
+				router.SendTo("some-app-id", logEnvelope)
+
+				Expect(mockEgressRecorder.RecordEgressInput).To(
+					BeCalled(With("some-app-id", 5, 5*len(logEnvelopeBytes))),
+				)
+			})
+
+			It("does not record egress when there are no subscriptions", func() {
+				cleanupD()
+				cleanupE()
+				cleanupF()
+
+				router.SendTo("unknown-app-id", logEnvelope)
+
+				Expect(mockEgressRecorder.RecordEgressCalled).To(
+					Not(BeCalled()),
+				)
+			})
+
 			It("does not send data for bad envelope", func() {
 				router.SendTo("some-app-id", new(events.Envelope))
 
@@ -235,6 +259,38 @@ var _ = Describe("Router", func() {
 				})
 			})
 
+			Context("when a firehose subscription is sampled", func() {
+				var mockSampledSetter *mockDataSetter
+
+				BeforeEach(func() {
+					mockSampledSetter = newMockDataSetter()
+					router.Register(&plumbing.SubscriptionRequest{
+						ShardID:    "some-sampled-sub-id",
+						SampleRate: 10,
+					}, mockSampledSetter)
+				})
+
+				It("sends only the sampled envelopes", func() {
+					var kept int
+					for i := 0; i < 50; i++ {
+						envelope := &events.Envelope{
+							Origin:    proto.String("some-origin"),
+							EventType: events.Envelope_CounterEvent.Enum(),
+							Timestamp: proto.Int64(int64(i)),
+						}
+						if sampling.Keep(envelope, 10) {
+							kept++
+						}
+						router.SendTo("some-app-id", envelope)
+					}
+
+					Expect(kept).To(BeNumerically(">", 0))
+					Expect(kept).To(BeNumerically("<", 50))
+					Expect(mockSampledSetter.SetCalled).To(HaveLen(kept))
+					Expect(mockDataSetterF.SetCalled).To(HaveLen(50))
+				})
+			})
+
 			Describe("thread safety", func() {
 				It("survives the race detector", func(done Done) {
 					cleanup := router.Register(reqA, mockDataSetterA)
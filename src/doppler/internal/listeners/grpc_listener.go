@@ -6,11 +6,13 @@ import (
 	"doppler/internal/grpcmanager/v1"
 	"doppler/internal/grpcmanager/v2"
 	"doppler/internal/sinkserver/sinkmanager"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	plumbingv1 "plumbing"
 	plumbingv2 "plumbing/v2"
+	"sync/atomic"
 
 	"github.com/cloudfoundry/dropsonde/metricbatcher"
 
@@ -19,8 +21,10 @@ import (
 )
 
 type GRPCListener struct {
-	listener net.Listener
-	server   *grpc.Server
+	listener       net.Listener
+	server         *grpc.Server
+	ingressStreams int64
+	serving        int32
 }
 
 func NewGRPCListener(
@@ -48,7 +52,14 @@ func NewGRPCListener(
 		log.Printf("Failed to start listener (port=%d) for gRPC: %s", conf.Port, err)
 		return nil, err
 	}
-	grpcServer := grpc.NewServer(grpc.Creds(transportCreds))
+	g := &GRPCListener{
+		listener: grpcListener,
+	}
+	grpcServer := grpc.NewServer(
+		grpc.Creds(transportCreds),
+		grpc.StreamInterceptor(g.countIngressStreams),
+	)
+	g.server = grpcServer
 
 	// v1 ingress
 	plumbingv1.RegisterDopplerIngestorServer(
@@ -67,15 +78,53 @@ func NewGRPCListener(
 		v2.NewIngressServer(envelopeBuffer, batcher),
 	)
 
-	return &GRPCListener{
-		listener: grpcListener,
-		server:   grpcServer,
-	}, nil
+	return g, nil
 }
 
 func (g *GRPCListener) Start() {
 	log.Printf("Starting gRPC server on %s", g.listener.Addr().String())
-	if err := g.server.Serve(g.listener); err != nil {
+	atomic.StoreInt32(&g.serving, 1)
+	err := g.server.Serve(g.listener)
+	if atomic.LoadInt32(&g.serving) == 0 {
+		// Serve returns an error once Stop closes the listener, which
+		// is a normal shutdown.
+		log.Printf("gRPC server stopped: %v", err)
+		return
+	}
+	if err != nil {
 		log.Fatalf("Failed to start gRPC server: %s", err)
 	}
 }
+
+// Stop closes the listener and all open streams.
+func (g *GRPCListener) Stop() {
+	atomic.StoreInt32(&g.serving, 0)
+	g.server.Stop()
+}
+
+// Ready returns an error if the gRPC server is not serving.
+func (g *GRPCListener) Ready() error {
+	if atomic.LoadInt32(&g.serving) == 0 {
+		return errors.New("gRPC server is not serving")
+	}
+	return nil
+}
+
+// IngressStreams returns the number of open streams from Metrons sending
+// envelopes to Doppler.
+func (g *GRPCListener) IngressStreams() int64 {
+	return atomic.LoadInt64(&g.ingressStreams)
+}
+
+func (g *GRPCListener) countIngressStreams(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if info.IsClientStream && !info.IsServerStream {
+		atomic.AddInt64(&g.ingressStreams, 1)
+		defer atomic.AddInt64(&g.ingressStreams, -1)
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,29 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package quarantine_test
+
+import "github.com/cloudfoundry/sonde-go/events"
+
+type mockEnvelopeSender struct {
+	SendToCalled chan bool
+	SendToInput  struct {
+		AppID chan string
+		E     chan *events.Envelope
+	}
+}
+
+func newMockEnvelopeSender() *mockEnvelopeSender {
+	m := &mockEnvelopeSender{}
+	m.SendToCalled = make(chan bool, 100)
+	m.SendToInput.AppID = make(chan string, 100)
+	m.SendToInput.E = make(chan *events.Envelope, 100)
+	return m
+}
+func (m *mockEnvelopeSender) SendTo(appID string, e *events.Envelope) {
+	m.SendToCalled <- true
+	m.SendToInput.AppID <- appID
+	m.SendToInput.E <- e
+}
@@ -0,0 +1,300 @@
+// Package quarantine protects Doppler from apps whose log rate suddenly
+// grows far beyond their recent baseline by sampling their logs until the
+// rate returns to normal.
+package quarantine
+
+import (
+	"fmt"
+	"log"
+	"metric"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/dropsonde/factories"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// baselineWeight is the weight given to the latest window when updating an
+// app's baseline.
+const baselineWeight = 0.2
+
+// numShards is the number of independently locked shards apps are spread
+// across so that recording log messages for different apps does not
+// contend.
+const numShards = 16
+
+type EnvelopeSender interface {
+	SendTo(appID string, e *events.Envelope)
+}
+
+type Config struct {
+	// RateMultiple is how many times its baseline an app's log rate has to
+	// reach for the app to be quarantined.
+	RateMultiple float64
+
+	// MinRate is the log rate in lines per second below which an app is
+	// never quarantined.
+	MinRate float64
+
+	// SampleRate is N when keeping 1 in N log lines of a quarantined app.
+	SampleRate uint64
+
+	// Window is the period over which log rates are measured.
+	Window time.Duration
+
+	// Exempt lists the IDs of apps that are never quarantined.
+	Exempt []string
+}
+
+// Quarantine forwards envelopes to its senders, sampling the log messages
+// of quarantined apps. Envelopes other than log messages are always
+// forwarded. Log messages are recorded with atomic counters. Locks are only
+// taken to add an app or when a window is completed.
+type Quarantine struct {
+	// dropped is accessed atomically and must stay 64-bit aligned.
+	dropped uint64
+
+	conf    Config
+	origin  string
+	senders []EnvelopeSender
+	exempt  map[string]bool
+	shards  [numShards]shard
+
+	hasNotices int32
+	mu         sync.Mutex
+	notices    []notice
+}
+
+type shard struct {
+	mu   sync.RWMutex
+	apps map[string]*appState
+}
+
+type appState struct {
+	// count, sampled and quarantined are accessed atomically.
+	count       uint64
+	sampled     uint64
+	quarantined int32
+
+	// baseline and hasBaseline are only accessed by Rotate.
+	baseline    float64
+	hasBaseline bool
+}
+
+type notice struct {
+	appID    string
+	envelope *events.Envelope
+}
+
+// New returns a Quarantine that forwards to the given senders. The origin is
+// used for the messages that tell an app it has been quarantined or
+// released.
+func New(origin string, c Config, senders ...EnvelopeSender) *Quarantine {
+	if c.SampleRate == 0 {
+		c.SampleRate = 1
+	}
+
+	exempt := make(map[string]bool)
+	for _, appID := range c.Exempt {
+		exempt[appID] = true
+	}
+
+	q := &Quarantine{
+		conf:    c,
+		origin:  origin,
+		senders: senders,
+		exempt:  exempt,
+	}
+	for i := range q.shards {
+		q.shards[i].apps = make(map[string]*appState)
+	}
+	return q
+}
+
+// SendTo records a log message for the app and forwards the envelope unless
+// the app is quarantined and the message is not sampled. Messages telling
+// apps they were quarantined or released are sent ahead of the envelope so
+// that they are delivered from the same goroutine as every other envelope.
+func (q *Quarantine) SendTo(appID string, e *events.Envelope) {
+	for _, n := range q.takeNotices() {
+		q.forward(n.appID, n.envelope)
+	}
+
+	if q.record(appID, e) {
+		q.forward(appID, e)
+	}
+}
+
+func (q *Quarantine) forward(appID string, e *events.Envelope) {
+	for _, s := range q.senders {
+		s.SendTo(appID, e)
+	}
+}
+
+func (q *Quarantine) takeNotices() []notice {
+	if atomic.LoadInt32(&q.hasNotices) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	notices := q.notices
+	q.notices = nil
+	atomic.StoreInt32(&q.hasNotices, 0)
+	return notices
+}
+
+func (q *Quarantine) record(appID string, e *events.Envelope) bool {
+	if e.GetEventType() != events.Envelope_LogMessage || q.exempt[appID] {
+		return true
+	}
+
+	s := q.state(appID)
+	atomic.AddUint64(&s.count, 1)
+
+	if atomic.LoadInt32(&s.quarantined) == 0 {
+		return true
+	}
+
+	if (atomic.AddUint64(&s.sampled, 1)-1)%q.conf.SampleRate == 0 {
+		return true
+	}
+	atomic.AddUint64(&q.dropped, 1)
+	return false
+}
+
+// state returns the state of an app, adding it if it is not tracked yet.
+func (q *Quarantine) state(appID string) *appState {
+	sh := &q.shards[shardIndex(appID)]
+	sh.mu.RLock()
+	s, ok := sh.apps[appID]
+	sh.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	s, ok = sh.apps[appID]
+	if !ok {
+		s = &appState{}
+		sh.apps[appID] = s
+	}
+	return s
+}
+
+// shardIndex hashes an app ID with FNV-1a.
+func shardIndex(appID string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(appID); i++ {
+		h ^= uint32(appID[i])
+		h *= 16777619
+	}
+	return int(h % numShards)
+}
+
+// Start completes a window every configured interval. It blocks forever.
+func (q *Quarantine) Start() {
+	for range time.Tick(q.conf.Window) {
+		q.Rotate()
+	}
+}
+
+// Rotate completes the current window. Apps whose log rate exceeded their
+// baseline by the configured multiple are quarantined and quarantined apps
+// whose rate has returned to normal are released. Apps that sent nothing
+// during the window are forgotten along with their baseline.
+func (q *Quarantine) Rotate() {
+	var quarantined int
+	for i := range q.shards {
+		quarantined += q.rotate(&q.shards[i])
+	}
+
+	// metric-documentation-v2: (loggregator.doppler.quarantined_apps) Number
+	// of apps whose logs are being sampled for exceeding their baseline
+	metric.SetGauge("quarantined_apps", float64(quarantined), "apps",
+		metric.WithVersion(2, 0),
+	)
+
+	// metric-documentation-v2: (loggregator.doppler.quarantine_dropped)
+	// Number of log messages of quarantined apps dropped by sampling
+	metric.IncCounter("quarantine_dropped",
+		metric.WithIncrement(atomic.SwapUint64(&q.dropped, 0)),
+		metric.WithVersion(2, 0),
+	)
+}
+
+// rotate completes the window of the apps of a shard and returns the
+// number of apps that are quarantined.
+func (q *Quarantine) rotate(sh *shard) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	var quarantined int
+	for appID, s := range sh.apps {
+		rate := float64(atomic.SwapUint64(&s.count, 0)) / q.conf.Window.Seconds()
+		isQuarantined := atomic.LoadInt32(&s.quarantined) == 1
+
+		switch {
+		case isQuarantined && !q.exceeds(s, rate):
+			isQuarantined = false
+			atomic.StoreInt32(&s.quarantined, 0)
+			log.Printf("Released app %s from quarantine at %.0f lines/s", appID, rate)
+			q.notify(appID, "Log rate has returned to normal. All log lines are being delivered again.")
+		case !isQuarantined && s.hasBaseline && q.exceeds(s, rate):
+			isQuarantined = true
+			atomic.StoreUint64(&s.sampled, 0)
+			atomic.StoreInt32(&s.quarantined, 1)
+			log.Printf("Quarantined app %s at %.0f lines/s, baseline %.0f lines/s", appID, rate, s.baseline)
+			q.notify(appID, fmt.Sprintf(
+				"Log rate of %.0f lines/s exceeded %g times the app's baseline of %.0f lines/s. Only 1 in %d log lines will be delivered until the rate returns to normal.",
+				rate,
+				q.conf.RateMultiple,
+				s.baseline,
+				q.conf.SampleRate,
+			))
+		}
+
+		if isQuarantined {
+			quarantined++
+			continue
+		}
+
+		if rate == 0 {
+			delete(sh.apps, appID)
+			continue
+		}
+
+		if !s.hasBaseline {
+			s.baseline = rate
+			s.hasBaseline = true
+			continue
+		}
+		s.baseline = baselineWeight*rate + (1-baselineWeight)*s.baseline
+	}
+	return quarantined
+}
+
+func (q *Quarantine) exceeds(s *appState, rate float64) bool {
+	return rate > q.conf.MinRate && rate > s.baseline*q.conf.RateMultiple
+}
+
+func (q *Quarantine) notify(appID, msg string) {
+	logMessage := factories.NewLogMessage(events.LogMessage_ERR, msg, appID, "LGR")
+	envelope, err := emitter.Wrap(logMessage, q.origin)
+	if err != nil {
+		log.Printf("Error marshalling message: %v", err)
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.notices = append(q.notices, notice{
+		appID:    appID,
+		envelope: envelope,
+	})
+	atomic.StoreInt32(&q.hasNotices, 1)
+}
@@ -0,0 +1,165 @@
+package quarantine_test
+
+import (
+	"doppler/internal/quarantine"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quarantine", func() {
+	var (
+		sender *mockEnvelopeSender
+		q      *quarantine.Quarantine
+
+		sendLogs = func(appID string, n int) {
+			for i := 0; i < n; i++ {
+				q.SendTo(appID, logEnvelope(appID))
+			}
+		}
+	)
+
+	BeforeEach(func() {
+		sender = newMockEnvelopeSender()
+		q = quarantine.New("doppler", quarantine.Config{
+			RateMultiple: 5,
+			MinRate:      5,
+			SampleRate:   4,
+			Window:       time.Second,
+			Exempt:       []string{"exempt-app-id"},
+		}, sender)
+	})
+
+	It("forwards every envelope of an app within its baseline", func() {
+		sendLogs("some-app-id", 2)
+		q.Rotate()
+		sendLogs("some-app-id", 8)
+
+		Expect(drain(sender)).To(HaveLen(10))
+	})
+
+	It("does not quarantine an app without a baseline", func() {
+		sendLogs("some-app-id", 20)
+		q.Rotate()
+		sendLogs("some-app-id", 8)
+
+		Expect(drain(sender)).To(HaveLen(28))
+	})
+
+	Context("when an app exceeds its baseline", func() {
+		BeforeEach(func() {
+			sendLogs("some-app-id", 2)
+			q.Rotate()
+			sendLogs("some-app-id", 20)
+			q.Rotate()
+			drain(sender)
+		})
+
+		It("tells the app it has been quarantined", func() {
+			sendLogs("some-app-id", 1)
+
+			envelopes := drain(sender)
+			Expect(envelopes).To(HaveLen(2))
+			Expect(envelopes[0].GetLogMessage().GetSourceType()).To(Equal("LGR"))
+			Expect(envelopes[0].GetLogMessage().GetAppId()).To(Equal("some-app-id"))
+			Expect(string(envelopes[0].GetLogMessage().GetMessage())).To(ContainSubstring("1 in 4"))
+		})
+
+		It("forwards 1 in N log messages", func() {
+			sendLogs("some-app-id", 8)
+
+			Expect(drain(sender)).To(HaveLen(3))
+		})
+
+		It("forwards envelopes other than log messages", func() {
+			for i := 0; i < 8; i++ {
+				q.SendTo("some-app-id", &events.Envelope{
+					Origin:    proto.String("some-origin"),
+					EventType: events.Envelope_ContainerMetric.Enum(),
+				})
+			}
+
+			Expect(drain(sender)).To(HaveLen(9))
+		})
+
+		It("does not affect other apps", func() {
+			sendLogs("other-app-id", 8)
+
+			Expect(drain(sender)).To(HaveLen(9))
+		})
+
+		It("releases the app once its rate returns to normal", func() {
+			sendLogs("some-app-id", 2)
+			q.Rotate()
+			drain(sender)
+
+			sendLogs("some-app-id", 8)
+
+			envelopes := drain(sender)
+			Expect(envelopes).To(HaveLen(9))
+			Expect(envelopes[0].GetLogMessage().GetSourceType()).To(Equal("LGR"))
+			Expect(string(envelopes[0].GetLogMessage().GetMessage())).To(ContainSubstring("returned to normal"))
+		})
+
+		It("keeps the app quarantined while its rate stays high", func() {
+			sendLogs("some-app-id", 20)
+			q.Rotate()
+			drain(sender)
+
+			sendLogs("some-app-id", 8)
+
+			Expect(drain(sender)).To(HaveLen(2))
+		})
+	})
+
+	It("does not quarantine an app below the minimum rate", func() {
+		sendLogs("some-app-id", 1)
+		q.Rotate()
+		sendLogs("some-app-id", 5)
+		q.Rotate()
+		sendLogs("some-app-id", 8)
+
+		Expect(drain(sender)).To(HaveLen(14))
+	})
+
+	It("does not quarantine exempt apps", func() {
+		sendLogs("exempt-app-id", 2)
+		q.Rotate()
+		sendLogs("exempt-app-id", 20)
+		q.Rotate()
+		sendLogs("exempt-app-id", 8)
+
+		Expect(drain(sender)).To(HaveLen(30))
+	})
+})
+
+func logEnvelope(appID string) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String("some-origin"),
+		EventType: events.Envelope_LogMessage.Enum(),
+		LogMessage: &events.LogMessage{
+			Message:     []byte("some-message"),
+			MessageType: events.LogMessage_OUT.Enum(),
+			Timestamp:   proto.Int64(1),
+			AppId:       proto.String(appID),
+		},
+	}
+}
+
+func drain(sender *mockEnvelopeSender) []*events.Envelope {
+	var envelopes []*events.Envelope
+	for {
+		select {
+		case e := <-sender.SendToInput.E:
+			<-sender.SendToCalled
+			<-sender.SendToInput.AppID
+			envelopes = append(envelopes, e)
+		default:
+			return envelopes
+		}
+	}
+}
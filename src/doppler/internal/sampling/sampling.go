@@ -0,0 +1,71 @@
+// Package sampling decides which envelopes are delivered to subscriptions
+// that only want a sample of the firehose.
+package sampling
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+var requestIDPrefix = []byte("vcap_request_id:")
+
+// Keep reports whether an envelope is kept when sampling 1 in rate
+// envelopes. The decision is a hash of the envelope rather than a random
+// draw, so every Doppler makes the same decision for an envelope and an
+// HTTP request's HttpStartStop and router access log are kept or dropped
+// together.
+func Keep(e *events.Envelope, rate uint32) bool {
+	if rate <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write(key(e))
+	return h.Sum32()%rate == 0
+}
+
+func key(e *events.Envelope) []byte {
+	switch e.GetEventType() {
+	case events.Envelope_HttpStartStop:
+		if id := e.GetHttpStartStop().GetRequestId(); id != nil {
+			return []byte(formatUUID(id))
+		}
+	case events.Envelope_LogMessage:
+		if id := requestID(e.GetLogMessage().GetMessage()); id != nil {
+			return id
+		}
+	}
+
+	return []byte(fmt.Sprintf("%s/%d/%d", e.GetOrigin(), e.GetEventType(), e.GetTimestamp()))
+}
+
+// requestID returns the request ID of a router access log line or nil if
+// the message does not have one.
+func requestID(msg []byte) []byte {
+	i := bytes.Index(msg, requestIDPrefix)
+	if i < 0 {
+		return nil
+	}
+
+	id := bytes.TrimPrefix(msg[i+len(requestIDPrefix):], []byte(`"`))
+	if end := bytes.IndexAny(id, `" `); end >= 0 {
+		id = id[:end]
+	}
+	if len(id) == 0 {
+		return nil
+	}
+	return id
+}
+
+// formatUUID formats a UUID the same way the router writes it in its access
+// logs.
+func formatUUID(id *events.UUID) string {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], id.GetLow())
+	binary.LittleEndian.PutUint64(b[8:], id.GetHigh())
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,13 @@
+package sampling_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSampling(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sampling Suite")
+}
@@ -0,0 +1,92 @@
+package sampling_test
+
+import (
+	"doppler/internal/sampling"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keep", func() {
+	It("keeps every envelope when the rate is 0 or 1", func() {
+		for i := 0; i < 100; i++ {
+			Expect(sampling.Keep(counterEnvelope(i), 0)).To(BeTrue())
+			Expect(sampling.Keep(counterEnvelope(i), 1)).To(BeTrue())
+		}
+	})
+
+	It("keeps about 1 in rate envelopes", func() {
+		var kept int
+		for i := 0; i < 10000; i++ {
+			if sampling.Keep(counterEnvelope(i), 10) {
+				kept++
+			}
+		}
+
+		Expect(kept).To(BeNumerically("~", 1000, 200))
+	})
+
+	It("decides the same way for the same envelope", func() {
+		for i := 0; i < 100; i++ {
+			Expect(sampling.Keep(counterEnvelope(i), 10)).To(
+				Equal(sampling.Keep(counterEnvelope(i), 10)),
+			)
+		}
+	})
+
+	It("decides the same way for a request's HttpStartStop and access log", func() {
+		var kept int
+		for i := 0; i < 1000; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+
+			httpStartStop := &events.Envelope{
+				Origin:    proto.String("gorouter"),
+				EventType: events.Envelope_HttpStartStop.Enum(),
+				Timestamp: proto.Int64(int64(i)),
+				HttpStartStop: &events.HttpStartStop{
+					RequestId: &events.UUID{
+						Low:  proto.Uint64(binary.LittleEndian.Uint64(id[:8])),
+						High: proto.Uint64(binary.LittleEndian.Uint64(id[8:])),
+					},
+				},
+			}
+			accessLog := &events.Envelope{
+				Origin:    proto.String("gorouter"),
+				EventType: events.Envelope_LogMessage.Enum(),
+				Timestamp: proto.Int64(int64(i + 1)),
+				LogMessage: &events.LogMessage{
+					Message: []byte(fmt.Sprintf(
+						`app.example.com - "GET / HTTP/1.1" 200 0 5 "-" "curl" vcap_request_id:"%x-%x-%x-%x-%x" response_time:0.01`,
+						id[0:4], id[4:6], id[6:8], id[8:10], id[10:],
+					)),
+					MessageType: events.LogMessage_OUT.Enum(),
+					Timestamp:   proto.Int64(int64(i + 1)),
+					SourceType:  proto.String("RTR"),
+				},
+			}
+
+			keep := sampling.Keep(httpStartStop, 10)
+			Expect(sampling.Keep(accessLog, 10)).To(Equal(keep))
+			if keep {
+				kept++
+			}
+		}
+
+		Expect(kept).To(BeNumerically(">", 0))
+	})
+})
+
+func counterEnvelope(i int) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String("some-origin"),
+		EventType: events.Envelope_CounterEvent.Enum(),
+		Timestamp: proto.Int64(int64(i)),
+	}
+}
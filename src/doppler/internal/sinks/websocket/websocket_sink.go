@@ -35,6 +35,7 @@ type WebsocketSink struct {
 	writeTimeout           time.Duration
 	dropsondeOrigin        string
 	counter                Counter
+	sampleRate             uint32
 }
 
 func NewWebsocketSink(appID string, ws remoteMessageWriter, messageDrainBufferSize uint, writeTimeout time.Duration, dropsondeOrigin string) *WebsocketSink {
@@ -53,6 +54,15 @@ func (sink *WebsocketSink) SetCounter(counter Counter) {
 	sink.counter = counter
 }
 
+// SetSampleRate makes a firehose sink receive only 1 in rate envelopes.
+func (sink *WebsocketSink) SetSampleRate(rate uint32) {
+	sink.sampleRate = rate
+}
+
+func (sink *WebsocketSink) SampleRate() uint32 {
+	return sink.sampleRate
+}
+
 func (sink *WebsocketSink) Identifier() string {
 	return sink.ws.RemoteAddr().String()
 }
@@ -5,6 +5,7 @@ import (
 	"doppler/internal/sinks/dump"
 	"doppler/internal/sinks/syslog"
 	"doppler/internal/sinks/websocket"
+	"metric"
 	"time"
 
 	"doppler/internal/sinks/containermetric"
@@ -39,23 +40,44 @@ func (s *SinkManagerMetrics) run(ticker *time.Ticker) {
 		default:
 		}
 
-		// metric-documentation-v1: (messageRouter.numberOfDumpSinks) Number of Dump Sinks
+		// metric-documentation-v1: (messageRouter.numberOfDumpSinks) DEPRECATED
+		// Number of Dump Sinks. Replaced by loggregator.doppler.sinks with
+		// type:dump.
 		metrics.SendValue("messageRouter.numberOfDumpSinks", float64(atomic.LoadInt32(&s.dumpSinks)), "sinks")
-		// metric-documentation-v1: (messageRouter.numberOfWebsocketSinks) Number of
-		// websocket sinks
+		// metric-documentation-v1: (messageRouter.numberOfWebsocketSinks) DEPRECATED
+		// Number of websocket sinks. Replaced by loggregator.doppler.sinks with
+		// type:websocket.
 		metrics.SendValue("messageRouter.numberOfWebsocketSinks", float64(atomic.LoadInt32(&s.websocketSinks)), "sinks")
-		// metric-documentation-v1: (messageRouter.numberOfSyslogSinks) Number of
-		// syslog sinks
+		// metric-documentation-v1: (messageRouter.numberOfSyslogSinks) DEPRECATED
+		// Number of syslog sinks. Replaced by loggregator.doppler.sinks with
+		// type:syslog.
 		metrics.SendValue("messageRouter.numberOfSyslogSinks", float64(atomic.LoadInt32(&s.syslogSinks)), "sinks")
-		// metric-documentation-v1: (messageRouter.numberOfFirehoseSinks) Number of
-		// firehose sinks
+		// metric-documentation-v1: (messageRouter.numberOfFirehoseSinks) DEPRECATED
+		// Number of firehose sinks. Replaced by loggregator.doppler.sinks with
+		// type:firehose.
 		metrics.SendValue("messageRouter.numberOfFirehoseSinks", float64(atomic.LoadInt32(&s.firehoseSinks)), "sinks")
-		// metric-documentation-v1: (messageRouter.numberOfContainerMetricSinks) Number of
-		// container metric sinks
+		// metric-documentation-v1: (messageRouter.numberOfContainerMetricSinks)
+		// DEPRECATED Number of container metric sinks. Replaced by
+		// loggregator.doppler.sinks with type:container_metric.
 		metrics.SendValue("messageRouter.numberOfContainerMetricSinks", float64(atomic.LoadInt32(&s.containerMetrics)), "sinks")
+
+		// metric-documentation-v2: (loggregator.doppler.sinks) Number of sinks,
+		// tagged by type
+		s.setSinksGauge("dump", &s.dumpSinks)
+		s.setSinksGauge("websocket", &s.websocketSinks)
+		s.setSinksGauge("syslog", &s.syslogSinks)
+		s.setSinksGauge("firehose", &s.firehoseSinks)
+		s.setSinksGauge("container_metric", &s.containerMetrics)
 	}
 }
 
+func (s *SinkManagerMetrics) setSinksGauge(sinkType string, count *int32) {
+	metric.SetGauge("sinks", float64(atomic.LoadInt32(count)), "sinks",
+		metric.WithVersion(2, 0),
+		metric.WithTag("type", sinkType),
+	)
+}
+
 func (s *SinkManagerMetrics) Inc(sink sinks.Sink) {
 	switch sink.(type) {
 	case *dump.DumpSink:
@@ -40,6 +40,27 @@ type SinkManager struct {
 	dialTimeout         time.Duration
 
 	stopOnce sync.Once
+
+	syslogSinksLock sync.Mutex
+	syslogSinks     map[*syslog.SyslogSink]struct{}
+
+	egress EgressRecorder
+}
+
+// EgressRecorder records envelopes written to the sinks of an app.
+type EgressRecorder interface {
+	RecordEgress(appID string, envelopes, bytes int)
+}
+
+// Option configures a SinkManager.
+type Option func(*SinkManager)
+
+// WithEgressRecorder records every envelope written to a websocket, syslog
+// or firehose sink with the given recorder.
+func WithEgressRecorder(e EgressRecorder) Option {
+	return func(sm *SinkManager) {
+		sm.egress = e
+	}
 }
 
 func New(
@@ -52,8 +73,9 @@ func New(
 	sinkIOTimeout,
 	metricTTL,
 	dialTimeout time.Duration,
+	opts ...Option,
 ) *SinkManager {
-	return &SinkManager{
+	sm := &SinkManager{
 		doneChannel:            make(chan struct{}),
 		errorChannel:           make(chan *events.Envelope, 100),
 		urlBlacklistManager:    blackListManager,
@@ -67,7 +89,12 @@ func New(
 		sinkIOTimeout:          sinkIOTimeout,
 		metricTTL:              metricTTL,
 		dialTimeout:            dialTimeout,
+		syslogSinks:            make(map[*syslog.SyslogSink]struct{}),
+	}
+	for _, o := range opts {
+		o(sm)
 	}
+	return sm
 }
 
 func (sm *SinkManager) Start(newAppServiceChan, deletedAppServiceChan <-chan store.AppService) {
@@ -85,10 +112,31 @@ func (sm *SinkManager) Stop() {
 	})
 }
 
+// Flush stops the sink manager and waits up to timeout for syslog sinks to
+// write out the envelopes they have buffered. Syslog sinks that have not
+// finished by then are disconnected. It reports whether all syslog sinks
+// finished before the timeout.
+func (sm *SinkManager) Flush(timeout time.Duration) bool {
+	sm.Stop()
+
+	deadline := time.Now().Add(timeout)
+	for sm.runningSyslogSinks() > 0 {
+		if time.Now().After(deadline) {
+			sm.disconnectSyslogSinks()
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
 func (sm *SinkManager) SendTo(appID string, msg *events.Envelope) {
 	sm.ensureRecentLogsSinkFor(appID)
 	sm.ensureContainerMetricsSinkFor(appID)
-	sm.sinks.Broadcast(appID, msg)
+	writes := sm.sinks.Broadcast(appID, msg)
+	if sm.egress != nil && writes > 0 {
+		sm.egress.RecordEgress(appID, writes, writes*msg.Size())
+	}
 }
 
 func (sm *SinkManager) RegisterSink(sink sinks.Sink) bool {
@@ -101,9 +149,17 @@ func (sm *SinkManager) RegisterSink(sink sinks.Sink) bool {
 	// metric-documentation-v1: see sink_manager_metrics.go for details
 	sm.metrics.Inc(sink)
 
+	syslogSink, isSyslogSink := sink.(*syslog.SyslogSink)
+	if isSyslogSink {
+		sm.trackSyslogSink(syslogSink)
+	}
+
 	go func() {
 		sink.Run(inputChan)
 		sm.UnregisterSink(sink)
+		if isSyslogSink {
+			sm.untrackSyslogSink(syslogSink)
+		}
 	}()
 
 	return true
@@ -122,6 +178,32 @@ func (sm *SinkManager) UnregisterSink(sink sinks.Sink) {
 	}
 }
 
+func (sm *SinkManager) trackSyslogSink(sink *syslog.SyslogSink) {
+	sm.syslogSinksLock.Lock()
+	defer sm.syslogSinksLock.Unlock()
+	sm.syslogSinks[sink] = struct{}{}
+}
+
+func (sm *SinkManager) untrackSyslogSink(sink *syslog.SyslogSink) {
+	sm.syslogSinksLock.Lock()
+	defer sm.syslogSinksLock.Unlock()
+	delete(sm.syslogSinks, sink)
+}
+
+func (sm *SinkManager) runningSyslogSinks() int {
+	sm.syslogSinksLock.Lock()
+	defer sm.syslogSinksLock.Unlock()
+	return len(sm.syslogSinks)
+}
+
+func (sm *SinkManager) disconnectSyslogSinks() {
+	sm.syslogSinksLock.Lock()
+	defer sm.syslogSinksLock.Unlock()
+	for sink := range sm.syslogSinks {
+		sink.Disconnect()
+	}
+}
+
 func (sm *SinkManager) IsFirehoseRegistered(sink sinks.Sink) bool {
 	return sm.sinks.IsFirehoseRegistered(sink)
 }
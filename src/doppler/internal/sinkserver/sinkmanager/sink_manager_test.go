@@ -9,6 +9,8 @@ import (
 	"doppler/internal/sinkserver/blacklist"
 	"doppler/internal/sinkserver/sinkmanager"
 	"doppler/internal/store"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"sync"
@@ -73,6 +75,27 @@ var _ = Describe("SinkManager", func() {
 			Expect(sink2.Received()[0]).To(Equal(expectedMessage))
 		})
 
+		It("records egress to the sinks of an app", func() {
+			recorder := &spyEgressRecorder{}
+			sinkManager := sinkmanager.New(1, true, blackListManager, 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 1*time.Second,
+				sinkmanager.WithEgressRecorder(recorder),
+			)
+			defer sinkManager.Stop()
+
+			sink := &channelSink{appId: "myApp",
+				identifier: "myAppChan1",
+				done:       make(chan struct{}),
+			}
+			sinkManager.RegisterSink(sink)
+
+			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "Some Data", "myApp", "App"), "origin")
+			sinkManager.SendTo("myApp", msg)
+
+			Expect(recorder.appID).To(Equal("myApp"))
+			Expect(recorder.envelopes).To(Equal(1))
+			Expect(recorder.bytes).To(Equal(msg.Size()))
+		})
+
 		It("only sends to sinks that match the appID", func(done Done) {
 			sink1 := &channelSink{appId: "myApp1",
 				identifier: "myAppChan1",
@@ -267,6 +290,53 @@ var _ = Describe("SinkManager", func() {
 		})
 	})
 
+	Describe("Flush", func() {
+		var registerSyslogSink = func(host string) {
+			url := &url.URL{Scheme: "syslog", Host: host}
+			writer, err := syslogwriter.NewSyslogWriter(url, "appId", "loggregator", &net.Dialer{Timeout: 500 * time.Millisecond}, 0)
+			Expect(err).ToNot(HaveOccurred())
+			syslogSink := syslog.NewSyslogSink("appId", url, 100, writer, func(string, string) {}, "dropsonde-origin")
+			Expect(sinkManager.RegisterSink(syslogSink)).To(BeTrue())
+		}
+
+		It("writes buffered envelopes to syslog drains before returning", func() {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer lis.Close()
+
+			received := make(chan []byte, 1)
+			go func() {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				data, _ := ioutil.ReadAll(conn)
+				received <- data
+			}()
+
+			registerSyslogSink(lis.Addr().String())
+			for i := 0; i < 10; i++ {
+				msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, fmt.Sprintf("message-%d", i), "appId", "App"), "origin")
+				sinkManager.SendTo("appId", msg)
+			}
+
+			Expect(sinkManager.Flush(5 * time.Second)).To(BeTrue())
+
+			var data []byte
+			Eventually(received).Should(Receive(&data))
+			Expect(string(data)).To(ContainSubstring("message-9"))
+		})
+
+		It("gives up once the timeout has elapsed", func() {
+			registerSyslogSink("127.0.0.1:1")
+			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "appId", "App"), "origin")
+			sinkManager.SendTo("appId", msg)
+
+			Expect(sinkManager.Flush(100 * time.Millisecond)).To(BeFalse())
+		})
+	})
+
 	Describe("UnregisterSink", func() {
 		Context("with a DumpSink", func() {
 			var dumpSink *dump.DumpSink
@@ -466,6 +536,18 @@ var _ = Describe("SinkManager", func() {
 	})
 })
 
+type spyEgressRecorder struct {
+	appID     string
+	envelopes int
+	bytes     int
+}
+
+func (s *spyEgressRecorder) RecordEgress(appID string, envelopes, bytes int) {
+	s.appID = appID
+	s.envelopes += envelopes
+	s.bytes += bytes
+}
+
 type channelSink struct {
 	sync.RWMutex
 	done              chan struct{}
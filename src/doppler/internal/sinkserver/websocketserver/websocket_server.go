@@ -9,6 +9,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -155,8 +156,19 @@ func (w *WebsocketServer) firehoseHandler(paths []string, writer http.ResponseWr
 		return nil, fmt.Errorf("missing subscription id in firehose request: (returning %d) %s", http.StatusBadRequest, request.URL.Path)
 	}
 	firehoseSubscriptionId := paths[2]
+
+	var sampleRate uint64
+	if param := request.URL.Query().Get("sample_rate"); param != "" {
+		var err error
+		sampleRate, err = strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			http.Error(writer, "invalid sample_rate in firehose request: "+param, http.StatusBadRequest)
+			return nil, fmt.Errorf("invalid sample_rate in firehose request: (returning %d) %s", http.StatusBadRequest, param)
+		}
+	}
+
 	f := func(ws *gorilla.Conn) {
-		w.streamFirehose(firehoseSubscriptionId, ws)
+		w.streamFirehose(firehoseSubscriptionId, uint32(sampleRate), ws)
 	}
 	return f, nil
 }
@@ -215,7 +227,7 @@ func (w *WebsocketServer) streamLogs(appId string, websocketConnection *gorilla.
 	w.streamWebsocket(websocketSink, websocketConnection, w.sinkManager.RegisterSink, w.sinkManager.UnregisterSink)
 }
 
-func (w *WebsocketServer) streamFirehose(subscriptionId string, websocketConnection *gorilla.Conn) {
+func (w *WebsocketServer) streamFirehose(subscriptionId string, sampleRate uint32, websocketConnection *gorilla.Conn) {
 	websocketSink := websocket.NewWebsocketSink(
 		subscriptionId,
 		websocketConnection,
@@ -226,6 +238,7 @@ func (w *WebsocketServer) streamFirehose(subscriptionId string, websocketConnect
 
 	firehoseCounter := newFirehoseCounter(subscriptionId, w.batcher)
 	websocketSink.SetCounter(firehoseCounter)
+	websocketSink.SetSampleRate(sampleRate)
 
 	w.streamWebsocket(websocketSink, websocketConnection, w.sinkManager.RegisterFirehoseSink, w.sinkManager.UnregisterFirehoseSink)
 }
@@ -268,6 +268,15 @@ var _ = Describe("WebsocketServer", func() {
 		Expect(bytes).To(ContainSubstring("missing subscription id in firehose request"))
 	})
 
+	It("rejects an invalid firehose sample rate", func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/firehose/subscription-id?sample_rate=bad", apiEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		bytes, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bytes).To(ContainSubstring("invalid sample_rate in firehose request"))
+	})
+
 	It("still sends to 'live' sinks", func() {
 		stopKeepAlive, connectionDropped, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/stream", apiEndpoint, appId))
 		defer cleanup()
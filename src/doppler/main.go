@@ -6,22 +6,28 @@ import (
 	"log"
 	"math/rand"
 	"metric"
+	"net"
+	"net/http"
 	"plumbing"
 	"sync"
 	"time"
 
 	"diodes"
 	"doppler/app"
+	"doppler/internal/appstats"
 	grpcv1 "doppler/internal/grpcmanager/v1"
 	"doppler/internal/listeners"
+	"doppler/internal/quarantine"
 	"doppler/internal/sinkserver"
 	"doppler/internal/sinkserver/blacklist"
 	"doppler/internal/sinkserver/sinkmanager"
 	"doppler/internal/sinkserver/websocketserver"
 	"doppler/internal/store"
 	"dopplerservice"
+	"health"
 	"monitor"
 	"profiler"
+	"prometheus"
 	"signalmanager"
 
 	"code.cloudfoundry.org/workpool"
@@ -67,11 +73,21 @@ func main() {
 		log.Fatal(err)
 	}
 
-	setupMetricsEmitter(conf)
+	var promRegistry *prometheus.Registry
+	if conf.PrometheusPort != 0 {
+		promRegistry = prometheus.NewRegistry("doppler")
+	}
+	setupMetricsEmitter(conf, promRegistry)
 	monitorInterval := time.Duration(conf.MonitorIntervalSeconds) * time.Second
 	openFileMonitor := monitor.NewLinuxFD(monitorInterval)
 	uptimeMonitor := monitor.NewUptime(monitorInterval)
 
+	accountant := appstats.NewAccountant(
+		conf.AppAccounting.Capacity,
+		conf.AppAccounting.TopN,
+		time.Duration(conf.AppAccounting.IntervalSeconds)*time.Second,
+	)
+
 	//------------------------------
 	// Caching
 	//------------------------------
@@ -85,6 +101,7 @@ func main() {
 		time.Duration(conf.SinkIOTimeoutSeconds)*time.Second,
 		time.Duration(conf.ContainerMetricTTLSeconds)*time.Second,
 		time.Duration(conf.SinkDialTimeoutSeconds)*time.Second,
+		sinkmanager.WithEgressRecorder(accountant),
 	)
 
 	//------------------------------
@@ -95,7 +112,7 @@ func main() {
 	errChan := make(chan error)
 	var wg sync.WaitGroup
 	dropsondeUnmarshallerCollection := dropsonde_unmarshaller.NewDropsondeUnmarshallerCollection(conf.UnmarshallerCount)
-	batcher := initializeMetrics(conf.MetricBatchIntervalMilliseconds)
+	batcher := initializeMetrics(conf.MetricBatchIntervalMilliseconds, promRegistry)
 	envelopeBuffer := diodes.NewManyToOneEnvelope(10000, gendiodes.AlertFunc(func(missed int) {
 		log.Printf("Shed %d envelopes", missed)
 		// metric-documentation-v1: (doppler.shedEnvelopes) Number of envelopes dropped by the
@@ -116,8 +133,20 @@ func main() {
 		"udpListener",
 	)
 
-	grpcRouter := grpcv1.NewRouter()
-	messageRouter := sinkserver.NewMessageRouter(sinkManager, grpcRouter)
+	grpcRouter := grpcv1.NewRouter(grpcv1.WithEgressRecorder(accountant))
+	senders := []sinkserver.EnvelopeSender{sinkManager, grpcRouter}
+	if conf.Quarantine.Enabled {
+		q := quarantine.New(dopplerOrigin, quarantine.Config{
+			RateMultiple: conf.Quarantine.RateMultiple,
+			MinRate:      conf.Quarantine.MinRate,
+			SampleRate:   conf.Quarantine.SampleRate,
+			Window:       time.Duration(conf.Quarantine.WindowSeconds) * time.Second,
+			Exempt:       conf.Quarantine.ExemptAppIDs,
+		}, sinkManager, grpcRouter)
+		go q.Start()
+		senders = []sinkserver.EnvelopeSender{q}
+	}
+	messageRouter := sinkserver.NewMessageRouter(append(senders, accountant)...)
 	signatureVerifier := signature.NewVerifier(conf.SharedSecret)
 	grpcListener, err := listeners.NewGRPCListener(
 		grpcRouter,
@@ -185,6 +214,28 @@ func main() {
 	p := profiler.New(conf.PPROFPort)
 	go p.Start()
 
+	if conf.HealthPort != 0 {
+		registry := health.NewRegistry()
+		registry.Register("etcd", func() error {
+			_, err := storeAdapter.ListRecursively(dopplerservice.META_ROOT)
+			if err == storeadapter.ErrorKeyNotFound {
+				return nil
+			}
+			return err
+		})
+		registry.Register("grpc", grpcListener.Ready)
+		go health.NewServer(conf.HealthPort, registry).Start()
+	}
+
+	if promRegistry != nil {
+		go prometheus.NewServer(conf.PrometheusPort, promRegistry).Start()
+	}
+
+	go accountant.Start()
+	if conf.AppAccounting.Port != 0 {
+		go serveAppAccounting(conf.AppAccounting.Port, accountant)
+	}
+
 	//------------------------------
 	// Post Start
 	//------------------------------
@@ -196,10 +247,26 @@ func main() {
 		case <-killChan:
 			log.Print("Shutting down")
 
+			// Withdraw the announcements first so that Metrons and traffic
+			// controllers move to other Dopplers while this one is still
+			// serving.
 			stopped := make(chan bool)
 			legacyStopped := make(chan bool)
 			releaseNodeChan <- stopped
 			legacyReleaseNodeChan <- legacyStopped
+			<-stopped
+			<-legacyStopped
+
+			err := dopplerservice.Withdraw(conf, storeAdapter)
+			if err != nil {
+				log.Printf("Failed to withdraw announcements: %s", err)
+			}
+
+			drainIngress(
+				grpcListener,
+				time.Duration(conf.DrainMinimumSeconds)*time.Second,
+				time.Duration(conf.DrainTimeoutSeconds)*time.Second,
+			)
 
 			stop(
 				errChan,
@@ -208,14 +275,13 @@ func main() {
 				uptimeMonitor,
 				appStoreWatcher,
 				udpListener,
+				grpcListener,
 				sinkManager,
 				websocketServer,
 				storeAdapter,
+				time.Duration(conf.SinkFlushTimeoutSeconds)*time.Second,
 			)
 
-			<-stopped
-			<-legacyStopped
-
 			return
 		}
 	}
@@ -306,6 +372,26 @@ func start(
 	}
 }
 
+// drainIngress waits up to timeout for Metrons to close their gRPC ingress
+// streams after the announcements have been withdrawn. It serves for at
+// least minimum even if no stream is open as Metrons that find Dopplers
+// through DNS only notice the Doppler going away when they refresh its
+// addresses.
+func drainIngress(grpcListener *listeners.GRPCListener, minimum, timeout time.Duration) {
+	log.Printf("Waiting %s to %s for %d ingress streams to drain", minimum, timeout, grpcListener.IngressStreams())
+
+	time.Sleep(minimum)
+
+	deadline := time.Now().Add(timeout - minimum)
+	for grpcListener.IngressStreams() > 0 {
+		if time.Now().After(deadline) {
+			log.Printf("%d ingress streams did not drain", grpcListener.IngressStreams())
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 func stop(
 	errChan chan error,
 	wg sync.WaitGroup,
@@ -313,12 +399,19 @@ func stop(
 	uptimeMonitor *monitor.Uptime,
 	appStoreWatcher *store.AppServiceStoreWatcher,
 	udpListener *listeners.UDPListener,
+	grpcListener *listeners.GRPCListener,
 	sinkManager *sinkmanager.SinkManager,
 	websocketServer *websocketserver.WebsocketServer,
 	storeAdapter storeadapter.StoreAdapter,
+	sinkFlushTimeout time.Duration,
 ) {
-	go udpListener.Stop()
-	go sinkManager.Stop()
+	udpListener.Stop()
+	grpcListener.Stop()
+
+	if !sinkManager.Flush(sinkFlushTimeout) {
+		log.Printf("Syslog drains did not flush within %s", sinkFlushTimeout)
+	}
+
 	go websocketServer.Stop()
 	appStoreWatcher.Stop()
 	wg.Wait()
@@ -331,10 +424,11 @@ func stop(
 
 	uptimeMonitor.Stop()
 	openFileMonitor.Stop()
+	metric.Flush()
 }
 
-func initializeMetrics(batchIntervalMilliseconds uint) *metricbatcher.MetricBatcher {
-	eventEmitter := dropsonde.AutowiredEmitter()
+func initializeMetrics(batchIntervalMilliseconds uint, promRegistry *prometheus.Registry) *metricbatcher.MetricBatcher {
+	eventEmitter := prometheus.NewEmitter(promRegistry, dropsonde.AutowiredEmitter())
 	metricSender := metric_sender.NewMetricSender(eventEmitter)
 	metricBatcher := metricbatcher.New(
 		metricSender,
@@ -373,7 +467,7 @@ func connectToEtcd(conf *app.Config) storeadapter.StoreAdapter {
 	return etcdStoreAdapter
 }
 
-func setupMetricsEmitter(conf *app.Config) {
+func setupMetricsEmitter(conf *app.Config, promRegistry *prometheus.Registry) {
 	serverCreds, err := plumbing.NewCredentials(
 		conf.GRPC.CertFile,
 		conf.GRPC.KeyFile,
@@ -392,5 +486,22 @@ func setupMetricsEmitter(conf *app.Config) {
 		metric.WithOrigin("loggregator.doppler"),
 		metric.WithAddr(conf.MetronConfig.GRPCAddress),
 		metric.WithDeploymentMeta(conf.DeploymentName, conf.JobName, conf.Index),
+		metric.WithPrometheus(promRegistry),
 	)
 }
+
+func serveAppAccounting(port uint32, accountant *appstats.Accountant) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Panicf("Error creating app accounting listener: %s", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/apps/top", accountant)
+
+	log.Printf("Starting app accounting server on: %s", lis.Addr().String())
+	err = http.Serve(lis, mux)
+	if err != nil {
+		log.Panicf("Error starting app accounting server: %s", err)
+	}
+}
@@ -25,7 +25,7 @@ func Announce(ip string, ttl time.Duration, config *app.Config, storeAdapter sto
 		panic(err)
 	}
 
-	key := fmt.Sprintf("%s/%s/%s/%s", META_ROOT, config.Zone, config.JobName, config.Index)
+	key := metaKey(config)
 	log.Printf("Starting Health Status Updates to Store: %s", key)
 
 	node := storeadapter.StoreNode{
@@ -52,7 +52,7 @@ func Announce(ip string, ttl time.Duration, config *app.Config, storeAdapter sto
 }
 
 func AnnounceLegacy(ip string, ttl time.Duration, config *app.Config, storeAdapter storeadapter.StoreAdapter) chan (chan bool) {
-	key := fmt.Sprintf("%s/%s/%s/%s", LEGACY_ROOT, config.Zone, config.JobName, config.Index)
+	key := legacyKey(config)
 	status, stopChan, err := storeAdapter.MaintainNode(storeadapter.StoreNode{
 		Key:   key,
 		Value: []byte(ip),
@@ -73,6 +73,30 @@ func AnnounceLegacy(ip string, ttl time.Duration, config *app.Config, storeAdapt
 	return stopChan
 }
 
+// Withdraw deletes the meta and legacy announcements for this Doppler so
+// that clients stop routing to it. It should be called after the nodes
+// returned by Announce and AnnounceLegacy have been released.
+func Withdraw(config *app.Config, storeAdapter storeadapter.StoreAdapter) error {
+	keys := []string{metaKey(config), legacyKey(config)}
+	log.Printf("Withdrawing announcements from Store: %v", keys)
+
+	for _, key := range keys {
+		err := storeAdapter.Delete(key)
+		if err != nil && err != storeadapter.ErrorKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func metaKey(config *app.Config) string {
+	return fmt.Sprintf("%s/%s/%s/%s", META_ROOT, config.Zone, config.JobName, config.Index)
+}
+
+func legacyKey(config *app.Config) string {
+	return fmt.Sprintf("%s/%s/%s/%s", LEGACY_ROOT, config.Zone, config.JobName, config.Index)
+}
+
 func buildDopplerMeta(ip string, config *app.Config) ([]byte, error) {
 	udpAddr := fmt.Sprintf("udp://%s:%d", ip, config.IncomingUDPPort)
 	tcpAddr := fmt.Sprintf("tcp://%s:%d", ip, config.IncomingTCPPort)
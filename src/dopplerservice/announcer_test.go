@@ -160,4 +160,48 @@ var _ = Describe("Announcer", func() {
 			}).Should(Equal([]byte(ip)))
 		})
 	})
+
+	Context("Withdraw", func() {
+		var (
+			dopplerKey string
+			legacyKey  string
+		)
+
+		BeforeEach(func() {
+			dopplerKey = fmt.Sprintf("/doppler/meta/%s/%s/%s", conf.Zone, conf.JobName, conf.Index)
+			legacyKey = fmt.Sprintf("/healthstatus/doppler/%s/%s/%s", conf.Zone, conf.JobName, conf.Index)
+		})
+
+		It("deletes the meta and legacy keys", func() {
+			metaStopChan := dopplerservice.Announce(ip, time.Minute, &conf, etcdAdapter)
+			legacyStopChan := dopplerservice.AnnounceLegacy(ip, time.Minute, &conf, etcdAdapter)
+			Eventually(func() error {
+				_, err := etcdAdapter.Get(legacyKey)
+				return err
+			}).ShouldNot(HaveOccurred())
+
+			for _, c := range []chan chan bool{metaStopChan, legacyStopChan} {
+				notify := make(chan bool)
+				Eventually(c).Should(BeSent(notify))
+				Eventually(notify).Should(BeClosed())
+			}
+
+			Expect(dopplerservice.Withdraw(&conf, etcdAdapter)).To(Succeed())
+
+			_, err := etcdAdapter.Get(dopplerKey)
+			Expect(err).To(Equal(storeadapter.ErrorKeyNotFound))
+			_, err = etcdAdapter.Get(legacyKey)
+			Expect(err).To(Equal(storeadapter.ErrorKeyNotFound))
+		})
+
+		It("ignores keys that have already expired", func() {
+			Expect(dopplerservice.Withdraw(&conf, etcdAdapter)).To(Succeed())
+		})
+
+		It("returns an error if the store fails", func() {
+			fakeadapter := &fakes.FakeStoreAdapter{}
+			fakeadapter.DeleteReturns(errors.New("some etcd time out error"))
+			Expect(dopplerservice.Withdraw(&conf, fakeadapter)).ToNot(Succeed())
+		})
+	})
 })
@@ -0,0 +1,137 @@
+// Package health serves /health and /ready endpoints so that an orchestrator
+// can tell a running process apart from one that is connected and serving.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Check reports whether a dependency of a component is ready. It returns an
+// error describing the problem if it is not.
+type Check func() error
+
+// Registry holds the readiness checks registered by a component.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		checks: make(map[string]Check),
+	}
+}
+
+// Register adds a readiness check with the given name. A check registered
+// with an existing name replaces it.
+func (r *Registry) Register(name string, c Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = c
+}
+
+// Handler returns a handler for /health and /ready. /health responds with
+// 200 while the process is serving requests. /ready runs every registered
+// check and responds with 200 if all of them pass and 503 otherwise.
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", r.serveHealth)
+	mux.HandleFunc("/ready", r.serveReady)
+	return mux
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (r *Registry) serveHealth(w http.ResponseWriter, req *http.Request) {
+	writeResponse(w, http.StatusOK, response{Status: "ok"})
+}
+
+func (r *Registry) serveReady(w http.ResponseWriter, req *http.Request) {
+	resp := response{
+		Status: "ready",
+		Checks: make(map[string]string),
+	}
+	code := http.StatusOK
+
+	for _, name := range r.names() {
+		if err := r.check(name)(); err != nil {
+			resp.Checks[name] = err.Error()
+			resp.Status = "not ready"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+
+	writeResponse(w, code, resp)
+}
+
+func (r *Registry) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var names []string
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) check(name string) Check {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.checks[name]
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp response) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Failed to marshal health response: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// Server serves the endpoints of a Registry.
+type Server struct {
+	port     uint32
+	registry *Registry
+}
+
+// NewServer returns a Server that listens on the given port on all
+// interfaces.
+func NewServer(port uint32, r *Registry) *Server {
+	return &Server{
+		port:     port,
+		registry: r,
+	}
+}
+
+// Start listens for health requests. It blocks until the server fails.
+func (s *Server) Start() {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		log.Panicf("Error creating health listener: %s", err)
+	}
+
+	log.Printf("Starting health server on: %s", lis.Addr().String())
+	err = http.Serve(lis, s.registry.Handler())
+	if err != nil {
+		log.Panicf("Error starting health server: %s", err)
+	}
+}
@@ -0,0 +1,90 @@
+package health_test
+
+import (
+	"errors"
+	"health"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *health.Registry
+		recorder *httptest.ResponseRecorder
+	)
+
+	var get = func(path string) {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		registry.Handler().ServeHTTP(recorder, req)
+	}
+
+	BeforeEach(func() {
+		registry = health.NewRegistry()
+		recorder = httptest.NewRecorder()
+	})
+
+	Describe("/health", func() {
+		It("responds with 200 even when checks fail", func() {
+			registry.Register("etcd", func() error {
+				return errors.New("not connected")
+			})
+
+			get("/health")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"status": "ok"}`))
+		})
+	})
+
+	Describe("/ready", func() {
+		It("responds with 200 when no checks are registered", func() {
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"status": "ready"}`))
+		})
+
+		It("responds with 200 when all checks pass", func() {
+			registry.Register("etcd", func() error { return nil })
+			registry.Register("grpc", func() error { return nil })
+
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"status": "ready",
+				"checks": {"etcd": "ok", "grpc": "ok"}
+			}`))
+		})
+
+		It("responds with 503 and the failures when a check fails", func() {
+			registry.Register("etcd", func() error { return nil })
+			registry.Register("grpc", func() error {
+				return errors.New("not listening")
+			})
+
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"status": "not ready",
+				"checks": {"etcd": "ok", "grpc": "not listening"}
+			}`))
+		})
+
+		It("replaces checks registered with the same name", func() {
+			registry.Register("etcd", func() error {
+				return errors.New("not connected")
+			})
+			registry.Register("etcd", func() error { return nil })
+
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,171 @@
+package doppler_test
+
+import (
+	"fmt"
+	"plumbing"
+	v2 "plumbing/v2"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var _ = Describe("Graceful drain", func() {
+	var (
+		conn         *grpc.ClientConn
+		sender       v2.DopplerIngress_SenderClient
+		subscription plumbing.Doppler_SubscribeClient
+	)
+
+	var logEnvelope = func(message string) *v2.Envelope {
+		return &v2.Envelope{
+			SourceId:  "drain-app",
+			Timestamp: time.Now().UnixNano(),
+			Message: &v2.Envelope_Log{
+				Log: &v2.Log{
+					Payload: []byte(message),
+					Type:    v2.Log_OUT,
+				},
+			},
+		}
+	}
+
+	var receiveMessage = func() string {
+		resp, err := subscription.Recv()
+		Expect(err).ToNot(HaveOccurred())
+
+		var env events.Envelope
+		Expect(proto.Unmarshal(resp.Payload, &env)).To(Succeed())
+		return string(env.GetLogMessage().GetMessage())
+	}
+
+	BeforeEach(func() {
+		conf := fetchDopplerConfig("fixtures/doppler.json")
+
+		var egressClient plumbing.DopplerClient
+		conn, egressClient = connectToGRPC(conf)
+		var err error
+		subscription, err = egressClient.Subscribe(
+			context.Background(),
+			&plumbing.SubscriptionRequest{
+				ShardID: "drain-shard",
+				Filter:  &plumbing.Filter{AppID: "drain-app"},
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		sender, err = v2.NewDopplerIngressClient(conn).Sender(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		primed := make(chan struct{})
+		primerDone := make(chan struct{})
+		go func() {
+			defer close(primerDone)
+			for {
+				select {
+				case <-primed:
+					return
+				default:
+				}
+				sender.Send(logEnvelope("primer"))
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		Expect(receiveMessage()).To(Equal("primer"))
+		close(primed)
+		<-primerDone
+	})
+
+	AfterEach(func() {
+		conn.Close()
+	})
+
+	It("withdraws its announcements and keeps serving until Metrons disconnect", func() {
+		dopplerSession.Terminate()
+
+		for _, key := range []string{"/doppler/meta/z1/doppler_z1/0", "/healthstatus/doppler/z1/doppler_z1/0"} {
+			key := key
+			Eventually(func() error {
+				_, err := etcdAdapter.Get(key)
+				return err
+			}).Should(Equal(storeadapter.ErrorKeyNotFound))
+		}
+		Consistently(dopplerSession, time.Second).ShouldNot(gexec.Exit())
+
+		for i := 0; i < 100; i++ {
+			Expect(sender.Send(logEnvelope(fmt.Sprintf("drain-%d", i)))).To(Succeed())
+		}
+
+		var received []string
+		for len(received) < 100 {
+			msg := receiveMessage()
+			if strings.HasPrefix(msg, "drain-") {
+				received = append(received, msg)
+			}
+		}
+		for i, msg := range received {
+			Expect(msg).To(Equal(fmt.Sprintf("drain-%d", i)))
+		}
+
+		Expect(sender.CloseSend()).To(Succeed())
+		Eventually(dopplerSession, 10).Should(gexec.Exit())
+	})
+	It("delivers every envelope sent during the minimum drain", func() {
+		received := make(chan string, 1000)
+		go func() {
+			for {
+				resp, err := subscription.Recv()
+				if err != nil {
+					return
+				}
+
+				var env events.Envelope
+				if proto.Unmarshal(resp.Payload, &env) != nil {
+					continue
+				}
+				if msg := string(env.GetLogMessage().GetMessage()); strings.HasPrefix(msg, "drain-") {
+					received <- msg
+				}
+			}
+		}()
+
+		sent := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			var n int
+			stop := time.After(time.Second)
+			for {
+				select {
+				case <-stop:
+					Expect(sender.CloseSend()).To(Succeed())
+					sent <- n
+					return
+				default:
+				}
+				Expect(sender.Send(logEnvelope(fmt.Sprintf("drain-%d", n)))).To(Succeed())
+				n++
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+		dopplerSession.Terminate()
+
+		var n int
+		Eventually(sent, 5).Should(Receive(&n))
+		Expect(n).ToNot(BeZero())
+
+		for i := 0; i < n; i++ {
+			var msg string
+			Eventually(received, 5).Should(Receive(&msg))
+			Expect(msg).To(Equal(fmt.Sprintf("drain-%d", i)))
+		}
+
+		Eventually(dopplerSession, 10).Should(gexec.Exit())
+	})
+})
@@ -1,19 +1,94 @@
 package trafficcontroller_test
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
+	"sync/atomic"
 )
 
+const fakeUaaKeyID = "fake-uaa-key"
+
 type FakeUaaHandler struct {
+	signingKey      *rsa.PrivateKey
+	checkTokenCalls int64
+}
+
+func NewFakeUaaHandler() *FakeUaaHandler {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return &FakeUaaHandler{
+		signingKey: key,
+	}
 }
 
 func (h *FakeUaaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/check_token" {
+	switch r.URL.Path {
+	case "/check_token":
+		atomic.AddInt64(&h.checkTokenCalls, 1)
+		h.checkToken(w, r)
+	case "/token_keys":
+		h.tokenKeys(w, r)
+	default:
 		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *FakeUaaHandler) CheckTokenCalls() int64 {
+	return atomic.LoadInt64(&h.checkTokenCalls)
+}
+
+func (h *FakeUaaHandler) SignToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"kid": fakeUaaKeyID,
+		"typ": "JWT",
+	})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, h.signingKey, crypto.SHA256, hash[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (h *FakeUaaHandler) tokenKeys(w http.ResponseWriter, r *http.Request) {
+	der, err := x509.MarshalPKIXPublicKey(&h.signingKey.PublicKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	keys := map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kid":   fakeUaaKeyID,
+				"kty":   "RSA",
+				"alg":   "RS256",
+				"use":   "sig",
+				"value": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			},
+		},
+	}
+
+	marshaled, _ := json.Marshal(keys)
+	w.Write(marshaled)
+}
+
+func (h *FakeUaaHandler) checkToken(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Authorization") != "Basic Ym9iOnlvdXJVbmNsZQ==" {
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("{\"error\":\"unauthorized\",\"error_description\":\"No client with requested id: wrongUser\"}"))
@@ -0,0 +1,81 @@
+package trafficcontroller_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("TrafficController graceful shutdown", func() {
+	var streamEndpoint string
+
+	BeforeEach(func() {
+		fakeDoppler = NewFakeDoppler()
+		go fakeDoppler.Start()
+		streamEndpoint = fmt.Sprintf("ws://%s:%d/apps/%s/stream", localIPAddress, TRAFFIC_CONTROLLER_DROPSONDE_PORT, APP_ID)
+	})
+
+	AfterEach(func() {
+		fakeDoppler.Stop()
+	})
+
+	It("delivers pending messages and closes open streams with a going away frame before exiting", func() {
+		conn, _, err := websocket.DefaultDialer.Dial(
+			streamEndpoint,
+			http.Header{"Authorization": []string{AUTH_TOKEN}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Eventually(fakeDoppler.SubscriptionRequests, 10).Should(Receive())
+
+		fakeDoppler.SendLogMessage(makeDropsondeMessage("before shutdown", APP_ID, time.Now().UnixNano()))
+		_, _, err = conn.ReadMessage()
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 10; i++ {
+			fakeDoppler.SendLogMessage(makeDropsondeMessage(fmt.Sprintf("during shutdown %d", i), APP_ID, time.Now().UnixNano()))
+		}
+		Eventually(func() int { return len(fakeDoppler.grpcOut) }).Should(BeZero())
+		trafficControllerSession.Terminate()
+
+		for i := 0; i < 10; i++ {
+			_, msg, err := conn.ReadMessage()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg).To(ContainSubstring(fmt.Sprintf("during shutdown %d", i)))
+		}
+
+		_, _, err = conn.ReadMessage()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("websocket: close 1001"))
+
+		Eventually(trafficControllerSession, 10).Should(gexec.Exit())
+	})
+
+	It("stops accepting new connections once shutdown starts", func() {
+		conn, _, err := websocket.DefaultDialer.Dial(
+			streamEndpoint,
+			http.Header{"Authorization": []string{AUTH_TOKEN}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Eventually(fakeDoppler.SubscriptionRequests, 10).Should(Receive())
+
+		trafficControllerSession.Terminate()
+
+		Eventually(func() error {
+			newConn, _, err := websocket.DefaultDialer.Dial(
+				streamEndpoint,
+				http.Header{"Authorization": []string{AUTH_TOKEN}},
+			)
+			if err == nil {
+				newConn.Close()
+			}
+			return err
+		}, 5).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,94 @@
+package trafficcontroller_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"plumbing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/noaa/consumer"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+var _ = Describe("TrafficController with local token validation", func() {
+	var dropsondeEndpoint string
+
+	BeforeEach(func() {
+		configFile = "fixtures/trafficcontroller_local_token_validation.json"
+
+		fakeDoppler = NewFakeDoppler()
+		go fakeDoppler.Start()
+		dropsondeEndpoint = fmt.Sprintf("ws://%s:%d", localIPAddress, TRAFFIC_CONTROLLER_DROPSONDE_PORT)
+	})
+
+	AfterEach(func() {
+		fakeDoppler.Stop()
+	})
+
+	It("allows firehose access for a signed token without calling check_token", func() {
+		token := fakeUaaServer.SignToken(map[string]interface{}{
+			"scope": []string{"doppler.firehose"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		checkTokenCalls := fakeUaaServer.CheckTokenCalls()
+
+		client := consumer.New(dropsondeEndpoint, &tls.Config{}, nil)
+		defer client.Close()
+		messages, errors := client.FirehoseWithoutReconnect(SUBSCRIPTION_ID, "bearer "+token)
+
+		var grpcRequest *plumbing.SubscriptionRequest
+		Eventually(fakeDoppler.SubscriptionRequests, 10).Should(Receive(&grpcRequest))
+		Expect(grpcRequest.ShardID).To(Equal(SUBSCRIPTION_ID))
+
+		fakeDoppler.SendLogMessage(makeDropsondeMessage("Hello through NOAA", APP_ID, time.Now().UnixNano()))
+
+		var receivedEnvelope *events.Envelope
+		Eventually(messages).Should(Receive(&receivedEnvelope))
+		Consistently(errors).ShouldNot(Receive())
+		Expect(receivedEnvelope.GetLogMessage().GetMessage()).To(BeEquivalentTo("Hello through NOAA"))
+
+		Expect(fakeUaaServer.CheckTokenCalls()).To(Equal(checkTokenCalls))
+	})
+
+	It("rejects a signed token without the doppler.firehose scope", func() {
+		token := fakeUaaServer.SignToken(map[string]interface{}{
+			"scope": []string{"uaa.not-admin"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+
+		client := consumer.New(dropsondeEndpoint, &tls.Config{}, nil)
+		defer client.Close()
+		_, errors := client.FirehoseWithoutReconnect(SUBSCRIPTION_ID, "bearer "+token)
+
+		Eventually(errors, 5).Should(Receive())
+		Consistently(fakeDoppler.SubscriptionRequests).ShouldNot(Receive())
+	})
+
+	It("rejects an expired token", func() {
+		token := fakeUaaServer.SignToken(map[string]interface{}{
+			"scope": []string{"doppler.firehose"},
+			"exp":   time.Now().Add(-time.Minute).Unix(),
+		})
+
+		client := consumer.New(dropsondeEndpoint, &tls.Config{}, nil)
+		defer client.Close()
+		_, errors := client.FirehoseWithoutReconnect(SUBSCRIPTION_ID, "bearer "+token)
+
+		Eventually(errors, 5).Should(Receive())
+		Consistently(fakeDoppler.SubscriptionRequests).ShouldNot(Receive())
+	})
+
+	It("falls back to check_token for opaque tokens", func() {
+		checkTokenCalls := fakeUaaServer.CheckTokenCalls()
+
+		client := consumer.New(dropsondeEndpoint, &tls.Config{}, nil)
+		defer client.Close()
+		client.FirehoseWithoutReconnect(SUBSCRIPTION_ID, AUTH_TOKEN)
+
+		Eventually(fakeDoppler.SubscriptionRequests, 10).Should(Receive())
+		Expect(fakeUaaServer.CheckTokenCalls()).To(BeNumerically(">", checkTokenCalls))
+	})
+})
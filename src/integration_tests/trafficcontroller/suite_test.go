@@ -32,6 +32,7 @@ var (
 	etcdPort                  int
 	localIPAddress            string
 	fakeDoppler               *FakeDoppler
+	fakeUaaServer             *FakeUaaHandler
 	configFile                string
 )
 
@@ -121,7 +122,7 @@ var setupFakeAuthServer = func() {
 }
 
 var setupFakeUaaServer = func() {
-	fakeUaaServer := &FakeUaaHandler{}
+	fakeUaaServer = NewFakeUaaHandler()
 	go http.ListenAndServe(":5678", fakeUaaServer)
 	Eventually(func() error {
 		_, err := http.Get("http://" + localIPAddress + ":5678/check_token")
@@ -1,12 +1,18 @@
 package metric
 
 import (
+	"diodes"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"time"
 
 	v2 "plumbing/v2"
 )
 
+// IncrementOpt configures a counter, gauge or timer. WithIncrement only
+// applies to counters.
 type IncrementOpt func(*incrementOption)
 
 type incrementOption struct {
@@ -37,6 +43,72 @@ func IncCounter(name string, options ...IncrementOpt) {
 		return
 	}
 
+	incConf := applyOptions(options)
+	conf.registry.AddCounter(name, float64(incConf.delta), incConf.tags)
+
+	e := newEnvelope(incConf.tags)
+	e.Message = &v2.Envelope_Counter{
+		Counter: &v2.Counter{
+			Name: name,
+			Value: &v2.Counter_Delta{
+				Delta: incConf.delta,
+			},
+		},
+	}
+
+	batchBuffer.Set(e)
+}
+
+// SetGauge sets the gauge with the given name. Only the latest value set
+// within a batch interval is sent.
+func SetGauge(name string, value float64, unit string, options ...IncrementOpt) {
+	if batchBuffer == nil {
+		return
+	}
+
+	incConf := applyOptions(options)
+	conf.registry.SetGauge(name, value, incConf.tags)
+
+	e := newEnvelope(incConf.tags)
+	e.Message = &v2.Envelope_Gauge{
+		Gauge: &v2.Gauge{
+			Metrics: map[string]*v2.GaugeValue{
+				name: {
+					Unit:  unit,
+					Value: value,
+				},
+			},
+		},
+	}
+
+	batchBuffer.Set(e)
+}
+
+// RecordTimer records a timer with the given name that started at start and
+// stopped at stop. Every timer is sent individually so that consumers can
+// build a latency distribution.
+func RecordTimer(name string, start, stop time.Time, options ...IncrementOpt) {
+	if batchBuffer == nil {
+		return
+	}
+
+	incConf := applyOptions(options)
+	conf.registry.AddCounter(name+"_seconds_sum", stop.Sub(start).Seconds(), incConf.tags)
+	conf.registry.AddCounter(name+"_seconds_count", 1, incConf.tags)
+
+	e := newEnvelope(incConf.tags)
+	e.Message = &v2.Envelope_Timer{
+		Timer: &v2.Timer{
+			Name:  name,
+			Start: start.UnixNano(),
+			Stop:  stop.UnixNano(),
+		},
+	}
+
+	batchBuffer.Set(e)
+}
+
+func applyOptions(options []IncrementOpt) *incrementOption {
 	incConf := &incrementOption{
 		delta: 1,
 		tags:  make(map[string]string),
@@ -45,9 +117,12 @@ func IncCounter(name string, options ...IncrementOpt) {
 	for _, opt := range options {
 		opt(incConf)
 	}
+	return incConf
+}
 
+func newEnvelope(metricTags map[string]string) *v2.Envelope {
 	tags := make(map[string]*v2.Value)
-	for k, v := range incConf.tags {
+	for k, v := range metricTags {
 		tags[k] = &v2.Value{
 			Data: &v2.Value_Text{
 				Text: v,
@@ -63,21 +138,11 @@ func IncCounter(name string, options ...IncrementOpt) {
 		}
 	}
 
-	e := &v2.Envelope{
+	return &v2.Envelope{
 		SourceId:  conf.sourceUUID,
 		Timestamp: time.Now().UnixNano(),
-		Message: &v2.Envelope_Counter{
-			Counter: &v2.Counter{
-				Name: name,
-				Value: &v2.Counter_Delta{
-					Delta: incConf.delta,
-				},
-			},
-		},
-		Tags: tags,
+		Tags:      tags,
 	}
-
-	batchBuffer.Set(e)
 }
 
 func runBatcher() {
@@ -93,29 +158,99 @@ func runBatcher() {
 			continue
 		}
 
-		for _, e := range aggregateCounters() {
-			s.Send(e)
-		}
+		flush(s)
+	}
+}
+
+// Flush sends everything batched since the last batch interval. It should
+// be called before a process exits so that the last batch is not lost.
+func Flush() {
+	mu.Lock()
+	s := sender
+	mu.Unlock()
+
+	if s == nil || batchBuffer == nil {
+		return
 	}
+
+	flush(s)
 }
 
-func aggregateCounters() map[string]*v2.Envelope {
-	m := make(map[string]*v2.Envelope)
+func flush(s v2.Ingress_SenderClient) {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+
+	for _, e := range aggregate(batchBuffer, conf.maxSeries) {
+		s.Send(e)
+	}
+}
+
+// aggregate drains the batch buffer. Counters with the same name and tags
+// are summed and only the latest value of each gauge with the same name and
+// tags is kept. Timers are passed through. Counters and gauges for series
+// beyond maxSeries are dropped.
+func aggregate(buf *diodes.ManyToOneEnvelopeV2, maxSeries int) []*v2.Envelope {
+	series := make(map[string]*v2.Envelope)
+	var (
+		envelopes []*v2.Envelope
+		dropped   int
+	)
 	for {
-		envelope, ok := batchBuffer.TryNext()
+		envelope, ok := buf.TryNext()
 		if !ok {
 			break
 		}
 
-		existingEnvelope, ok := m[envelope.GetCounter().Name]
-		if !ok {
-			existingEnvelope = envelope
-			m[envelope.GetCounter().Name] = existingEnvelope
+		var key string
+		switch envelope.GetMessage().(type) {
+		case *v2.Envelope_Counter:
+			key = seriesKey("counter", []string{envelope.GetCounter().GetName()}, envelope.GetTags())
+		case *v2.Envelope_Gauge:
+			names := make([]string, 0, len(envelope.GetGauge().GetMetrics()))
+			for name := range envelope.GetGauge().GetMetrics() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			key = seriesKey("gauge", names, envelope.GetTags())
+		default:
+			envelopes = append(envelopes, envelope)
+			continue
+		}
+
+		existing, ok := series[key]
+		if !ok && len(series) >= maxSeries {
+			dropped++
 			continue
 		}
 
-		existingEnvelope.GetCounter().GetValue().(*v2.Counter_Delta).Delta += envelope.GetCounter().GetDelta()
+		if ok && existing.GetCounter() != nil {
+			existing.GetCounter().GetValue().(*v2.Counter_Delta).Delta += envelope.GetCounter().GetDelta()
+			continue
+		}
+		series[key] = envelope
 	}
 
-	return m
+	if dropped > 0 {
+		log.Printf("dropped %d metrics exceeding the limit of %d series per batch", dropped, maxSeries)
+	}
+
+	for _, e := range series {
+		envelopes = append(envelopes, e)
+	}
+	return envelopes
+}
+
+// seriesKey identifies a metric by its type, names and tags.
+func seriesKey(kind string, names []string, tags map[string]*v2.Value) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{kind, strings.Join(names, "|")}
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k].GetText())
+	}
+	return strings.Join(parts, ",")
 }
@@ -5,6 +5,10 @@ import (
 	"math/rand"
 	"metric"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"prometheus"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
@@ -20,17 +24,21 @@ var _ = Describe("Metric", func() {
 	var (
 		mockConsumer *mockIngressServer
 		receiver     <-chan *v2.Envelope
+		registry     *prometheus.Registry
 	)
 
 	BeforeSuite(func() {
 		var addr string
 		addr, mockConsumer = startConsumer()
+		registry = prometheus.NewRegistry("")
 		metric.Setup(
 			metric.WithAddr(addr),
 			metric.WithSourceUUID("some-uuid"),
 			metric.WithBatchInterval(250*time.Millisecond),
 			metric.WithOrigin("loggregator.metron"),
 			metric.WithDeploymentMeta("some-deployment", "some-job", "some-index"),
+			metric.WithPrometheus(registry),
+			metric.WithMaxSeries(100),
 		)
 
 		// Seed the data
@@ -59,6 +67,10 @@ var _ = Describe("Metric", func() {
 
 		BeforeEach(func() {
 			randName = generateRandName()
+
+			// Send whatever previous tests left in the batch so every test
+			// starts with an empty batch.
+			metric.Flush()
 		})
 
 		Describe("IncCounter()", func() {
@@ -161,6 +173,186 @@ var _ = Describe("Metric", func() {
 				Expect(e.Tags["job"].GetText()).To(Equal("some-job"))
 				Expect(e.Tags["index"].GetText()).To(Equal("some-index"))
 			})
+
+			It("aggregates counters by name and tags", func() {
+				metric.IncCounter(randName, metric.WithTag("direction", "ingress"), metric.WithIncrement(2))
+				metric.IncCounter(randName, metric.WithTag("direction", "egress"))
+				metric.IncCounter(randName, metric.WithTag("direction", "ingress"), metric.WithIncrement(3))
+
+				deltas := make(map[string]uint64)
+				f := func() int {
+					var e *v2.Envelope
+					Eventually(receiver).Should(Receive(&e))
+
+					if e.GetCounter().GetName() == randName {
+						deltas[e.GetTags()["direction"].GetText()] += e.GetCounter().GetDelta()
+					}
+					return len(deltas)
+				}
+
+				Eventually(f).Should(Equal(2))
+				Expect(deltas).To(Equal(map[string]uint64{"ingress": 5, "egress": 1}))
+			})
+
+			It("drops series beyond the maximum per batch", func() {
+				for i := 0; i < 150; i++ {
+					metric.IncCounter(fmt.Sprintf("%s-%d", randName, i))
+				}
+				metric.Flush()
+
+				var received int
+				f := func() int {
+					select {
+					case e := <-receiver:
+						if strings.HasPrefix(e.GetCounter().GetName(), randName) {
+							received++
+						}
+					default:
+					}
+					return received
+				}
+
+				Eventually(f).Should(Equal(100))
+				Consistently(f).Should(Equal(100))
+			})
+
+			It("keeps counters and gauges with the same name separate", func() {
+				metric.IncCounter(randName, metric.WithIncrement(2))
+				metric.SetGauge(randName, 3, "sinks")
+				metric.IncCounter(randName, metric.WithIncrement(4))
+				metric.Flush()
+
+				var (
+					delta uint64
+					gauge float64
+				)
+				f := func() bool {
+					var e *v2.Envelope
+					Eventually(receiver).Should(Receive(&e))
+
+					if e.GetCounter().GetName() == randName {
+						delta += e.GetCounter().GetDelta()
+					}
+					if v, ok := e.GetGauge().GetMetrics()[randName]; ok {
+						gauge = v.GetValue()
+					}
+					return delta == 6 && gauge != 0
+				}
+
+				Eventually(f).Should(BeTrue())
+				Expect(delta).To(Equal(uint64(6)))
+				Expect(gauge).To(Equal(3.0))
+			})
+
+			It("sends batched counters when flushed", func() {
+				metric.IncCounter(randName)
+				metric.Flush()
+
+				var e *v2.Envelope
+				f := func() bool {
+					Eventually(receiver).Should(Receive(&e))
+					return e.GetCounter().GetName() == randName
+				}
+
+				Eventually(f).Should(BeTrue())
+			})
+
+			It("records the counter in the prometheus registry", func() {
+				metric.IncCounter(randName, metric.WithIncrement(3), metric.WithTag("name", "value"))
+				metric.IncCounter(randName, metric.WithTag("name", "value"))
+
+				req, err := http.NewRequest("GET", "/metrics", nil)
+				Expect(err).ToNot(HaveOccurred())
+				recorder := httptest.NewRecorder()
+				registry.ServeHTTP(recorder, req)
+
+				Expect(recorder.Body.String()).To(ContainSubstring(
+					fmt.Sprintf(`%s{name="value"} 4`, strings.Replace(randName, "-", "_", -1)),
+				))
+			})
+		})
+
+		Describe("SetGauge()", func() {
+			It("writes the latest gauge value periodically to the consumer", func() {
+				metric.SetGauge(randName, 1, "connections")
+				metric.SetGauge(randName, 2, "connections")
+
+				var e *v2.Envelope
+				f := func() bool {
+					Eventually(receiver).Should(Receive(&e))
+
+					v, ok := e.GetGauge().GetMetrics()[randName]
+					return ok && v.GetValue() == 2
+				}
+
+				Eventually(f).Should(BeTrue())
+				Expect(e.GetGauge().GetMetrics()[randName].GetUnit()).To(Equal("connections"))
+			})
+
+			It("writes the latest gauge value to the consumer when flushed", func() {
+				metric.SetGauge(randName, 1, "connections", metric.WithVersion(2, 0))
+				metric.SetGauge(randName, 2, "connections", metric.WithVersion(2, 0))
+				metric.Flush()
+
+				var e *v2.Envelope
+				f := func() bool {
+					Eventually(receiver).Should(Receive(&e))
+
+					_, ok := e.GetGauge().GetMetrics()[randName]
+					return ok
+				}
+
+				Eventually(f).Should(BeTrue())
+				Expect(e.SourceId).To(Equal("some-uuid"))
+				Expect(e.GetGauge().GetMetrics()[randName].GetValue()).To(Equal(2.0))
+				Expect(e.GetGauge().GetMetrics()[randName].GetUnit()).To(Equal("connections"))
+				Expect(e.GetTags()["metric_version"].GetText()).To(Equal("2.0"))
+				Expect(e.GetTags()["origin"].GetText()).To(Equal("loggregator.metron"))
+			})
+
+			It("keeps gauges with different tags separate", func() {
+				metric.SetGauge(randName, 1, "sinks", metric.WithTag("type", "dump"))
+				metric.SetGauge(randName, 2, "sinks", metric.WithTag("type", "syslog"))
+
+				values := make(map[string]float64)
+				f := func() int {
+					var e *v2.Envelope
+					Eventually(receiver).Should(Receive(&e))
+
+					if v, ok := e.GetGauge().GetMetrics()[randName]; ok {
+						values[e.GetTags()["type"].GetText()] = v.GetValue()
+					}
+					return len(values)
+				}
+
+				Eventually(f).Should(Equal(2))
+				Expect(values).To(Equal(map[string]float64{"dump": 1, "syslog": 2}))
+			})
+		})
+
+		Describe("RecordTimer()", func() {
+			It("writes every timer to the consumer", func() {
+				start := time.Unix(0, 1000)
+				metric.RecordTimer(randName, start, start.Add(time.Second), metric.WithTag("name", "value"))
+				metric.RecordTimer(randName, start, start.Add(2*time.Second), metric.WithTag("name", "value"))
+
+				var timers []*v2.Timer
+				f := func() int {
+					var e *v2.Envelope
+					Eventually(receiver).Should(Receive(&e))
+
+					if e.GetTimer().GetName() == randName {
+						Expect(e.GetTags()["name"].GetText()).To(Equal("value"))
+						timers = append(timers, e.GetTimer())
+					}
+					return len(timers)
+				}
+
+				Eventually(f).Should(Equal(2))
+				Expect(timers[0].GetStart()).To(Equal(int64(1000)))
+				Expect(timers[0].GetStop()).To(Equal(int64(1000 + time.Second)))
+				Expect(timers[1].GetStop()).To(Equal(int64(1000 + 2*time.Second)))
+			})
 		})
 	})
 })
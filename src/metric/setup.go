@@ -4,6 +4,7 @@ import (
 	"context"
 	"diodes"
 	"log"
+	"prometheus"
 	"sync"
 	"time"
 
@@ -15,7 +16,8 @@ import (
 )
 
 var (
-	mu sync.Mutex
+	mu      sync.Mutex
+	flushMu sync.Mutex
 
 	client      v2.IngressClient
 	sender      v2.Ingress_SenderClient
@@ -28,7 +30,9 @@ type config struct {
 	dialOpts      []grpc.DialOption
 	sourceUUID    string
 	batchInterval time.Duration
+	maxSeries     int
 	tags          map[string]string
+	registry      *prometheus.Registry
 }
 
 type SetOpts func(c *config)
@@ -57,12 +61,29 @@ func WithBatchInterval(interval time.Duration) func(c *config) {
 	}
 }
 
+// WithMaxSeries limits the number of distinct counters and gauges, by name
+// and tags, that are sent per batch interval. Metrics for further series are
+// dropped.
+func WithMaxSeries(n int) func(c *config) {
+	return func(c *config) {
+		c.maxSeries = n
+	}
+}
+
 func WithOrigin(name string) func(c *config) {
 	return func(c *config) {
 		c.tags["origin"] = name
 	}
 }
 
+// WithPrometheus records every counter in the given registry in addition to
+// sending it to the consumer.
+func WithPrometheus(r *prometheus.Registry) func(c *config) {
+	return func(c *config) {
+		c.registry = r
+	}
+}
+
 func WithDeploymentMeta(deployment, job, index string) func(c *config) {
 	return func(c *config) {
 		c.tags["deployment"] = deployment
@@ -79,6 +100,7 @@ func Setup(opts ...SetOpts) {
 		consumerAddr:  "localhost:3458",
 		dialOpts:      []grpc.DialOption{grpc.WithInsecure()},
 		batchInterval: 10 * time.Second,
+		maxSeries:     250,
 		tags:          map[string]string{"prefix": "loggregator"},
 	}
 
@@ -3,7 +3,9 @@ package app
 import (
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry/dropsonde/metric_sender"
@@ -16,16 +18,35 @@ import (
 	"metron/internal/clientpool/legacy"
 	clientpool "metron/internal/clientpool/v1"
 	egress "metron/internal/egress/v1"
+	"metron/internal/enrichment"
 	ingress "metron/internal/ingress/v1"
+	"metron/internal/multiline"
+	"metron/internal/rollup"
+	"prometheus"
 )
 
 type AppV1 struct {
-	config *Config
-	creds  credentials.TransportCredentials
+	config       *Config
+	creds        credentials.TransportCredentials
+	promRegistry *prometheus.Registry
+	enricher     *enrichment.Enricher
+
+	mu           sync.RWMutex
+	connManagers []*clientpool.ConnManager
+
+	rebalanceMu      sync.Mutex
+	rebalancePending bool
 }
 
-func NewV1App(c *Config, creds credentials.TransportCredentials) *AppV1 {
-	return &AppV1{config: c, creds: creds}
+// NewV1App returns an AppV1. The enricher adds tags to envelopes and may be
+// nil.
+func NewV1App(
+	c *Config,
+	creds credentials.TransportCredentials,
+	promRegistry *prometheus.Registry,
+	enricher *enrichment.Enricher,
+) *AppV1 {
+	return &AppV1{config: c, creds: creds, promRegistry: promRegistry, enricher: enricher}
 }
 
 func (a *AppV1) Start() {
@@ -49,7 +70,18 @@ func (a *AppV1) Start() {
 	aggregator := egress.NewAggregator(messageTagger)
 	eventWriter.SetWriter(aggregator)
 
-	dropsondeUnmarshaller := ingress.NewUnMarshaller(aggregator, batcher)
+	var writer ingress.EnvelopeWriter = aggregator
+	if a.enricher != nil {
+		writer = enrichment.NewV1Writer(aggregator, a.enricher)
+	}
+	if a.config.Rollup.Enabled {
+		writer = a.startRollup(writer)
+	}
+	if a.config.Multiline.Enabled {
+		writer = multiline.NewV1Reassembler(writer, a.config.Multiline.config())
+	}
+
+	dropsondeUnmarshaller := ingress.NewUnMarshaller(writer, batcher)
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", a.config.IncomingUDPPort)
 	networkReader, err := ingress.New(metronAddress, "dropsondeAgentListener", dropsondeUnmarshaller)
 	if err != nil {
@@ -61,13 +93,42 @@ func (a *AppV1) Start() {
 	networkReader.StartWriting()
 }
 
+// startRollup returns a writer that aggregates HttpStartStop events before
+// writing to w. The rolled up metrics are written to w as v1 events.
+func (a *AppV1) startRollup(w rollup.EnvelopeWriter) *rollup.V1Writer {
+	c := a.config.Rollup
+	r := rollup.New(
+		rollup.NewV1Setter(w, "MetronAgent"),
+		time.Duration(c.IntervalMilliseconds)*time.Millisecond,
+		c.MaxSeries,
+		false,
+	)
+	go r.Start()
+	return rollup.NewV1Writer(w, r, c.PassThrough)
+}
+
+// ConnectedDopplers returns the number of gRPC connections to Dopplers.
+func (a *AppV1) ConnectedDopplers() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var connected int
+	for _, m := range a.connManagers {
+		if m.Connected() {
+			connected++
+		}
+	}
+	return connected
+}
+
 func (a *AppV1) initializeMetrics(stopChan chan struct{}) (*metricbatcher.MetricBatcher, *egress.EventWriter) {
 	eventWriter := egress.New("MetronAgent")
-	metricSender := metric_sender.NewMetricSender(eventWriter)
+	metricEmitter := prometheus.NewEmitter(a.promRegistry, eventWriter)
+	metricSender := metric_sender.NewMetricSender(metricEmitter)
 	metricBatcher := metricbatcher.New(metricSender, time.Duration(a.config.MetricBatchIntervalMilliseconds)*time.Millisecond)
 	metrics.Initialize(metricSender, metricBatcher)
 
-	stats := runtime_stats.NewRuntimeStats(eventWriter, time.Duration(a.config.RuntimeStatsIntervalMilliseconds)*time.Millisecond)
+	stats := runtime_stats.NewRuntimeStats(metricEmitter, time.Duration(a.config.RuntimeStatsIntervalMilliseconds)*time.Millisecond)
 	go stats.Run(stopChan)
 	return metricBatcher, eventWriter
 }
@@ -93,9 +154,21 @@ func (a *AppV1) setupGRPC() []legacy.Pool {
 		return nil
 	}
 
+	balancerOpts := []clientpool.BalancerOption{
+		clientpool.WithRefreshInterval(
+			time.Duration(a.config.DopplerRefreshIntervalSeconds) * time.Second,
+		),
+		clientpool.WithOnChange(a.rebalance),
+	}
 	balancers := []*clientpool.Balancer{
-		clientpool.NewBalancer(fmt.Sprintf("%s.%s", a.config.Zone, a.config.DopplerAddr)),
-		clientpool.NewBalancer(a.config.DopplerAddr),
+		clientpool.NewBalancer(fmt.Sprintf("%s.%s", a.config.Zone, a.config.DopplerAddr), balancerOpts...),
+		clientpool.NewBalancer(a.config.DopplerAddr, balancerOpts...),
+	}
+
+	if a.config.DopplerRefreshIntervalSeconds > 0 {
+		for _, b := range balancers {
+			go b.Start()
+		}
 	}
 
 	fetcher := clientpool.NewPusherFetcher(
@@ -104,16 +177,64 @@ func (a *AppV1) setupGRPC() []legacy.Pool {
 
 	connector := clientpool.MakeGRPCConnector(fetcher, balancers)
 
+	// Connections are recycled after a number of writes to spread them
+	// across Dopplers. Refreshing the addresses rebalances the connections
+	// whenever the Dopplers change so they are not recycled otherwise.
+	maxWrites := func() int64 { return 10000 + rand.Int63n(1000) }
+	if a.config.DopplerRefreshIntervalSeconds > 0 {
+		maxWrites = func() int64 { return math.MaxInt64 }
+	}
+
 	var connManagers []clientpool.Conn
+	a.mu.Lock()
 	for i := 0; i < 5; i++ {
-		connManagers = append(connManagers, clientpool.NewConnManager(
+		m := clientpool.NewConnManager(
 			connector,
-			10000+rand.Int63n(1000),
+			maxWrites(),
 			time.Second,
-		))
+		)
+		a.connManagers = append(a.connManagers, m)
+		connManagers = append(connManagers, m)
 	}
+	a.mu.Unlock()
 
 	pool := clientpool.New(connManagers...)
 	grpcWrapper := egress.NewGRPCWrapper(pool)
 	return []legacy.Pool{grpcWrapper}
 }
+
+// rebalance schedules recycling every Doppler connection unless it is
+// already scheduled.
+func (a *AppV1) rebalance() {
+	a.rebalanceMu.Lock()
+	defer a.rebalanceMu.Unlock()
+
+	if a.rebalancePending {
+		return
+	}
+	a.rebalancePending = true
+	time.AfterFunc(rebalanceDelay, a.recycleConns)
+}
+
+// recycleConns recycles every Doppler connection so that they are spread
+// across the current set of Dopplers. Connections are recycled one at a
+// time to avoid dropping every connection at once.
+func (a *AppV1) recycleConns() {
+	a.rebalanceMu.Lock()
+	a.rebalancePending = false
+	a.rebalanceMu.Unlock()
+
+	a.mu.RLock()
+	connManagers := make([]*clientpool.ConnManager, len(a.connManagers))
+	copy(connManagers, a.connManagers)
+	a.mu.RUnlock()
+
+	// metric-documentation-v1: (dopplerConnector.rebalances) Number of times
+	// the v1 Doppler connections were rebalanced after the set of Doppler
+	// addresses changed
+	metrics.BatchIncrementCounter("dopplerConnector.rebalances")
+
+	for i, m := range connManagers {
+		time.AfterFunc(time.Duration(i)*time.Second, m.Recycle)
+	}
+}
@@ -1,18 +1,32 @@
 package app
 
 import (
+	"crypto/tls"
 	"diodes"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"metric"
+	"net"
+	"net/http"
+	"plumbing"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	gendiodes "github.com/cloudfoundry/diodes"
 
 	clientpool "metron/internal/clientpool/v2"
+	"metron/internal/diskbuffer"
 	egress "metron/internal/egress/v2"
+	"metron/internal/enrichment"
+	"metron/internal/ingress/statsd"
+	"metron/internal/ingress/syslog"
 	ingress "metron/internal/ingress/v2"
+	"metron/internal/multiline"
+	"metron/internal/rollup"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -22,17 +36,33 @@ type AppV2 struct {
 	config      *Config
 	clientCreds credentials.TransportCredentials
 	serverCreds credentials.TransportCredentials
+	enricher    *enrichment.Enricher
+
+	mu           sync.RWMutex
+	connManagers []*clientpool.ConnManager
+
+	rebalanceMu      sync.Mutex
+	rebalancePending bool
 }
 
+// rebalanceDelay is how long a rebalance waits so that changes reported
+// together, e.g. by the zone and the global balancer for the same DNS
+// change, recycle the connections once.
+const rebalanceDelay = 5 * time.Second
+
+// NewV2App returns an AppV2. The enricher adds tags to envelopes and may be
+// nil.
 func NewV2App(
 	c *Config,
 	clientCreds credentials.TransportCredentials,
 	serverCreds credentials.TransportCredentials,
+	enricher *enrichment.Enricher,
 ) *AppV2 {
 	return &AppV2{
 		config:      c,
 		clientCreds: clientCreds,
 		serverCreds: serverCreds,
+		enricher:    enricher,
 	}
 }
 
@@ -41,27 +71,193 @@ func (a *AppV2) Start() {
 		log.Panic("Failed to load TLS server config")
 	}
 
-	envelopeBuffer := diodes.NewManyToOneEnvelopeV2(10000, gendiodes.AlertFunc(func(missed int) {
+	queues := a.config.Queues
+	logBuffer := newEnvelopeBuffer("log", queues.Log.Size)
+	counterBuffer := newEnvelopeBuffer("counter", queues.Counter.Size)
+	gaugeBuffer := newEnvelopeBuffer("gauge", queues.Gauge.Size)
+	timerBuffer := newEnvelopeBuffer("timer", queues.Timer.Size)
+	envelopeBuffer := ingress.NewClassSetter(logBuffer, counterBuffer, gaugeBuffer, timerBuffer)
+
+	var writer egress.Writer = a.initializePool()
+	if a.config.DiskBuffer.Enabled {
+		writer = a.initializeDiskBuffer(writer)
+	}
+	counterAggr := egress.New(writer)
+	nexter := egress.NewWeightedNexter(
+		egress.WeightedQueue{Queue: logBuffer, Weight: queues.Log.Weight},
+		egress.WeightedQueue{Queue: counterBuffer, Weight: queues.Counter.Weight},
+		egress.WeightedQueue{Queue: gaugeBuffer, Weight: queues.Gauge.Weight},
+		egress.WeightedQueue{Queue: timerBuffer, Weight: queues.Timer.Weight},
+	)
+	tx := egress.NewTransponder(nexter, counterAggr, a.config.Tags)
+	go tx.Start()
+
+	metronAddress := net.JoinHostPort(a.config.GRPC.Host, strconv.Itoa(int(a.config.GRPC.Port)))
+	log.Printf("metron v2 API started on addr %s", metronAddress)
+	var base ingress.DataSetter = envelopeBuffer
+	if a.enricher != nil {
+		base = enrichment.NewV2Setter(envelopeBuffer, a.enricher)
+	}
+
+	setter := base
+	if a.config.Rollup.Enabled {
+		setter = a.startRollup(base)
+	}
+	if a.config.Multiline.Enabled {
+		setter = multiline.NewV2Reassembler(setter, a.config.Multiline.config())
+	}
+
+	a.startSyslog(setter)
+	a.startStatsd(base)
+	a.startHTTP(setter)
+
+	rx := ingress.NewReceiver(setter)
+	opts := []grpc.ServerOption{grpc.Creds(a.serverCreds)}
+	if len(a.config.GRPC.AllowedCNs) > 0 {
+		opts = append(opts, grpc.StreamInterceptor(ingress.AuthorizeCNs(a.config.GRPC.AllowedCNs)))
+	}
+	ingressServer := ingress.NewServer(metronAddress, rx, opts...)
+	ingressServer.Start()
+}
+
+func newEnvelopeBuffer(class string, size int) *diodes.ManyToOneEnvelopeV2 {
+	return diodes.NewManyToOneEnvelopeV2(size, gendiodes.AlertFunc(func(missed int) {
 		// metric-documentation-v2: (loggregator.metron.dropped) Number of v2 envelopes
-		// droppred from the metron ingress diode
+		// droppred from the metron ingress diodes, tagged by message class
 		metric.IncCounter("dropped",
 			metric.WithIncrement(uint64(missed)),
 			metric.WithVersion(2, 0),
 			metric.WithTag("direction", "ingress"),
+			metric.WithTag("class", class),
 		)
-		log.Printf("Dropped %d v2 envelopes", missed)
+		log.Printf("Dropped %d v2 %s envelopes", missed, class)
 	}))
+}
 
-	pool := a.initializePool()
-	counterAggr := egress.New(pool)
-	tx := egress.NewTransponder(envelopeBuffer, counterAggr, a.config.Tags)
-	go tx.Start()
+// ConnectedDopplers returns the number of gRPC connections to Dopplers.
+func (a *AppV2) ConnectedDopplers() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	metronAddress := fmt.Sprintf("127.0.0.1:%d", a.config.GRPC.Port)
-	log.Printf("metron v2 API started on addr %s", metronAddress)
-	rx := ingress.NewReceiver(envelopeBuffer)
-	ingressServer := ingress.NewServer(metronAddress, rx, grpc.Creds(a.serverCreds))
-	ingressServer.Start()
+	var connected int
+	for _, m := range a.connManagers {
+		if m.Connected() {
+			connected++
+		}
+	}
+	return connected
+}
+
+// startSyslog starts a listener for each configured syslog port.
+func (a *AppV2) startSyslog(setter syslog.DataSetter) {
+	c := a.config.Syslog
+	addr := func(port uint16) string {
+		return net.JoinHostPort(c.Host, strconv.Itoa(int(port)))
+	}
+
+	if c.UDPPort != 0 {
+		l, err := syslog.NewUDPListener(addr(c.UDPPort), setter)
+		if err != nil {
+			log.Panicf("Failed to start syslog UDP listener: %s", err)
+		}
+		go l.Start()
+	}
+
+	if c.TCPPort != 0 {
+		l, err := syslog.NewTCPListener(addr(c.TCPPort), nil, setter)
+		if err != nil {
+			log.Panicf("Failed to start syslog TCP listener: %s", err)
+		}
+		go l.Start()
+	}
+
+	if c.TLSPort != 0 {
+		cert, err := tls.LoadX509KeyPair(a.config.GRPC.CertFile, a.config.GRPC.KeyFile)
+		if err != nil {
+			log.Panicf("Failed to load syslog TLS keypair: %s", err)
+		}
+		tlsConfig := plumbing.NewTLSConfig()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+
+		l, err := syslog.NewTCPListener(addr(c.TLSPort), tlsConfig, setter)
+		if err != nil {
+			log.Panicf("Failed to start syslog TLS listener: %s", err)
+		}
+		go l.Start()
+	}
+}
+
+// startStatsd starts the StatsD listener if a port is configured.
+func (a *AppV2) startStatsd(setter statsd.DataSetter) {
+	c := a.config.Statsd
+	if c.Port == 0 {
+		return
+	}
+
+	aggregator := statsd.NewAggregator(
+		setter,
+		c.SourceID,
+		time.Duration(c.FlushIntervalMilliseconds)*time.Millisecond,
+		c.MaxSeries,
+	)
+	go aggregator.Start()
+
+	l, err := statsd.NewListener(net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port))), aggregator)
+	if err != nil {
+		log.Panicf("Failed to start StatsD listener: %s", err)
+	}
+	go l.Start()
+}
+
+// startRollup returns a Rollup that aggregates timers before setting them
+// on setter.
+func (a *AppV2) startRollup(setter rollup.DataSetter) *rollup.Rollup {
+	c := a.config.Rollup
+	r := rollup.New(
+		setter,
+		time.Duration(c.IntervalMilliseconds)*time.Millisecond,
+		c.MaxSeries,
+		c.PassThrough,
+	)
+	go r.Start()
+	return r
+}
+
+// startHTTP serves the HTTP API on /v2/envelopes if a port is configured.
+func (a *AppV2) startHTTP(setter ingress.DataSetter) {
+	c := a.config.HTTP
+	if c.Port == 0 {
+		return
+	}
+
+	lis, err := net.Listen("tcp", net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port))))
+	if err != nil {
+		log.Panicf("Failed to start HTTP listener: %s", err)
+	}
+	log.Printf("metron v2 HTTP API started on addr %s", lis.Addr())
+
+	mux := http.NewServeMux()
+	mux.Handle("/v2/envelopes", ingress.NewHTTPReceiver(setter))
+	go func() {
+		log.Printf("metron v2 HTTP API stopped: %s", http.Serve(lis, mux))
+	}()
+}
+
+func (a *AppV2) initializeDiskBuffer(w egress.Writer) *diskbuffer.Buffer {
+	c := a.config.DiskBuffer
+	buffer, err := diskbuffer.New(w, diskbuffer.Config{
+		Dir:           c.Dir,
+		MaxBytes:      c.MaxBytes,
+		SegmentBytes:  c.SegmentBytes,
+		MaxAge:        time.Duration(c.MaxAgeSeconds) * time.Second,
+		RetryInterval: time.Second,
+	})
+	if err != nil {
+		log.Panicf("Failed to initialize disk buffer: %s", err)
+	}
+	go buffer.Start()
+
+	return buffer
 }
 
 func (a *AppV2) initializePool() *clientpool.ClientPool {
@@ -69,9 +265,28 @@ func (a *AppV2) initializePool() *clientpool.ClientPool {
 		log.Panic("Failed to load TLS client config")
 	}
 
-	balancers := []*clientpool.Balancer{
-		clientpool.NewBalancer(fmt.Sprintf("%s.%s", a.config.Zone, a.config.DopplerAddr)),
-		clientpool.NewBalancer(a.config.DopplerAddr),
+	balancerOpts := []clientpool.BalancerOption{
+		clientpool.WithRefreshInterval(
+			time.Duration(a.config.DopplerRefreshIntervalSeconds) * time.Second,
+		),
+		clientpool.WithOnChange(a.rebalance),
+	}
+
+	// Zones are not part of SRV names so an SRV addr only gets one
+	// balancer.
+	var balancers []*clientpool.Balancer
+	if !strings.HasPrefix(a.config.DopplerAddr, "_") {
+		balancers = append(balancers, clientpool.NewBalancer(
+			fmt.Sprintf("%s.%s", a.config.Zone, a.config.DopplerAddr),
+			balancerOpts...,
+		))
+	}
+	balancers = append(balancers, clientpool.NewBalancer(a.config.DopplerAddr, balancerOpts...))
+
+	if a.config.DopplerRefreshIntervalSeconds > 0 {
+		for _, b := range balancers {
+			go b.Start()
+		}
 	}
 
 	fetcher := clientpool.NewSenderFetcher(
@@ -80,14 +295,62 @@ func (a *AppV2) initializePool() *clientpool.ClientPool {
 
 	connector := clientpool.MakeGRPCConnector(fetcher, balancers)
 
+	// Connections are recycled after a number of writes to spread them
+	// across Dopplers. Refreshing the addresses rebalances the connections
+	// whenever the Dopplers change so they are not recycled otherwise.
+	maxWrites := func() int64 { return 10000 + rand.Int63n(1000) }
+	if a.config.DopplerRefreshIntervalSeconds > 0 {
+		maxWrites = func() int64 { return math.MaxInt64 }
+	}
+
 	var connManagers []clientpool.Conn
+	a.mu.Lock()
 	for i := 0; i < 5; i++ {
-		connManagers = append(connManagers, clientpool.NewConnManager(
+		m := clientpool.NewConnManager(
 			connector,
-			10000+rand.Int63n(1000),
+			maxWrites(),
 			time.Second,
-		))
+		)
+		a.connManagers = append(a.connManagers, m)
+		connManagers = append(connManagers, m)
+	}
+	a.mu.Unlock()
+
+	return clientpool.New(connManagers)
+}
+
+// rebalance schedules recycling every Doppler connection unless it is
+// already scheduled.
+func (a *AppV2) rebalance() {
+	a.rebalanceMu.Lock()
+	defer a.rebalanceMu.Unlock()
+
+	if a.rebalancePending {
+		return
 	}
+	a.rebalancePending = true
+	time.AfterFunc(rebalanceDelay, a.recycleConns)
+}
 
-	return clientpool.New(connManagers...)
+// recycleConns recycles every Doppler connection so that they are spread
+// across the current set of Dopplers. Connections are recycled one at a
+// time to avoid dropping every connection at once.
+func (a *AppV2) recycleConns() {
+	a.rebalanceMu.Lock()
+	a.rebalancePending = false
+	a.rebalanceMu.Unlock()
+
+	a.mu.RLock()
+	connManagers := make([]*clientpool.ConnManager, len(a.connManagers))
+	copy(connManagers, a.connManagers)
+	a.mu.RUnlock()
+
+	// metric-documentation-v2: (loggregator.metron.doppler_rebalances) Number of
+	// times the Doppler connections were rebalanced after the set of Doppler
+	// addresses changed
+	metric.IncCounter("doppler_rebalances", metric.WithVersion(2, 0))
+
+	for i, m := range connManagers {
+		time.AfterFunc(time.Duration(i)*time.Second, m.Recycle)
+	}
 }
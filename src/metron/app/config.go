@@ -4,14 +4,97 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"metron/internal/multiline"
+	"net"
 	"os"
+	"regexp"
+	"time"
 )
 
 type GRPC struct {
+	Host     string
 	Port     uint16
 	CAFile   string
 	CertFile string
 	KeyFile  string
+
+	// AllowedCNs lists the certificate common names of the clients that may
+	// send to the v2 API from other hosts. Clients on the same host are
+	// always allowed.
+	AllowedCNs []string
+}
+
+// Multiline configures the joining of continuation lines, such as the lines
+// of a stack trace, with the log line they continue.
+type Multiline struct {
+	Enabled             bool
+	Patterns            []string
+	MaxLines            int
+	MaxWaitMilliseconds uint
+	SourceIDs           []string
+}
+
+// Syslog configures the syslog listeners. A listener is disabled when its
+// port is 0. The TLS listener uses the GRPC certificate and key.
+type Syslog struct {
+	Host    string
+	UDPPort uint16
+	TCPPort uint16
+	TLSPort uint16
+}
+
+// Statsd configures the StatsD listener. It is disabled when Port is 0.
+type Statsd struct {
+	Host                      string
+	Port                      uint16
+	SourceID                  string
+	FlushIntervalMilliseconds uint
+	MaxSeries                 int
+}
+
+// HTTP configures the HTTP API that accepts JSON encoded v2 envelopes. It
+// is disabled when Port is 0. As the API is not authenticated Host must be
+// a loopback address.
+type HTTP struct {
+	Host string
+	Port uint16
+}
+
+// Rollup configures aggregating timers, including HttpStartStop events,
+// into request counts and latency percentiles per app, route and status
+// class.
+type Rollup struct {
+	Enabled              bool
+	IntervalMilliseconds uint
+	MaxSeries            int
+	PassThrough          bool
+}
+
+// DiskBuffer configures persisting v2 envelopes to disk while no Doppler is
+// reachable.
+type DiskBuffer struct {
+	Enabled       bool
+	Dir           string
+	MaxBytes      int64
+	SegmentBytes  int64
+	MaxAgeSeconds uint
+}
+
+// Queue configures the ingress queue of a message class. Weight is how
+// often the queue is read from relative to the other queues while they all
+// have envelopes.
+type Queue struct {
+	Size   int
+	Weight int
+}
+
+// Queues configures an ingress queue per message class so that a burst of
+// one class does not cause the others to be dropped.
+type Queues struct {
+	Log     Queue
+	Counter Queue
+	Gauge   Queue
+	Timer   Queue
 }
 
 type Config struct {
@@ -23,6 +106,11 @@ type Config struct {
 
 	Tags map[string]string
 
+	// TagsFile is the path of a YAML or JSON file with tags to add to
+	// envelopes based on their source ID. It is reloaded whenever it
+	// changes. See enrichment.File for its format.
+	TagsFile string
+
 	DisableUDP      bool
 	IncomingUDPPort int
 
@@ -33,10 +121,25 @@ type Config struct {
 	DopplerAddr    string
 	DopplerAddrUDP string // TODO: Delete when UDP is removed
 
+	// DopplerRefreshIntervalSeconds is how often DopplerAddr is resolved
+	// to detect added or removed Dopplers. Zero disables refreshing.
+	DopplerRefreshIntervalSeconds uint
+
 	MetricBatchIntervalMilliseconds  uint
 	RuntimeStatsIntervalMilliseconds uint
 
-	PPROFPort uint32
+	PPROFPort      uint32
+	HealthPort     uint32
+	PrometheusPort uint32
+
+	Multiline Multiline
+	Syslog    Syslog
+	Statsd    Statsd
+	HTTP      HTTP
+	Rollup    Rollup
+
+	DiskBuffer DiskBuffer
+	Queues     Queues
 }
 
 func ParseConfig(configFile string) (*Config, error) {
@@ -53,6 +156,43 @@ func Parse(reader io.Reader) (*Config, error) {
 	config := &Config{
 		MetricBatchIntervalMilliseconds:  5000,
 		RuntimeStatsIntervalMilliseconds: 15000,
+		DopplerRefreshIntervalSeconds:    60,
+		GRPC: GRPC{
+			Host: "127.0.0.1",
+		},
+		Multiline: Multiline{
+			Patterns:            []string{`^[ \t]+`, `^Caused by:`},
+			MaxLines:            100,
+			MaxWaitMilliseconds: 500,
+		},
+		Syslog: Syslog{
+			Host: "127.0.0.1",
+		},
+		Statsd: Statsd{
+			Host:                      "127.0.0.1",
+			SourceID:                  "statsd",
+			FlushIntervalMilliseconds: 10000,
+			MaxSeries:                 10000,
+		},
+		HTTP: HTTP{
+			Host: "127.0.0.1",
+		},
+		Rollup: Rollup{
+			IntervalMilliseconds: 10000,
+			MaxSeries:            10000,
+			PassThrough:          true,
+		},
+		DiskBuffer: DiskBuffer{
+			MaxBytes:      100 << 20,
+			SegmentBytes:  4 << 20,
+			MaxAgeSeconds: 3600,
+		},
+		Queues: Queues{
+			Log:     Queue{Size: 10000, Weight: 4},
+			Counter: Queue{Size: 5000, Weight: 2},
+			Gauge:   Queue{Size: 5000, Weight: 2},
+			Timer:   Queue{Size: 5000, Weight: 1},
+		},
 	}
 	err := json.NewDecoder(reader).Decode(config)
 	if err != nil {
@@ -67,5 +207,64 @@ func Parse(reader io.Reader) (*Config, error) {
 		return nil, fmt.Errorf("DopplerAddrUDP is required")
 	}
 
+	for _, p := range config.Multiline.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid Multiline pattern %q: %s", p, err)
+		}
+	}
+
+	if config.Statsd.Port != 0 && config.Statsd.FlushIntervalMilliseconds == 0 {
+		return nil, fmt.Errorf("Statsd FlushIntervalMilliseconds must be positive")
+	}
+
+	if config.HTTP.Port != 0 && !isLoopback(config.HTTP.Host) {
+		return nil, fmt.Errorf("HTTP Host %q must be a loopback address", config.HTTP.Host)
+	}
+
+	if config.Rollup.Enabled && config.Rollup.IntervalMilliseconds == 0 {
+		return nil, fmt.Errorf("Rollup IntervalMilliseconds must be positive")
+	}
+
+	if config.DiskBuffer.Enabled && config.DiskBuffer.Dir == "" {
+		return nil, fmt.Errorf("DiskBuffer Dir is required when enabled")
+	}
+
+	for class, q := range config.Queues.byClass() {
+		if q.Size < 1 || q.Weight < 1 {
+			return nil, fmt.Errorf("%s queue Size and Weight must be positive", class)
+		}
+	}
+
 	return config, nil
 }
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (q Queues) byClass() map[string]Queue {
+	return map[string]Queue{
+		"log":     q.Log,
+		"counter": q.Counter,
+		"gauge":   q.Gauge,
+		"timer":   q.Timer,
+	}
+}
+
+func (m Multiline) config() multiline.Config {
+	var patterns []*regexp.Regexp
+	for _, p := range m.Patterns {
+		patterns = append(patterns, regexp.MustCompile(p))
+	}
+
+	return multiline.Config{
+		Patterns:  patterns,
+		MaxLines:  m.MaxLines,
+		MaxWait:   time.Duration(m.MaxWaitMilliseconds) * time.Millisecond,
+		SourceIDs: m.SourceIDs,
+	}
+}
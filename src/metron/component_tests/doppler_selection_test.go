@@ -0,0 +1,106 @@
+package component_test
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	clientpool "metron/internal/clientpool/v2"
+	"plumbing"
+	v2 "plumbing/v2"
+	"testservers"
+
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doppler selection", func() {
+	var (
+		fastDoppler *Server
+		slowDoppler *Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		fastDoppler, err = NewServer()
+		Expect(err).ToNot(HaveOccurred())
+		slowDoppler, err = NewServer()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		fastDoppler.Stop()
+		slowDoppler.Stop()
+	})
+
+	It("sends most envelopes to the Doppler that receives them faster", func() {
+		pool := newDopplerPool(fastDoppler, slowDoppler)
+		fastCount := countReceived(fastDoppler, 0)
+		slowCount := countReceived(slowDoppler, 10*time.Millisecond)
+
+		e := &v2.Envelope{
+			SourceId: "some-source-id",
+			Message: &v2.Envelope_Log{
+				Log: &v2.Log{
+					Payload: []byte(strings.Repeat("a", 100)),
+				},
+			},
+		}
+		for i := 0; i < 20000; i++ {
+			Expect(pool.Write(e)).To(Succeed())
+		}
+
+		Eventually(func() int64 {
+			return atomic.LoadInt64(fastCount)
+		}, 10).Should(BeNumerically(">", 15000))
+		Expect(atomic.LoadInt64(slowCount)).To(BeNumerically("<", 5000))
+	})
+})
+
+// newDopplerPool returns a client pool with a connection to each of the
+// Dopplers.
+func newDopplerPool(dopplers ...*Server) *clientpool.ClientPool {
+	creds, err := plumbing.NewCredentials(
+		testservers.Cert("metron.crt"),
+		testservers.Cert("metron.key"),
+		testservers.Cert("loggregator-ca.crt"),
+		"doppler",
+	)
+	Expect(err).ToNot(HaveOccurred())
+	fetcher := clientpool.NewSenderFetcher(grpc.WithTransportCredentials(creds))
+
+	var conns []clientpool.Conn
+	for _, d := range dopplers {
+		connector := clientpool.MakeGRPCConnector(fetcher, []*clientpool.Balancer{
+			clientpool.NewBalancer(d.URI()),
+		})
+		m := clientpool.NewConnManager(connector, 100000, 10*time.Millisecond)
+		Eventually(m.Connected).Should(BeTrue())
+		conns = append(conns, m)
+	}
+
+	return clientpool.New(conns)
+}
+
+// countReceived receives from the first stream opened to the Doppler,
+// pausing for delay after each envelope, and returns the number of
+// envelopes received.
+func countReceived(doppler *Server, delay time.Duration) *int64 {
+	var count int64
+	var rx v2.DopplerIngress_SenderServer
+	Eventually(doppler.V2.SenderInput.Arg0).Should(Receive(&rx))
+
+	go func() {
+		for {
+			if _, err := rx.Recv(); err != nil {
+				return
+			}
+			atomic.AddInt64(&count, 1)
+			time.Sleep(delay)
+		}
+	}()
+
+	return &count
+}
@@ -2,14 +2,24 @@ package v1
 
 import (
 	"fmt"
+	"log"
 	"math/rand"
 	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Balancer provides IPs resolved from a DNS address in random order
 type Balancer struct {
-	addr   string
-	lookup func(string) ([]net.IP, error)
+	addr            string
+	lookup          func(string) ([]net.IP, error)
+	refreshInterval time.Duration
+	onChange        func()
+
+	mu        sync.Mutex
+	hostPorts []string
 }
 
 // BalancerOption is a type that will manipulate a config
@@ -22,11 +32,29 @@ func WithLookup(lookup func(string) ([]net.IP, error)) func(*Balancer) {
 	}
 }
 
+// WithRefreshInterval sets how often Start resolves the addr. It defaults
+// to one minute.
+func WithRefreshInterval(d time.Duration) func(*Balancer) {
+	return func(b *Balancer) {
+		b.refreshInterval = d
+	}
+}
+
+// WithOnChange sets a function that is called whenever a refresh resolves
+// a different set of hostports than the previous one.
+func WithOnChange(f func()) func(*Balancer) {
+	return func(b *Balancer) {
+		b.onChange = f
+	}
+}
+
 // NewBalancer returns a Balancer
 func NewBalancer(addr string, opts ...BalancerOption) *Balancer {
 	balancer := &Balancer{
-		addr:   addr,
-		lookup: net.LookupIP,
+		addr:            addr,
+		lookup:          net.LookupIP,
+		refreshInterval: time.Minute,
+		onChange:        func() {},
 	}
 
 	for _, o := range opts {
@@ -37,23 +65,74 @@ func NewBalancer(addr string, opts ...BalancerOption) *Balancer {
 }
 
 // NextHostPort returns hostport resolved from the balancer's addr.
-// It returns error for an invalid addr or if lookup failed or
-// doesn't resolve to anything.
+// Hostports of the last successful refresh are used if there are any,
+// otherwise the addr is resolved. It returns error for an invalid addr or
+// if lookup failed or doesn't resolve to anything.
 func (b *Balancer) NextHostPort() (string, error) {
+	b.mu.Lock()
+	hostPorts := b.hostPorts
+	b.mu.Unlock()
+
+	if len(hostPorts) == 0 {
+		var err error
+		hostPorts, err = b.resolve()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hostPorts[rand.Int()%len(hostPorts)], nil
+}
+
+// Start refreshes the resolved hostports every refresh interval. It blocks
+// forever.
+func (b *Balancer) Start() {
+	b.Refresh()
+	for range time.Tick(b.refreshInterval) {
+		b.Refresh()
+	}
+}
+
+// Refresh resolves the balancer's addr and calls the OnChange function if
+// the hostports differ from those of the last successful refresh. Failed
+// lookups keep the previous hostports.
+func (b *Balancer) Refresh() {
+	hostPorts, err := b.resolve()
+	if err != nil {
+		log.Printf("Failed to refresh %s: %s", b.addr, err)
+		return
+	}
+	sort.Strings(hostPorts)
+
+	b.mu.Lock()
+	changed := b.hostPorts != nil && !reflect.DeepEqual(b.hostPorts, hostPorts)
+	b.hostPorts = hostPorts
+	b.mu.Unlock()
+
+	if changed {
+		log.Printf("Addresses for %s changed", b.addr)
+		b.onChange()
+	}
+}
+
+func (b *Balancer) resolve() ([]string, error) {
 	host, port, err := net.SplitHostPort(b.addr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	ips, err := b.lookup(host)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(ips) == 0 {
-		return "", fmt.Errorf("lookup failed with addr %s", b.addr)
+		return nil, fmt.Errorf("lookup failed with addr %s", b.addr)
 	}
 
-	return net.JoinHostPort(ips[rand.Int()%len(ips)].String(), port), nil
-
+	hostPorts := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		hostPorts = append(hostPorts, net.JoinHostPort(ip.String(), port))
+	}
+	return hostPorts, nil
 }
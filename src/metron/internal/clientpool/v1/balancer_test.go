@@ -4,6 +4,8 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"sync/atomic"
+	"time"
 
 	"metron/internal/clientpool/v1"
 
@@ -69,4 +71,69 @@ var _ = Describe("Balancer", func() {
 		_, err := balancer.NextHostPort()
 		Expect(err).To(HaveOccurred())
 	})
+
+	Describe("Refresh()", func() {
+		var (
+			ips      atomic.Value
+			changes  int64
+			balancer *v1.Balancer
+		)
+
+		BeforeEach(func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.1"), net.ParseIP("10.10.10.2")})
+			atomic.StoreInt64(&changes, 0)
+
+			f := func(addr string) ([]net.IP, error) {
+				return ips.Load().([]net.IP), nil
+			}
+			balancer = v1.NewBalancer("some-addr:8082",
+				v1.WithLookup(f),
+				v1.WithOnChange(func() { atomic.AddInt64(&changes, 1) }),
+			)
+			balancer.Refresh()
+		})
+
+		It("does not report a change when the addresses are the same", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.2"), net.ParseIP("10.10.10.1")})
+			balancer.Refresh()
+
+			Expect(atomic.LoadInt64(&changes)).To(Equal(int64(0)))
+		})
+
+		It("reports a change when the addresses change", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.1"), net.ParseIP("10.10.10.3")})
+			balancer.Refresh()
+
+			Expect(atomic.LoadInt64(&changes)).To(Equal(int64(1)))
+		})
+
+		It("returns the refreshed addresses without a lookup", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.3")})
+
+			hostPort, err := balancer.NextHostPort()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hostPort).To(Or(Equal("10.10.10.1:8082"), Equal("10.10.10.2:8082")))
+
+			balancer.Refresh()
+			hostPort, err = balancer.NextHostPort()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hostPort).To(Equal("10.10.10.3:8082"))
+		})
+
+		It("refreshes on an interval when started", func() {
+			f := func(addr string) ([]net.IP, error) {
+				return ips.Load().([]net.IP), nil
+			}
+			balancer = v1.NewBalancer("some-addr:8082",
+				v1.WithLookup(f),
+				v1.WithRefreshInterval(10*time.Millisecond),
+				v1.WithOnChange(func() { atomic.AddInt64(&changes, 1) }),
+			)
+			go balancer.Start()
+
+			Consistently(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(0)))
+			ips.Store([]net.IP{net.ParseIP("10.10.10.3")})
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(1)))
+		})
+	})
 })
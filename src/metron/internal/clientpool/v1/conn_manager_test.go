@@ -39,6 +39,10 @@ var _ = Describe("ConnManager", func() {
 				close(mockPusherClient.SendOutput.Ret0)
 			})
 
+			It("reports that it is connected", func() {
+				Eventually(connManager.Connected).Should(BeTrue())
+			})
+
 			It("sends the message down the connection", func() {
 				msg := []byte("some-data")
 				f := func() error {
@@ -71,6 +75,16 @@ var _ = Describe("ConnManager", func() {
 
 					Expect(len(mockCloser.CloseCalled)).ToNot(BeZero())
 				})
+
+				It("reconnects after being recycled", func() {
+					Eventually(connManager.Connected).Should(BeTrue())
+
+					connManager.Recycle()
+
+					Expect(mockCloser.CloseCalled).To(HaveLen(1))
+					Eventually(mockConnector.ConnectCalled).Should(HaveLen(2))
+					Eventually(connManager.Connected).Should(BeTrue())
+				})
 			})
 		})
 
@@ -107,5 +121,9 @@ var _ = Describe("ConnManager", func() {
 			}
 			Consistently(f).Should(HaveOccurred())
 		})
+
+		It("reports that it is not connected", func() {
+			Consistently(connManager.Connected).Should(BeFalse())
+		})
 	})
 })
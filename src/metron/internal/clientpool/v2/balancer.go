@@ -2,14 +2,35 @@ package v2
 
 import (
 	"fmt"
+	"log"
 	"math/rand"
 	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Balancer provides IPs resolved from a DNS address in random order
+// Balancer provides hostports resolved from a DNS address in random order.
+// An addr starting with an underscore (e.g. _doppler._tcp.example.com) is
+// resolved as a DNS SRV record, any other addr must be a host and port.
 type Balancer struct {
-	addr   string
-	lookup func(string) ([]net.IP, error)
+	addr            string
+	lookup          func(string) ([]net.IP, error)
+	lookupSRV       func(service, proto, name string) (string, []*net.SRV, error)
+	refreshInterval time.Duration
+	onChange        func()
+
+	mu      sync.Mutex
+	targets []target
+}
+
+type target struct {
+	hostPort string
+	priority uint16
+	weight   uint16
 }
 
 // BalancerOption is a type that will manipulate a config
@@ -22,11 +43,37 @@ func WithLookup(lookup func(string) ([]net.IP, error)) func(*Balancer) {
 	}
 }
 
+// WithLookupSRV sets the behavior of looking up SRV records
+func WithLookupSRV(lookup func(service, proto, name string) (string, []*net.SRV, error)) func(*Balancer) {
+	return func(b *Balancer) {
+		b.lookupSRV = lookup
+	}
+}
+
+// WithRefreshInterval sets how often Start resolves the addr. It defaults
+// to one minute.
+func WithRefreshInterval(d time.Duration) func(*Balancer) {
+	return func(b *Balancer) {
+		b.refreshInterval = d
+	}
+}
+
+// WithOnChange sets a function that is called whenever a refresh resolves
+// a different set of hostports than the previous one.
+func WithOnChange(f func()) func(*Balancer) {
+	return func(b *Balancer) {
+		b.onChange = f
+	}
+}
+
 // NewBalancer returns a Balancer
 func NewBalancer(addr string, opts ...BalancerOption) *Balancer {
 	balancer := &Balancer{
-		addr:   addr,
-		lookup: net.LookupIP,
+		addr:            addr,
+		lookup:          net.LookupIP,
+		lookupSRV:       net.LookupSRV,
+		refreshInterval: time.Minute,
+		onChange:        func() {},
 	}
 
 	for _, o := range opts {
@@ -37,23 +84,146 @@ func NewBalancer(addr string, opts ...BalancerOption) *Balancer {
 }
 
 // NextHostPort returns hostport resolved from the balancer's addr.
-// It returns error for an invalid addr or if lookup failed or
-// doesn't resolve to anything.
+// Hostports of the last successful refresh are used if there are any,
+// otherwise the addr is resolved. It returns error for an invalid addr or
+// if lookup failed or doesn't resolve to anything. SRV targets are chosen
+// from the lowest priority in proportion to their weight.
 func (b *Balancer) NextHostPort() (string, error) {
+	b.mu.Lock()
+	targets := b.targets
+	b.mu.Unlock()
+
+	if len(targets) == 0 {
+		var err error
+		targets, err = b.resolve()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return pick(targets), nil
+}
+
+// Start refreshes the resolved hostports every refresh interval. It blocks
+// forever.
+func (b *Balancer) Start() {
+	b.Refresh()
+	for range time.Tick(b.refreshInterval) {
+		b.Refresh()
+	}
+}
+
+// Refresh resolves the balancer's addr and calls the OnChange function if
+// the hostports differ from those of the last successful refresh. Failed
+// lookups keep the previous hostports.
+func (b *Balancer) Refresh() {
+	targets, err := b.resolve()
+	if err != nil {
+		log.Printf("Failed to refresh %s: %s", b.addr, err)
+		return
+	}
+	sort.Sort(byHostPort(targets))
+
+	b.mu.Lock()
+	changed := b.targets != nil && !reflect.DeepEqual(b.targets, targets)
+	b.targets = targets
+	b.mu.Unlock()
+
+	if changed {
+		log.Printf("Addresses for %s changed", b.addr)
+		b.onChange()
+	}
+}
+
+func (b *Balancer) resolve() ([]target, error) {
+	if strings.HasPrefix(b.addr, "_") {
+		return b.resolveSRV()
+	}
+
 	host, port, err := net.SplitHostPort(b.addr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	ips, err := b.lookup(host)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(ips) == 0 {
-		return "", fmt.Errorf("lookup failed with addr %s", b.addr)
+		return nil, fmt.Errorf("lookup failed with addr %s", b.addr)
 	}
 
-	return net.JoinHostPort(ips[rand.Int()%len(ips)].String(), port), nil
+	targets := make([]target, 0, len(ips))
+	for _, ip := range ips {
+		targets = append(targets, target{
+			hostPort: net.JoinHostPort(ip.String(), port),
+			weight:   1,
+		})
+	}
+	return targets, nil
+}
+
+func (b *Balancer) resolveSRV() ([]target, error) {
+	_, srvs, err := b.lookupSRV("", "", b.addr)
+	if err != nil {
+		return nil, err
+	}
 
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("lookup failed with addr %s", b.addr)
+	}
+
+	targets := make([]target, 0, len(srvs))
+	for _, srv := range srvs {
+		targets = append(targets, target{
+			hostPort: net.JoinHostPort(
+				strings.TrimSuffix(srv.Target, "."),
+				strconv.Itoa(int(srv.Port)),
+			),
+			priority: srv.Priority,
+			weight:   srv.Weight,
+		})
+	}
+	return targets, nil
+}
+
+// pick chooses a target with the lowest priority at random in proportion
+// to its weight. Targets are chosen uniformly if all their weights are
+// equal.
+func pick(targets []target) string {
+	var candidates []target
+	for _, t := range targets {
+		switch {
+		case len(candidates) == 0 || t.priority < candidates[0].priority:
+			candidates = []target{t}
+		case t.priority == candidates[0].priority:
+			candidates = append(candidates, t)
+		}
+	}
+
+	var total int
+	uniform := true
+	for _, t := range candidates {
+		total += int(t.weight)
+		uniform = uniform && t.weight == candidates[0].weight
+	}
+	if uniform {
+		return candidates[rand.Int()%len(candidates)].hostPort
+	}
+
+	n := rand.Intn(total)
+	for _, t := range candidates {
+		n -= int(t.weight)
+		if n < 0 {
+			return t.hostPort
+		}
+	}
+	return candidates[len(candidates)-1].hostPort
 }
+
+type byHostPort []target
+
+func (t byHostPort) Len() int           { return len(t) }
+func (t byHostPort) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byHostPort) Less(i, j int) bool { return t[i].hostPort < t[j].hostPort }
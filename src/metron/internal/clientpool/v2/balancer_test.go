@@ -4,8 +4,10 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"sync/atomic"
+	"time"
 
-	"metron/internal/clientpool/v1"
+	clientpool "metron/internal/clientpool/v2"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -25,7 +27,7 @@ var _ = Describe("Balancer", func() {
 		}
 
 		addr := "some-addr:8082"
-		balancer := v1.NewBalancer(addr, v1.WithLookup(f))
+		balancer := clientpool.NewBalancer(addr, clientpool.WithLookup(f))
 
 		NextIP := func() string {
 			ip, _ := balancer.NextHostPort()
@@ -42,7 +44,7 @@ var _ = Describe("Balancer", func() {
 			return nil, errors.New("some-error")
 		}
 		addr := "some-addr:8082"
-		balancer := v1.NewBalancer(addr, v1.WithLookup(f))
+		balancer := clientpool.NewBalancer(addr, clientpool.WithLookup(f))
 
 		_, err := balancer.NextHostPort()
 		Expect(err).To(HaveOccurred())
@@ -53,7 +55,7 @@ var _ = Describe("Balancer", func() {
 			return []net.IP{}, nil
 		}
 		addr := "some-addr:8082"
-		balancer := v1.NewBalancer(addr, v1.WithLookup(f))
+		balancer := clientpool.NewBalancer(addr, clientpool.WithLookup(f))
 
 		_, err := balancer.NextHostPort()
 		Expect(err).To(HaveOccurred())
@@ -64,9 +66,128 @@ var _ = Describe("Balancer", func() {
 			panic("Never should be here")
 		}
 		addr := "some-addr/qwe34523475itaysdgp:oauei4h:8082"
-		balancer := v1.NewBalancer(addr, v1.WithLookup(f))
+		balancer := clientpool.NewBalancer(addr, clientpool.WithLookup(f))
 
 		_, err := balancer.NextHostPort()
 		Expect(err).To(HaveOccurred())
 	})
+
+	Context("with an SRV addr", func() {
+		It("returns the targets of the lowest priority with their ports", func() {
+			f := func(service, proto, name string) (string, []*net.SRV, error) {
+				Expect(name).To(Equal("_doppler._tcp.example.com"))
+				return "", []*net.SRV{
+					{Target: "doppler-0.example.com.", Port: 8082, Priority: 10, Weight: 1},
+					{Target: "doppler-1.example.com.", Port: 8083, Priority: 10, Weight: 1},
+					{Target: "doppler-2.example.com.", Port: 8084, Priority: 20, Weight: 100},
+				}, nil
+			}
+			balancer := clientpool.NewBalancer("_doppler._tcp.example.com", clientpool.WithLookupSRV(f))
+
+			seen := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				hostPort, err := balancer.NextHostPort()
+				Expect(err).ToNot(HaveOccurred())
+				seen[hostPort] = true
+			}
+			Expect(seen).To(Equal(map[string]bool{
+				"doppler-0.example.com:8082": true,
+				"doppler-1.example.com:8083": true,
+			}))
+		})
+
+		It("chooses targets in proportion to their weight", func() {
+			f := func(service, proto, name string) (string, []*net.SRV, error) {
+				return "", []*net.SRV{
+					{Target: "heavy.example.com.", Port: 8082, Weight: 90},
+					{Target: "light.example.com.", Port: 8082, Weight: 10},
+				}, nil
+			}
+			balancer := clientpool.NewBalancer("_doppler._tcp.example.com", clientpool.WithLookupSRV(f))
+
+			var heavy int
+			for i := 0; i < 1000; i++ {
+				hostPort, _ := balancer.NextHostPort()
+				if hostPort == "heavy.example.com:8082" {
+					heavy++
+				}
+			}
+			Expect(heavy).To(BeNumerically("~", 900, 50))
+		})
+
+		It("returns an error if lookup is empty", func() {
+			f := func(service, proto, name string) (string, []*net.SRV, error) {
+				return "", nil, nil
+			}
+			balancer := clientpool.NewBalancer("_doppler._tcp.example.com", clientpool.WithLookupSRV(f))
+
+			_, err := balancer.NextHostPort()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Refresh()", func() {
+		var (
+			ips      atomic.Value
+			changes  int64
+			balancer *clientpool.Balancer
+		)
+
+		BeforeEach(func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.1"), net.ParseIP("10.10.10.2")})
+			atomic.StoreInt64(&changes, 0)
+
+			f := func(addr string) ([]net.IP, error) {
+				return ips.Load().([]net.IP), nil
+			}
+			balancer = clientpool.NewBalancer("some-addr:8082",
+				clientpool.WithLookup(f),
+				clientpool.WithOnChange(func() { atomic.AddInt64(&changes, 1) }),
+			)
+			balancer.Refresh()
+		})
+
+		It("does not report a change when the addresses are the same", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.2"), net.ParseIP("10.10.10.1")})
+			balancer.Refresh()
+
+			Expect(atomic.LoadInt64(&changes)).To(Equal(int64(0)))
+		})
+
+		It("reports a change when the addresses change", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.1"), net.ParseIP("10.10.10.3")})
+			balancer.Refresh()
+
+			Expect(atomic.LoadInt64(&changes)).To(Equal(int64(1)))
+		})
+
+		It("returns the refreshed addresses without a lookup", func() {
+			ips.Store([]net.IP{net.ParseIP("10.10.10.3")})
+
+			hostPort, err := balancer.NextHostPort()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hostPort).To(Or(Equal("10.10.10.1:8082"), Equal("10.10.10.2:8082")))
+
+			balancer.Refresh()
+			hostPort, err = balancer.NextHostPort()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hostPort).To(Equal("10.10.10.3:8082"))
+		})
+
+		It("refreshes on an interval when started", func() {
+			f := func(addr string) ([]net.IP, error) {
+				return ips.Load().([]net.IP), nil
+			}
+			balancer = clientpool.NewBalancer("some-addr:8082",
+				clientpool.WithLookup(f),
+				clientpool.WithRefreshInterval(10*time.Millisecond),
+				clientpool.WithOnChange(func() { atomic.AddInt64(&changes, 1) }),
+			)
+			go balancer.Start()
+
+			Consistently(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(0)))
+			ips.Store([]net.IP{net.ParseIP("10.10.10.3")})
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(1)))
+		})
+	})
 })
@@ -2,42 +2,242 @@ package v2
 
 import (
 	"errors"
+	"log"
+	"math"
 	"math/rand"
+	"metric"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	plumbing "plumbing/v2"
 )
 
+const (
+	// latencyWeight is the weight of a new latency sample in the moving
+	// average.
+	latencyWeight = 0.2
+
+	// latencyDecay is how quickly the latency of a conn that is not
+	// written to decays towards zero so that it is eventually retried.
+	latencyDecay = 10 * time.Second
+)
+
 type Conn interface {
 	Write(data *plumbing.Envelope) (err error)
 }
 
+// recycler is implemented by conns that can drop their connection so that
+// they reconnect, possibly to a different Doppler.
+type recycler interface {
+	Recycle()
+}
+
+// ClientPool writes to one of several conns. It picks the faster of two
+// random conns based on the moving average of their write latency. Conns
+// that fail repeatedly or are much slower than the others are ejected for
+// a while. At most half of the conns are ejected at once and ejected conns
+// are still written to if all others fail.
 type ClientPool struct {
 	conns []unsafe.Pointer
+
+	maxFailures       int
+	ejectionDuration  time.Duration
+	outlierFactor     float64
+	outlierMinLatency time.Duration
+
+	mu    sync.Mutex
+	stats []connStats
 }
 
-func New(conns ...Conn) *ClientPool {
+type connStats struct {
+	// latency is the moving average of the write latency in nanoseconds.
+	// It is 0 when unknown.
+	latency      float64
+	updated      time.Time
+	failures     int
+	ejectedUntil time.Time
+}
+
+// PoolOption configures a ClientPool.
+type PoolOption func(*ClientPool)
+
+// WithEjection ejects a conn for the given duration after maxFailures
+// consecutive failed writes. It defaults to 5 failures and 30 seconds.
+func WithEjection(maxFailures int, duration time.Duration) PoolOption {
+	return func(c *ClientPool) {
+		c.maxFailures = maxFailures
+		c.ejectionDuration = duration
+	}
+}
+
+// WithOutlierLatency ejects a conn whose average write latency exceeds
+// both min and factor times the median latency of the other conns. It
+// defaults to 10 times and 20 milliseconds.
+func WithOutlierLatency(factor float64, min time.Duration) PoolOption {
+	return func(c *ClientPool) {
+		c.outlierFactor = factor
+		c.outlierMinLatency = min
+	}
+}
+
+func New(conns []Conn, opts ...PoolOption) *ClientPool {
 	pool := &ClientPool{
-		conns: make([]unsafe.Pointer, len(conns)),
+		conns:             make([]unsafe.Pointer, len(conns)),
+		maxFailures:       5,
+		ejectionDuration:  30 * time.Second,
+		outlierFactor:     10,
+		outlierMinLatency: 20 * time.Millisecond,
+		stats:             make([]connStats, len(conns)),
 	}
 	for i := range conns {
 		pool.conns[i] = unsafe.Pointer(&conns[i])
 	}
 
+	for _, o := range opts {
+		o(pool)
+	}
+
 	return pool
 }
 
 func (c *ClientPool) Write(msg *plumbing.Envelope) error {
-	seed := rand.Int()
-	for i := range c.conns {
-		idx := (i + seed) % len(c.conns)
+	for _, idx := range c.order(time.Now()) {
 		conn := *(*Conn)(atomic.LoadPointer(&c.conns[idx]))
 
-		if err := conn.Write(msg); err == nil {
+		start := time.Now()
+		err := conn.Write(msg)
+		if c.record(idx, start, err) {
+			if r, ok := conn.(recycler); ok {
+				r.Recycle()
+			}
+		}
+
+		if err == nil {
 			return nil
 		}
 	}
 
 	return errors.New("unable to write to any dopplers")
 }
+
+// order returns the indexes of the conns in the order they should be
+// tried: the faster of two random healthy conns, the remaining healthy
+// conns in random order and finally the ejected conns.
+func (c *ClientPool) order(now time.Time) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	healthy := make([]int, 0, len(c.conns))
+	var ejected []int
+	for _, idx := range rand.Perm(len(c.conns)) {
+		s := &c.stats[idx]
+		if now.Before(s.ejectedUntil) {
+			ejected = append(ejected, idx)
+			continue
+		}
+		if !s.ejectedUntil.IsZero() {
+			*s = connStats{}
+		}
+		healthy = append(healthy, idx)
+	}
+
+	if len(healthy) >= 2 && c.stats[healthy[1]].currentLatency(now) < c.stats[healthy[0]].currentLatency(now) {
+		healthy[0], healthy[1] = healthy[1], healthy[0]
+	}
+
+	return append(healthy, ejected...)
+}
+
+// record updates the stats of a conn after a write that started at start.
+// It reports whether the conn was ejected.
+func (c *ClientPool) record(idx int, start time.Time, err error) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := &c.stats[idx]
+	if err != nil {
+		s.failures++
+		if s.failures >= c.maxFailures {
+			return c.eject(idx, now, "errors")
+		}
+		return false
+	}
+
+	s.failures = 0
+	latency := float64(now.Sub(start))
+	if current := s.currentLatency(now); current != 0 {
+		latency = current + latencyWeight*(latency-current)
+	}
+	s.latency = latency
+	s.updated = now
+
+	if c.isOutlier(idx, now) {
+		return c.eject(idx, now, "latency")
+	}
+	return false
+}
+
+// isOutlier reports whether the conn is much slower than the median of
+// the other healthy conns. c.mu must be held.
+func (c *ClientPool) isOutlier(idx int, now time.Time) bool {
+	latency := c.stats[idx].latency
+	if latency <= float64(c.outlierMinLatency) {
+		return false
+	}
+
+	var others []float64
+	for i := range c.stats {
+		l := c.stats[i].currentLatency(now)
+		if i == idx || now.Before(c.stats[i].ejectedUntil) || l == 0 {
+			continue
+		}
+		others = append(others, l)
+	}
+	if len(others) == 0 {
+		return false
+	}
+
+	sort.Float64s(others)
+	return latency > c.outlierFactor*others[len(others)/2]
+}
+
+// eject ejects the conn unless half of the conns are already ejected.
+// c.mu must be held.
+func (c *ClientPool) eject(idx int, now time.Time, reason string) bool {
+	var ejected int
+	for i := range c.stats {
+		if now.Before(c.stats[i].ejectedUntil) {
+			ejected++
+		}
+	}
+	if ejected+1 > len(c.stats)/2 {
+		return false
+	}
+
+	c.stats[idx] = connStats{
+		ejectedUntil: now.Add(c.ejectionDuration),
+	}
+
+	log.Printf("ejecting doppler connection %d for %s due to %s", idx, c.ejectionDuration, reason)
+	// metric-documentation-v2: (loggregator.metron.doppler_ejections) Number
+	// of times a Doppler connection was ejected, tagged by reason
+	metric.IncCounter("doppler_ejections",
+		metric.WithVersion(2, 0),
+		metric.WithTag("reason", reason),
+	)
+	return true
+}
+
+// currentLatency returns the latency decayed by the time since it was last
+// updated.
+func (s *connStats) currentLatency(now time.Time) float64 {
+	if s.latency == 0 {
+		return 0
+	}
+	return s.latency * math.Exp(-float64(now.Sub(s.updated))/float64(latencyDecay))
+}
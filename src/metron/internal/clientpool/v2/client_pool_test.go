@@ -2,6 +2,7 @@ package v2_test
 
 import (
 	"fmt"
+	"time"
 
 	clientpool "metron/internal/clientpool/v2"
 	plumbing "plumbing/v2"
@@ -11,15 +12,22 @@ import (
 )
 
 type SpyConn struct {
-	err  error
-	data []*plumbing.Envelope
+	err      error
+	delay    time.Duration
+	data     []*plumbing.Envelope
+	recycled int
 }
 
 func (s *SpyConn) Write(e *plumbing.Envelope) error {
+	time.Sleep(s.delay)
 	s.data = append(s.data, e)
 	return s.err
 }
 
+func (s *SpyConn) Recycle() {
+	s.recycled++
+}
+
 var _ = Describe("ClientPool", func() {
 	var (
 		pool  *clientpool.ClientPool
@@ -34,7 +42,7 @@ var _ = Describe("ClientPool", func() {
 			conns = append(conns, conn)
 			poolConns = append(poolConns, conn)
 		}
-		pool = clientpool.New(poolConns...)
+		pool = clientpool.New(poolConns)
 	})
 
 	Describe("Write()", func() {
@@ -61,6 +69,76 @@ var _ = Describe("ClientPool", func() {
 			})
 		})
 
+		Context("with a slow conn", func() {
+			BeforeEach(func() {
+				conns[0].delay = 2 * time.Millisecond
+			})
+
+			It("prefers the faster conns", func() {
+				for i := 0; i < 100; i++ {
+					Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+				}
+
+				Expect(len(conns[0].data)).To(BeNumerically("<", 10))
+			})
+
+			It("ejects the conn if it is an outlier", func() {
+				var poolConns []clientpool.Conn
+				for _, c := range conns {
+					poolConns = append(poolConns, c)
+				}
+				pool = clientpool.New(poolConns, clientpool.WithOutlierLatency(10, 100*time.Microsecond))
+
+				conns[0].delay = 0
+				for _, c := range conns {
+					for len(c.data) == 0 {
+						Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+					}
+				}
+				conns[0].delay = 2 * time.Millisecond
+
+				Eventually(func() int {
+					Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+					return conns[0].recycled
+				}).Should(Equal(1))
+			})
+		})
+
+		Context("with a failing conn", func() {
+			BeforeEach(func() {
+				conns[0].err = fmt.Errorf("some-error")
+
+				var poolConns []clientpool.Conn
+				for _, c := range conns {
+					poolConns = append(poolConns, c)
+				}
+				pool = clientpool.New(poolConns, clientpool.WithEjection(2, 50*time.Millisecond))
+			})
+
+			It("ejects the conn after consecutive failures", func() {
+				for i := 0; i < 100; i++ {
+					Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+				}
+
+				Expect(conns[0].data).To(HaveLen(2))
+				Expect(conns[0].recycled).To(Equal(1))
+			})
+
+			It("writes to the conn again once the ejection expires", func() {
+				for len(conns[0].data) < 2 {
+					Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+				}
+				conns[0].err = nil
+				time.Sleep(60 * time.Millisecond)
+
+				for i := 0; i < 100; i++ {
+					Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
+				}
+
+				Expect(len(conns[0].data)).To(BeNumerically(">", 2))
+			})
+		})
+
 		Context("all conns succeed", func() {
 			It("returns a nil error", func() {
 				Expect(pool.Write(&plumbing.Envelope{})).To(Succeed())
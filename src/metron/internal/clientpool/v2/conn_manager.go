@@ -64,6 +64,25 @@ func (m *ConnManager) Write(envelope *plumbing.Envelope) error {
 	return nil
 }
 
+// Connected reports whether the ConnManager has a connection to a Doppler.
+func (m *ConnManager) Connected() bool {
+	conn := atomic.LoadPointer(&m.conn)
+	return conn != nil && (*v2GRPCConn)(conn) != nil
+}
+
+// Recycle closes the connection. A new connection, possibly to a different
+// Doppler, is established on the next poll.
+func (m *ConnManager) Recycle() {
+	conn := atomic.SwapPointer(&m.conn, nil)
+	if conn == nil || (*v2GRPCConn)(conn) == nil {
+		return
+	}
+
+	gRPCConn := (*v2GRPCConn)(conn)
+	log.Printf("recycling connection to doppler %s", gRPCConn.name)
+	gRPCConn.closer.Close()
+}
+
 func (m *ConnManager) maintainConn() {
 	for range time.Tick(m.pollDuration) {
 		conn := atomic.LoadPointer(&m.conn)
@@ -77,6 +77,10 @@ var _ = Describe("ConnManager", func() {
 			connManager = clientpool.NewConnManager(connector, 5, time.Millisecond)
 		})
 
+		It("reports that it is connected", func() {
+			Eventually(connManager.Connected).Should(BeTrue())
+		})
+
 		It("sends the message down the connection", func() {
 			e := &plumbing.Envelope{SourceId: "some-uuid"}
 			f := func() error {
@@ -96,6 +100,16 @@ var _ = Describe("ConnManager", func() {
 			Expect(closer.called).ToNot(BeZero())
 		})
 
+		It("reconnects after being recycled", func() {
+			Eventually(connManager.Connected).Should(BeTrue())
+
+			connManager.Recycle()
+
+			Expect(closer.called).To(Equal(1))
+			Eventually(connector.called).Should(Equal(2))
+			Eventually(connManager.Connected).Should(BeTrue())
+		})
+
 		Context("when Send() returns an error", func() {
 			BeforeEach(func() {
 				f := func() error {
@@ -129,5 +143,9 @@ var _ = Describe("ConnManager", func() {
 			}
 			Consistently(f).Should(HaveOccurred())
 		})
+
+		It("reports that it is not connected", func() {
+			Consistently(connManager.Connected).Should(BeFalse())
+		})
 	})
 })
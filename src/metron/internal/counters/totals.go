@@ -0,0 +1,120 @@
+// Package counters tracks running totals of counters for the egress
+// aggregators.
+package counters
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Totals holds the running total of a bounded number of counters. Counters
+// that have not been updated within the TTL are evicted, as is the least
+// recently updated counter when a new counter would exceed the maximum.
+// An evicted counter starts again from zero. It is safe for concurrent use.
+type Totals struct {
+	maxCounters int
+	ttl         time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type entry struct {
+	key     string
+	total   uint64
+	updated time.Time
+}
+
+// TotalsOption configures a Totals.
+type TotalsOption func(*Totals)
+
+// WithClock sets the function used to read the current time. It defaults
+// to time.Now.
+func WithClock(now func() time.Time) TotalsOption {
+	return func(t *Totals) {
+		t.now = now
+	}
+}
+
+// NewTotals returns a Totals that tracks at most maxCounters counters and
+// evicts counters idle for longer than ttl.
+func NewTotals(maxCounters int, ttl time.Duration, opts ...TotalsOption) *Totals {
+	if maxCounters < 1 {
+		maxCounters = 1
+	}
+
+	t := &Totals{
+		maxCounters: maxCounters,
+		ttl:         ttl,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+// Add adds delta to the total of the counter with the given key. It
+// returns the new total and the number of counters evicted to make room.
+func (t *Totals) Add(key string, delta uint64) (total uint64, evicted int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	evicted = t.evictIdle(now)
+
+	if el, ok := t.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.total += delta
+		e.updated = now
+		t.lru.MoveToFront(el)
+		return e.total, evicted
+	}
+
+	if len(t.entries) >= t.maxCounters {
+		t.remove(t.lru.Back())
+		evicted++
+	}
+
+	t.entries[key] = t.lru.PushFront(&entry{
+		key:     key,
+		total:   delta,
+		updated: now,
+	})
+	return delta, evicted
+}
+
+// Len returns the number of counters being tracked.
+func (t *Totals) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.entries)
+}
+
+// evictIdle removes counters that have not been updated within the TTL.
+// The least recently updated counters are at the back of the list so it
+// stops at the first counter that is still live.
+func (t *Totals) evictIdle(now time.Time) int {
+	var evicted int
+	for el := t.lru.Back(); el != nil; el = t.lru.Back() {
+		if now.Sub(el.Value.(*entry).updated) <= t.ttl {
+			break
+		}
+		t.remove(el)
+		evicted++
+	}
+	return evicted
+}
+
+func (t *Totals) remove(el *list.Element) {
+	t.lru.Remove(el)
+	delete(t.entries, el.Value.(*entry).key)
+}
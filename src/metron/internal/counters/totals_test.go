@@ -0,0 +1,86 @@
+package counters_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"metron/internal/counters"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Totals", func() {
+	var (
+		now    time.Time
+		totals *counters.Totals
+	)
+
+	BeforeEach(func() {
+		now = time.Unix(0, 0)
+		totals = counters.NewTotals(2, time.Minute, counters.WithClock(func() time.Time {
+			return now
+		}))
+	})
+
+	It("sums the deltas of each counter", func() {
+		totals.Add("a", 10)
+		totals.Add("b", 5)
+		total, evicted := totals.Add("a", 15)
+
+		Expect(total).To(Equal(uint64(25)))
+		Expect(evicted).To(Equal(0))
+		Expect(totals.Len()).To(Equal(2))
+	})
+
+	It("evicts the least recently updated counter when full", func() {
+		totals.Add("a", 10)
+		totals.Add("b", 10)
+		totals.Add("a", 10)
+
+		_, evicted := totals.Add("c", 10)
+		Expect(evicted).To(Equal(1))
+
+		total, _ := totals.Add("a", 10)
+		Expect(total).To(Equal(uint64(30)))
+
+		total, _ = totals.Add("b", 10)
+		Expect(total).To(Equal(uint64(10)))
+		Expect(totals.Len()).To(Equal(2))
+	})
+
+	It("evicts counters that have been idle for longer than the TTL", func() {
+		totals.Add("a", 10)
+		now = now.Add(30 * time.Second)
+		totals.Add("b", 10)
+		now = now.Add(45 * time.Second)
+
+		total, evicted := totals.Add("b", 10)
+		Expect(total).To(Equal(uint64(20)))
+		Expect(evicted).To(Equal(1))
+		Expect(totals.Len()).To(Equal(1))
+
+		total, _ = totals.Add("a", 10)
+		Expect(total).To(Equal(uint64(10)))
+	})
+
+	It("handles concurrent writes", func() {
+		totals = counters.NewTotals(100, time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					totals.Add(fmt.Sprint("counter-", j%10), 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		total, _ := totals.Add("counter-0", 0)
+		Expect(total).To(Equal(uint64(100)))
+	})
+})
@@ -0,0 +1,371 @@
+// Package diskbuffer persists v2 envelopes to local disk while they cannot
+// be written to any Doppler and writes them once a Doppler is reachable
+// again.
+package diskbuffer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"metric"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	plumbing "plumbing/v2"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	segmentExt = ".seg"
+
+	// headerSize is the size of the length and persisted time that precede
+	// every record.
+	headerSize = 12
+)
+
+type Writer interface {
+	Write(msg *plumbing.Envelope) error
+}
+
+// Config bounds the Buffer.
+type Config struct {
+	// Dir holds the segment files. It is created if it does not exist.
+	Dir string
+
+	// MaxBytes is the most disk space used. Envelopes that do not fit are
+	// dropped.
+	MaxBytes int64
+
+	// SegmentBytes is the size at which a new segment file is started.
+	SegmentBytes int64
+
+	// MaxAge is how long an envelope is kept. Older envelopes are dropped
+	// instead of written.
+	MaxAge time.Duration
+
+	// RetryInterval is how long draining pauses after a failed write.
+	RetryInterval time.Duration
+}
+
+// Buffer is a Writer that writes envelopes to the wrapped Writer and appends
+// them to segment files on disk when that fails. Start drains the segments
+// oldest first. Segments are deleted once drained so envelopes persisted
+// before a restart are drained after it. Envelopes are delivered at least
+// once: a segment that is partially drained when Metron stops is drained
+// from its beginning again.
+type Buffer struct {
+	writer Writer
+	c      Config
+
+	// writeMu serializes writes to the wrapped Writer between Write and
+	// the drain.
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	segments   []uint64
+	active     *os.File
+	activeID   uint64
+	activeSize int64
+	nextID     uint64
+	size       int64
+	offset     int64
+}
+
+// New returns a Buffer that writes to w. Segments left in the directory by
+// a previous run are drained first.
+func New(w Writer, c Config) (*Buffer, error) {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Buffer{
+		writer: w,
+		c:      c,
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		b.segments = append(b.segments, id)
+		b.size += f.Size()
+		if id >= b.nextID {
+			b.nextID = id + 1
+		}
+	}
+	sort.Sort(byID(b.segments))
+
+	if len(b.segments) > 0 {
+		log.Printf("disk buffer found %d bytes in %d segments", b.size, len(b.segments))
+	}
+	return b, nil
+}
+
+// Write writes the envelope to the wrapped Writer. If that fails the
+// envelope is persisted. An error is only returned if the envelope can not
+// be persisted either.
+func (b *Buffer) Write(e *plumbing.Envelope) error {
+	if err := b.send(e); err == nil {
+		return nil
+	}
+
+	err := b.persist(e)
+	if err != nil {
+		// metric-documentation-v2: (loggregator.metron.disk_buffer_dropped)
+		// Number of envelopes that could neither be written to Doppler nor
+		// persisted to disk
+		metric.IncCounter("disk_buffer_dropped",
+			metric.WithVersion(2, 0),
+		)
+		return err
+	}
+
+	// metric-documentation-v2: (loggregator.metron.disk_buffer_persisted)
+	// Number of envelopes persisted to disk because no Doppler was reachable
+	metric.IncCounter("disk_buffer_persisted",
+		metric.WithVersion(2, 0),
+	)
+	return nil
+}
+
+func (b *Buffer) send(e *plumbing.Envelope) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return b.writer.Write(e)
+}
+
+func (b *Buffer) persist(e *plumbing.Envelope) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	binary.BigEndian.PutUint64(record[4:], uint64(time.Now().UnixNano()))
+	copy(record[headerSize:], data)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.size+int64(len(record)) > b.c.MaxBytes {
+		return errors.New("disk buffer is full")
+	}
+
+	if b.active != nil && b.activeSize+int64(len(record)) > b.c.SegmentBytes {
+		b.closeActive()
+	}
+
+	if b.active == nil {
+		f, err := os.OpenFile(b.path(b.nextID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		b.active = f
+		b.activeID = b.nextID
+		b.activeSize = 0
+		b.nextID++
+	}
+
+	n, err := b.active.Write(record)
+	b.activeSize += int64(n)
+	b.size += int64(n)
+	if err != nil {
+		b.closeActive()
+		return err
+	}
+
+	return nil
+}
+
+// closeActive makes the active segment available for draining. b.mu must
+// be held.
+func (b *Buffer) closeActive() {
+	b.active.Close()
+	b.segments = append(b.segments, b.activeID)
+	b.active = nil
+}
+
+// Start drains the persisted segments. It blocks forever.
+func (b *Buffer) Start() {
+	for {
+		if !b.Drain() {
+			time.Sleep(b.c.RetryInterval)
+		}
+	}
+}
+
+// Drain writes the envelopes of the oldest segment and deletes it. It
+// reports false if there is nothing to drain or a write failed.
+func (b *Buffer) Drain() bool {
+	id, offset, ok := b.oldest()
+
+	// metric-documentation-v2: (loggregator.metron.disk_buffer_bytes) Bytes
+	// of envelopes persisted to disk waiting for a Doppler
+	metric.SetGauge("disk_buffer_bytes", float64(b.Size()), "bytes",
+		metric.WithVersion(2, 0),
+	)
+	if !ok {
+		return false
+	}
+
+	f, err := os.Open(b.path(id))
+	if err != nil {
+		log.Printf("disk buffer failed to open segment %d: %s", id, err)
+		b.remove(id)
+		return true
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		log.Printf("disk buffer failed to seek segment %d: %s", id, err)
+		b.remove(id)
+		return true
+	}
+
+	var drained, expired uint64
+	defer func() {
+		if drained > 0 {
+			// metric-documentation-v2: (loggregator.metron.disk_buffer_drained)
+			// Number of persisted envelopes written to Doppler
+			metric.IncCounter("disk_buffer_drained",
+				metric.WithIncrement(drained),
+				metric.WithVersion(2, 0),
+			)
+		}
+		if expired > 0 {
+			// metric-documentation-v2: (loggregator.metron.disk_buffer_expired)
+			// Number of persisted envelopes dropped for exceeding the maximum age
+			metric.IncCounter("disk_buffer_expired",
+				metric.WithIncrement(expired),
+				metric.WithVersion(2, 0),
+			)
+		}
+	}()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err != io.EOF {
+				log.Printf("disk buffer discarding truncated segment %d: %s", id, err)
+			}
+			b.remove(id)
+			return true
+		}
+
+		// A record never exceeds MaxBytes as larger ones are not persisted.
+		// A larger length means the header is corrupt.
+		length := int64(binary.BigEndian.Uint32(header))
+		if length > b.c.MaxBytes-headerSize {
+			log.Printf("disk buffer discarding segment %d with corrupt record length %d", id, length)
+			b.remove(id)
+			return true
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			log.Printf("disk buffer discarding truncated segment %d: %s", id, err)
+			b.remove(id)
+			return true
+		}
+		recordSize := int64(headerSize + len(data))
+
+		persisted := time.Unix(0, int64(binary.BigEndian.Uint64(header[4:])))
+		if time.Since(persisted) > b.c.MaxAge {
+			expired++
+			b.advance(recordSize)
+			continue
+		}
+
+		e := &plumbing.Envelope{}
+		if err := proto.Unmarshal(data, e); err != nil {
+			log.Printf("disk buffer discarding corrupt record in segment %d: %s", id, err)
+			b.advance(recordSize)
+			continue
+		}
+
+		if err := b.send(e); err != nil {
+			return false
+		}
+		drained++
+		b.advance(recordSize)
+	}
+}
+
+// oldest returns the oldest segment and the offset of its first record
+// that has not been drained. The active segment is closed when it is the
+// only one so that draining does not wait for it to fill up.
+func (b *Buffer) oldest() (uint64, int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.segments) == 0 && b.active != nil && b.activeSize > 0 {
+		b.closeActive()
+	}
+	if len(b.segments) == 0 {
+		return 0, 0, false
+	}
+	return b.segments[0], b.offset, true
+}
+
+func (b *Buffer) advance(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offset += n
+}
+
+// remove deletes the oldest segment.
+func (b *Buffer) remove(id uint64) {
+	path := b.path(id)
+	info, err := os.Stat(path)
+	if err == nil {
+		if err := os.Remove(path); err != nil {
+			log.Printf("disk buffer failed to remove segment %d: %s", id, err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.size -= info.Size()
+	}
+	b.segments = b.segments[1:]
+	b.offset = 0
+}
+
+// Size returns the number of bytes persisted and not yet drained.
+func (b *Buffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size - b.offset
+}
+
+func (b *Buffer) path(id uint64) string {
+	return filepath.Join(b.c.Dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+type byID []uint64
+
+func (s byID) Len() int           { return len(s) }
+func (s byID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byID) Less(i, j int) bool { return s[i] < s[j] }
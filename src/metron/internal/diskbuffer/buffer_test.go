@@ -0,0 +1,178 @@
+package diskbuffer_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"metron/internal/diskbuffer"
+	plumbing "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type SpyWriter struct {
+	mu   sync.Mutex
+	err  error
+	data []string
+}
+
+func (s *SpyWriter) Write(e *plumbing.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.data = append(s.data, e.SourceId)
+	return nil
+}
+
+func (s *SpyWriter) SetErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *SpyWriter) SourceIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.data...)
+}
+
+var _ = Describe("Buffer", func() {
+	var (
+		dir    string
+		writer *SpyWriter
+		config diskbuffer.Config
+		buffer *diskbuffer.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "diskbuffer")
+		Expect(err).ToNot(HaveOccurred())
+
+		writer = &SpyWriter{}
+		config = diskbuffer.Config{
+			Dir:           dir,
+			MaxBytes:      1 << 20,
+			SegmentBytes:  100,
+			MaxAge:        time.Hour,
+			RetryInterval: time.Millisecond,
+		}
+		buffer, err = diskbuffer.New(writer, config)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(b *diskbuffer.Buffer, sourceIDs ...string) {
+		for _, id := range sourceIDs {
+			Expect(b.Write(&plumbing.Envelope{SourceId: id})).To(Succeed())
+		}
+	}
+
+	It("writes envelopes through while the writer succeeds", func() {
+		write(buffer, "a", "b")
+
+		Expect(writer.SourceIDs()).To(Equal([]string{"a", "b"}))
+		Expect(buffer.Size()).To(BeZero())
+		Expect(buffer.Drain()).To(BeFalse())
+	})
+
+	It("persists envelopes while the writer fails and drains them oldest first", func() {
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a", "b", "c", "d", "e", "f", "g", "h", "i", "j")
+		Expect(buffer.Size()).ToNot(BeZero())
+		Expect(buffer.Drain()).To(BeFalse())
+
+		writer.SetErr(nil)
+		go buffer.Start()
+
+		Eventually(writer.SourceIDs).Should(Equal([]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}))
+		Eventually(buffer.Size).Should(BeZero())
+		Eventually(func() ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }).Should(BeEmpty())
+	})
+
+	It("retries the oldest segment after a failed write", func() {
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a")
+		writer.SetErr(nil)
+		Expect(buffer.Drain()).To(BeTrue())
+
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "b", "c")
+		Expect(buffer.Drain()).To(BeFalse())
+
+		writer.SetErr(nil)
+		for buffer.Drain() {
+		}
+
+		Expect(writer.SourceIDs()).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("drains envelopes persisted before a restart", func() {
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a", "b")
+
+		restarted := &SpyWriter{}
+		buffer, err := diskbuffer.New(restarted, config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buffer.Size()).ToNot(BeZero())
+
+		for buffer.Drain() {
+		}
+		Expect(restarted.SourceIDs()).To(Equal([]string{"a", "b"}))
+	})
+
+	It("drops envelopes older than the maximum age", func() {
+		config.MaxAge = time.Millisecond
+		buffer, err := diskbuffer.New(writer, config)
+		Expect(err).ToNot(HaveOccurred())
+
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a", "b")
+		time.Sleep(10 * time.Millisecond)
+
+		writer.SetErr(nil)
+		for buffer.Drain() {
+		}
+		Expect(writer.SourceIDs()).To(BeEmpty())
+		Expect(buffer.Size()).To(BeZero())
+	})
+
+	It("discards segments with a corrupt record length", func() {
+		header := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+		path := filepath.Join(dir, "00000000000000000000.seg")
+		Expect(ioutil.WriteFile(path, header, 0600)).To(Succeed())
+
+		buffer, err := diskbuffer.New(writer, config)
+		Expect(err).ToNot(HaveOccurred())
+
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a")
+
+		writer.SetErr(nil)
+		for buffer.Drain() {
+		}
+		Expect(writer.SourceIDs()).To(Equal([]string{"a"}))
+		Expect(buffer.Size()).To(BeZero())
+		Expect(path).ToNot(BeAnExistingFile())
+	})
+
+	It("returns an error when the disk buffer is full", func() {
+		config.MaxBytes = 20
+		buffer, err := diskbuffer.New(writer, config)
+		Expect(err).ToNot(HaveOccurred())
+
+		writer.SetErr(errors.New("unable to write to any dopplers"))
+		write(buffer, "a")
+		Expect(buffer.Write(&plumbing.Envelope{SourceId: "b"})).ToNot(Succeed())
+	})
+})
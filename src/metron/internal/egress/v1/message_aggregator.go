@@ -8,22 +8,32 @@ import (
 	"sync"
 	"time"
 
+	"metron/internal/counters"
+
 	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
-var MaxTTL = time.Minute
+// MaxTTL is how long a counter may go without being updated before its
+// total is evicted. It matches the TTL of the v2 counter aggregator.
+var MaxTTL = 10 * time.Minute
+
+// MaxCounters is the maximum number of counters whose totals are tracked.
+// The least recently updated counter is evicted to make room for a new one.
+var MaxCounters = 10000
 
 type MessageAggregator struct {
-	mu            sync.Mutex
-	counterTotals map[counterID]uint64
-	outputWriter  EnvelopeWriter
+	totals       *counters.Totals
+	outputWriter EnvelopeWriter
+
+	mu         sync.Mutex
+	lastReport time.Time
 }
 
 func NewAggregator(outputWriter EnvelopeWriter) *MessageAggregator {
 	return &MessageAggregator{
-		outputWriter:  outputWriter,
-		counterTotals: make(map[counterID]uint64),
+		outputWriter: outputWriter,
+		totals:       counters.NewTotals(MaxCounters, MaxTTL),
 	}
 }
 
@@ -45,15 +55,38 @@ func (m *MessageAggregator) handleCounter(envelope *events.Envelope) *events.Env
 		tagsHash: hashTags(envelope.Tags),
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	newVal := m.counterTotals[countID] + envelope.GetCounterEvent().GetDelta()
-	m.counterTotals[countID] = newVal
+	newVal, evicted := m.totals.Add(countID.String(), envelope.GetCounterEvent().GetDelta())
+	if evicted > 0 {
+		// metric-documentation-v1: (MessageAggregator.counterEvicted) Total number of
+		// counter totals evicted by the message aggregator. Totals are evicted
+		// when a counter is not updated for 10 minutes or when more than 10000
+		// counters are tracked. The total of an evicted counter restarts from
+		// its next delta.
+		metrics.BatchAddCounter("MessageAggregator.counterEvicted", uint64(evicted))
+	}
+	m.reportCardinality()
 
 	envelope.GetCounterEvent().Total = &newVal
 	return envelope
 }
 
+// reportCardinality emits the number of tracked counters at most once a
+// second.
+func (m *MessageAggregator) reportCardinality() {
+	m.mu.Lock()
+	now := time.Now()
+	if now.Sub(m.lastReport) < time.Second {
+		m.mu.Unlock()
+		return
+	}
+	m.lastReport = now
+	m.mu.Unlock()
+
+	// metric-documentation-v1: (MessageAggregator.counters) Number of counters
+	// whose totals are tracked by the message aggregator.
+	metrics.SendValue("MessageAggregator.counters", float64(m.totals.Len()), "counters")
+}
+
 func hashTags(tags map[string]string) string {
 	hash := ""
 	elements := []mapElement{}
@@ -86,6 +119,10 @@ type counterID struct {
 	tagsHash string
 }
 
+func (c counterID) String() string {
+	return c.origin + "\x00" + c.name + "\x00" + c.tagsHash
+}
+
 type eventID struct {
 	requestID string
 	peerType  events.PeerType
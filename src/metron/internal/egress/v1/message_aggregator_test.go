@@ -173,6 +173,46 @@ var _ = Describe("MessageAggregator", func() {
 		})
 	})
 
+	Describe("counter eviction", func() {
+		var originalMaxCounters int
+
+		BeforeEach(func() {
+			originalMaxCounters = egress.MaxCounters
+		})
+
+		AfterEach(func() {
+			egress.MaxCounters = originalMaxCounters
+		})
+
+		It("resets the totals of counters idle for longer than MaxTTL", func() {
+			egress.MaxTTL = 10 * time.Millisecond
+			messageAggregator = egress.NewAggregator(mockWriter)
+
+			messageAggregator.Write(createCounterMessage("counter1", "fake-origin-4", nil))
+			time.Sleep(20 * time.Millisecond)
+			messageAggregator.Write(createCounterMessage("counter1", "fake-origin-4", nil))
+
+			Expect(mockWriter.Events).To(HaveLen(2))
+			expectCorrectCounterNameDeltaAndTotal(mockWriter.Events[1], "counter1", 4, 4)
+		})
+
+		It("evicts the least recently updated counter beyond MaxCounters", func() {
+			egress.MaxCounters = 2
+			messageAggregator = egress.NewAggregator(mockWriter)
+
+			messageAggregator.Write(createCounterMessage("counter1", "fake-origin-4", nil))
+			messageAggregator.Write(createCounterMessage("counter2", "fake-origin-4", nil))
+			messageAggregator.Write(createCounterMessage("counter1", "fake-origin-4", nil))
+			messageAggregator.Write(createCounterMessage("counter3", "fake-origin-4", nil))
+			messageAggregator.Write(createCounterMessage("counter1", "fake-origin-4", nil))
+			messageAggregator.Write(createCounterMessage("counter2", "fake-origin-4", nil))
+
+			Expect(mockWriter.Events).To(HaveLen(6))
+			expectCorrectCounterNameDeltaAndTotal(mockWriter.Events[4], "counter1", 4, 12)
+			expectCorrectCounterNameDeltaAndTotal(mockWriter.Events[5], "counter2", 4, 4)
+		})
+	})
+
 	Context("metrics", func() {
 		var (
 			fakeSender  *fake.FakeMetricSender
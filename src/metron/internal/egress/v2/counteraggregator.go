@@ -4,51 +4,108 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"io"
+	"metric"
 	"sort"
+	"sync"
+	"time"
 
+	"metron/internal/counters"
 	plumbing "plumbing/v2"
 )
 
-type counterID struct {
-	name     string
-	tagsHash string
+// CounterAggregator replaces the delta of every counter envelope with the
+// running total of the counter. Totals of counters that stop being updated
+// are evicted so that memory stays bounded.
+type CounterAggregator struct {
+	writer Writer
+	totals *counters.Totals
+
+	mu         sync.Mutex
+	lastReport time.Time
 }
 
-type CounterAggregator struct {
-	writer        Writer
-	counterTotals map[counterID]uint64
+// AggregatorOption configures a CounterAggregator.
+type AggregatorOption func(*aggregatorConfig)
+
+type aggregatorConfig struct {
+	maxCounters int
+	ttl         time.Duration
+}
+
+// WithMaxCounters sets the maximum number of counters whose totals are
+// tracked. It defaults to 10000.
+func WithMaxCounters(n int) AggregatorOption {
+	return func(c *aggregatorConfig) {
+		c.maxCounters = n
+	}
+}
+
+// WithCounterTTL sets how long a counter may go without being updated
+// before its total is evicted. It defaults to 10 minutes.
+func WithCounterTTL(d time.Duration) AggregatorOption {
+	return func(c *aggregatorConfig) {
+		c.ttl = d
+	}
 }
 
-func New(w Writer) *CounterAggregator {
+func New(w Writer, opts ...AggregatorOption) *CounterAggregator {
+	conf := aggregatorConfig{
+		maxCounters: 10000,
+		ttl:         10 * time.Minute,
+	}
+	for _, o := range opts {
+		o(&conf)
+	}
+
 	return &CounterAggregator{
-		writer:        w,
-		counterTotals: make(map[counterID]uint64),
+		writer: w,
+		totals: counters.NewTotals(conf.maxCounters, conf.ttl),
 	}
 }
 
 func (ca *CounterAggregator) Write(msg *plumbing.Envelope) error {
 	if msg.GetCounter() != nil {
-		if len(ca.counterTotals) > 10000 {
-			ca.resetTotals()
-		}
+		key := msg.GetCounter().Name + "\x00" + hashTags(msg.GetTags())
+		total, evicted := ca.totals.Add(key, msg.GetCounter().GetDelta())
 
-		id := counterID{
-			name:     msg.GetCounter().Name,
-			tagsHash: hashTags(msg.GetTags()),
+		msg.GetCounter().Value = &plumbing.Counter_Total{
+			Total: total,
 		}
 
-		ca.counterTotals[id] = ca.counterTotals[id] + msg.GetCounter().GetDelta()
-
-		msg.GetCounter().Value = &plumbing.Counter_Total{
-			Total: ca.counterTotals[id],
+		if evicted > 0 {
+			// metric-documentation-v2: (loggregator.metron.aggregator_evictions)
+			// Number of counter totals evicted from the egress aggregator.
+			// Totals are evicted when a counter is not updated for 10 minutes
+			// or when more than 10000 counters are tracked. The total of an
+			// evicted counter restarts from its next delta.
+			metric.IncCounter("aggregator_evictions",
+				metric.WithIncrement(uint64(evicted)),
+				metric.WithVersion(2, 0),
+			)
 		}
+		ca.reportCardinality()
 	}
 
 	return ca.writer.Write(msg)
 }
 
-func (ca *CounterAggregator) resetTotals() {
-	ca.counterTotals = make(map[counterID]uint64)
+// reportCardinality emits the number of tracked counters at most once a
+// second.
+func (ca *CounterAggregator) reportCardinality() {
+	ca.mu.Lock()
+	now := time.Now()
+	if now.Sub(ca.lastReport) < time.Second {
+		ca.mu.Unlock()
+		return
+	}
+	ca.lastReport = now
+	ca.mu.Unlock()
+
+	// metric-documentation-v2: (loggregator.metron.aggregator_counters) Number
+	// of counters whose totals are tracked by the egress aggregator
+	metric.SetGauge("aggregator_counters", float64(ca.totals.Len()), "counters",
+		metric.WithVersion(2, 0),
+	)
 }
 
 func hashTags(tags map[string]*plumbing.Value) string {
@@ -2,6 +2,7 @@ package v2_test
 
 import (
 	"fmt"
+	"time"
 
 	egress "metron/internal/egress/v2"
 	plumbing "plumbing/v2"
@@ -125,6 +126,45 @@ var _ = Describe("Counteraggregator", func() {
 		Expect(mockWriter.WriteInput.Msg).To(Receive(&receivedEnvelope))
 		Expect(receivedEnvelope.GetCounter().GetTotal()).To(Equal(uint64(10)))
 	})
+
+	It("keeps the totals of active counters when there are too many unique counters", func() {
+		mockWriter := newMockWriter()
+		close(mockWriter.WriteOutput.Ret0)
+
+		aggregator := egress.New(mockWriter, egress.WithMaxCounters(2))
+		aggregator.Write(buildCounterEnvelope(10, "name-1", "origin-1"))
+		aggregator.Write(buildCounterEnvelope(10, "name-2", "origin-1"))
+		aggregator.Write(buildCounterEnvelope(10, "name-1", "origin-1"))
+		aggregator.Write(buildCounterEnvelope(10, "name-3", "origin-1"))
+		aggregator.Write(buildCounterEnvelope(10, "name-1", "origin-1"))
+		aggregator.Write(buildCounterEnvelope(10, "name-2", "origin-1"))
+
+		var receivedEnvelope *plumbing.Envelope
+		for i := 0; i < 4; i++ {
+			Expect(mockWriter.WriteInput.Msg).To(Receive())
+		}
+
+		Expect(mockWriter.WriteInput.Msg).To(Receive(&receivedEnvelope))
+		Expect(receivedEnvelope.GetCounter().GetTotal()).To(Equal(uint64(30)))
+
+		Expect(mockWriter.WriteInput.Msg).To(Receive(&receivedEnvelope))
+		Expect(receivedEnvelope.GetCounter().GetTotal()).To(Equal(uint64(10)))
+	})
+
+	It("evicts the totals of idle counters", func() {
+		mockWriter := newMockWriter()
+		close(mockWriter.WriteOutput.Ret0)
+
+		aggregator := egress.New(mockWriter, egress.WithCounterTTL(10*time.Millisecond))
+		aggregator.Write(buildCounterEnvelope(10, "name-1", "origin-1"))
+		time.Sleep(20 * time.Millisecond)
+		aggregator.Write(buildCounterEnvelope(10, "name-1", "origin-1"))
+
+		var receivedEnvelope *plumbing.Envelope
+		Expect(mockWriter.WriteInput.Msg).To(Receive())
+		Expect(mockWriter.WriteInput.Msg).To(Receive(&receivedEnvelope))
+		Expect(receivedEnvelope.GetCounter().GetTotal()).To(Equal(uint64(10)))
+	})
 })
 
 func buildCounterEnvelope(delta uint64, name, origin string) *plumbing.Envelope {
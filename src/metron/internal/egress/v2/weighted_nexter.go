@@ -0,0 +1,89 @@
+package v2
+
+import (
+	"time"
+
+	plumbing "plumbing/v2"
+)
+
+type TryNexter interface {
+	TryNext() (*plumbing.Envelope, bool)
+}
+
+// WeightedQueue is a queue read by a WeightedNexter. A queue with twice
+// the weight of another is read from twice as often while both have
+// envelopes.
+type WeightedQueue struct {
+	Queue  TryNexter
+	Weight int
+}
+
+// WeightedNexter reads from several queues using smooth weighted round
+// robin so that a burst in one queue does not starve the others. A queue
+// that is empty gives up its turn to the next queue.
+type WeightedNexter struct {
+	schedule     []TryNexter
+	pos          int
+	pollInterval time.Duration
+}
+
+// NewWeightedNexter returns a WeightedNexter for the given queues. Queues
+// with a weight less than 1 are read from as if their weight was 1.
+func NewWeightedNexter(queues ...WeightedQueue) *WeightedNexter {
+	return &WeightedNexter{
+		schedule:     schedule(queues),
+		pollInterval: 10 * time.Millisecond,
+	}
+}
+
+// schedule interleaves the queues in proportion to their weights, e.g.
+// weights of 3 and 1 result in A A B A rather than A A A B.
+func schedule(queues []WeightedQueue) []TryNexter {
+	var total int
+	weights := make([]int, len(queues))
+	for i, q := range queues {
+		weights[i] = q.Weight
+		if weights[i] < 1 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+
+	current := make([]int, len(queues))
+	s := make([]TryNexter, 0, total)
+	for len(s) < total {
+		best := 0
+		for i := range queues {
+			current[i] += weights[i]
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		s = append(s, queues[best].Queue)
+	}
+	return s
+}
+
+// Next returns the next envelope. It blocks until one is available.
+func (n *WeightedNexter) Next() *plumbing.Envelope {
+	for {
+		if e, ok := n.TryNext(); ok {
+			return e
+		}
+		time.Sleep(n.pollInterval)
+	}
+}
+
+// TryNext returns the next envelope if any queue has one.
+func (n *WeightedNexter) TryNext() (*plumbing.Envelope, bool) {
+	for range n.schedule {
+		q := n.schedule[n.pos]
+		n.pos = (n.pos + 1) % len(n.schedule)
+
+		if e, ok := q.TryNext(); ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
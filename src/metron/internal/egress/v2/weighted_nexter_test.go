@@ -0,0 +1,87 @@
+package v2_test
+
+import (
+	"sync"
+
+	egress "metron/internal/egress/v2"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type SpyQueue struct {
+	mu        sync.Mutex
+	envelopes []*v2.Envelope
+}
+
+func newSpyQueue(sourceID string, n int) *SpyQueue {
+	q := &SpyQueue{}
+	for i := 0; i < n; i++ {
+		q.Set(&v2.Envelope{SourceId: sourceID})
+	}
+	return q
+}
+
+func (q *SpyQueue) Set(e *v2.Envelope) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.envelopes = append(q.envelopes, e)
+}
+
+func (q *SpyQueue) TryNext() (*v2.Envelope, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.envelopes) == 0 {
+		return nil, false
+	}
+	e := q.envelopes[0]
+	q.envelopes = q.envelopes[1:]
+	return e, true
+}
+
+var _ = Describe("WeightedNexter", func() {
+	It("interleaves the queues in proportion to their weights", func() {
+		nexter := egress.NewWeightedNexter(
+			egress.WeightedQueue{Queue: newSpyQueue("a", 10), Weight: 3},
+			egress.WeightedQueue{Queue: newSpyQueue("b", 10), Weight: 1},
+		)
+
+		var sourceIDs []string
+		for i := 0; i < 8; i++ {
+			sourceIDs = append(sourceIDs, nexter.Next().SourceId)
+		}
+
+		Expect(sourceIDs).To(Equal([]string{"a", "a", "b", "a", "a", "a", "b", "a"}))
+	})
+
+	It("gives the turn of an empty queue to the others", func() {
+		nexter := egress.NewWeightedNexter(
+			egress.WeightedQueue{Queue: newSpyQueue("a", 1), Weight: 1},
+			egress.WeightedQueue{Queue: newSpyQueue("b", 3), Weight: 1},
+		)
+
+		var sourceIDs []string
+		for i := 0; i < 4; i++ {
+			sourceIDs = append(sourceIDs, nexter.Next().SourceId)
+		}
+
+		Expect(sourceIDs).To(Equal([]string{"a", "b", "b", "b"}))
+		_, ok := nexter.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("blocks until an envelope is available", func() {
+		q := newSpyQueue("a", 0)
+		nexter := egress.NewWeightedNexter(egress.WeightedQueue{Queue: q, Weight: 1})
+
+		envelopes := make(chan *v2.Envelope, 1)
+		go func() {
+			envelopes <- nexter.Next()
+		}()
+		Consistently(envelopes).ShouldNot(Receive())
+
+		q.Set(&v2.Envelope{SourceId: "a"})
+		Eventually(envelopes).Should(Receive())
+	})
+})
@@ -0,0 +1,197 @@
+// Package enrichment adds tags loaded from a file to envelopes. The file is
+// watched and reloaded whenever it changes.
+package enrichment
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"metric"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/howeyc/fsnotify"
+	"gopkg.in/yaml.v2"
+)
+
+// maxCachedSourceIDs bounds the number of source IDs whose merged tags are
+// cached between reloads.
+const maxCachedSourceIDs = 10000
+
+// File is the format of a tags file. Tags apply to every envelope. Each
+// rule applies its tags to envelopes whose source ID matches the rule's
+// regular expression. Later rules override earlier rules and rules
+// override Tags. The file may be YAML or JSON, e.g.
+//
+//	tags:
+//	  rack: r12
+//	rules:
+//	- source_id: ^billing-
+//	  tags:
+//	    cost_center: "4711"
+type File struct {
+	Tags  map[string]string `yaml:"tags"`
+	Rules []Rule            `yaml:"rules"`
+}
+
+// Rule applies tags to envelopes whose source ID matches SourceID.
+type Rule struct {
+	SourceID string            `yaml:"source_id"`
+	Tags     map[string]string `yaml:"tags"`
+}
+
+// Enricher holds the tags of the most recently loaded tags file. It is
+// safe for concurrent use.
+type Enricher struct {
+	path  string
+	rules atomic.Value
+}
+
+type ruleSet struct {
+	tags  map[string]string
+	rules []compiledRule
+
+	mu    sync.Mutex
+	cache map[string]map[string]string
+}
+
+type compiledRule struct {
+	sourceID *regexp.Regexp
+	tags     map[string]string
+}
+
+// New returns an Enricher for the tags file at path. It fails if the file
+// exists but is invalid. A missing file adds no tags until it is created.
+func New(path string) (*Enricher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Enricher{path: path}
+	e.rules.Store(&ruleSet{})
+	if err := e.Load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Load reads the tags file. The previous tags are kept if it is invalid.
+func (e *Enricher) Load() error {
+	rs, err := load(e.path)
+	if err != nil {
+		return err
+	}
+
+	e.rules.Store(rs)
+	return nil
+}
+
+// Start reloads the tags file whenever it is created, written, renamed or
+// removed. The directory of the file is watched so that files replaced by
+// a rename are picked up. It blocks forever.
+func (e *Enricher) Start() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed to watch tags file %s: %s", e.path, err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Watch(filepath.Dir(e.path)); err != nil {
+		log.Printf("Failed to watch tags file %s: %s", e.path, err)
+		return
+	}
+
+	for {
+		select {
+		case ev := <-watcher.Event:
+			if filepath.Clean(ev.Name) != e.path {
+				continue
+			}
+
+			if err := e.Load(); err != nil {
+				// metric-documentation-v2: (loggregator.metron.tags_file_errors)
+				// Number of times the tags file failed to reload
+				metric.IncCounter("tags_file_errors", metric.WithVersion(2, 0))
+				log.Printf("Failed to reload tags file, keeping previous tags: %s", err)
+				continue
+			}
+			log.Printf("Reloaded tags file %s", e.path)
+		case err := <-watcher.Error:
+			log.Printf("Error watching tags file %s: %s", e.path, err)
+		}
+	}
+}
+
+// Tags returns the tags for envelopes with the given source ID. The
+// returned map is shared and must not be modified.
+func (e *Enricher) Tags(sourceID string) map[string]string {
+	return e.rules.Load().(*ruleSet).tagsFor(sourceID)
+}
+
+func (rs *ruleSet) tagsFor(sourceID string) map[string]string {
+	if len(rs.rules) == 0 {
+		return rs.tags
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if tags, ok := rs.cache[sourceID]; ok {
+		return tags
+	}
+
+	tags := make(map[string]string, len(rs.tags))
+	for k, v := range rs.tags {
+		tags[k] = v
+	}
+	for _, r := range rs.rules {
+		if !r.sourceID.MatchString(sourceID) {
+			continue
+		}
+		for k, v := range r.tags {
+			tags[k] = v
+		}
+	}
+
+	if len(rs.cache) >= maxCachedSourceIDs {
+		rs.cache = make(map[string]map[string]string)
+	}
+	rs.cache[sourceID] = tags
+	return tags
+}
+
+func load(path string) (*ruleSet, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ruleSet{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid tags file %s: %s", path, err)
+	}
+
+	rs := &ruleSet{
+		tags:  f.Tags,
+		cache: make(map[string]map[string]string),
+	}
+	for i, r := range f.Rules {
+		re, err := regexp.Compile(r.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source_id of rule %d in %s: %s", i, path, err)
+		}
+		rs.rules = append(rs.rules, compiledRule{
+			sourceID: re,
+			tags:     r.Tags,
+		})
+	}
+	return rs, nil
+}
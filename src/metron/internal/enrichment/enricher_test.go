@@ -0,0 +1,120 @@
+package enrichment_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"metron/internal/enrichment"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Enricher", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "enrichment")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "tags.yml")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writeFile := func(content string) {
+		tmp := filepath.Join(dir, "tags.tmp")
+		Expect(ioutil.WriteFile(tmp, []byte(content), 0644)).To(Succeed())
+		Expect(os.Rename(tmp, path)).To(Succeed())
+	}
+
+	It("applies global tags and the tags of matching rules in order", func() {
+		writeFile(`
+tags:
+  rack: r12
+  tier: standard
+rules:
+- source_id: ^billing-
+  tags:
+    cost_center: 4711
+    tier: gold
+- source_id: -worker$
+  tags:
+    tier: batch
+`)
+		e, err := enrichment.New(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(e.Tags("web")).To(Equal(map[string]string{
+			"rack": "r12",
+			"tier": "standard",
+		}))
+		Expect(e.Tags("billing-api")).To(Equal(map[string]string{
+			"rack":        "r12",
+			"tier":        "gold",
+			"cost_center": "4711",
+		}))
+		Expect(e.Tags("billing-worker")).To(Equal(map[string]string{
+			"rack":        "r12",
+			"tier":        "batch",
+			"cost_center": "4711",
+		}))
+	})
+
+	It("loads JSON files", func() {
+		writeFile(`{"tags": {"rack": "r12"}, "rules": [{"source_id": "^app$", "tags": {"zone": "z1"}}]}`)
+
+		e, err := enrichment.New(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Tags("app")).To(Equal(map[string]string{
+			"rack": "r12",
+			"zone": "z1",
+		}))
+	})
+
+	It("adds no tags while the file is missing", func() {
+		e, err := enrichment.New(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Tags("app")).To(BeEmpty())
+	})
+
+	It("fails for an invalid file", func() {
+		writeFile("rules:\n- source_id: \"(\"\n")
+
+		_, err := enrichment.New(path)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps the previous tags when a reload fails", func() {
+		writeFile("tags: {rack: r12}")
+		e, err := enrichment.New(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("tags: [")
+		Expect(e.Load()).ToNot(Succeed())
+		Expect(e.Tags("app")).To(Equal(map[string]string{"rack": "r12"}))
+	})
+
+	It("reloads the file when it changes", func() {
+		writeFile("tags: {rack: r12}")
+		e, err := enrichment.New(path)
+		Expect(err).ToNot(HaveOccurred())
+		go e.Start()
+
+		Eventually(func() map[string]string {
+			writeFile("tags: {rack: r13}")
+			return e.Tags("app")
+		}).Should(Equal(map[string]string{"rack": "r13"}))
+
+		os.Remove(path)
+		Eventually(func() map[string]string {
+			return e.Tags("app")
+		}).Should(BeEmpty())
+	})
+})
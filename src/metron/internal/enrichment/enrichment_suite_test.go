@@ -0,0 +1,13 @@
+package enrichment_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEnrichment(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Enrichment Suite")
+}
@@ -0,0 +1,46 @@
+package enrichment
+
+import (
+	"github.com/cloudfoundry/dropsonde/envelope_extensions"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type EnvelopeWriter interface {
+	Write(*events.Envelope)
+}
+
+// V1Writer adds the tags for the source ID of each envelope before writing
+// it to the next writer. The source ID is the app ID of app events and the
+// origin of every other event. Tags already on an envelope are not
+// overwritten.
+type V1Writer struct {
+	next     EnvelopeWriter
+	enricher *Enricher
+}
+
+func NewV1Writer(next EnvelopeWriter, e *Enricher) *V1Writer {
+	return &V1Writer{
+		next:     next,
+		enricher: e,
+	}
+}
+
+func (w *V1Writer) Write(e *events.Envelope) {
+	sourceID := envelope_extensions.GetAppId(e)
+	if sourceID == envelope_extensions.SystemAppId {
+		sourceID = e.GetOrigin()
+	}
+
+	tags := w.enricher.Tags(sourceID)
+	if len(tags) > 0 && e.Tags == nil {
+		e.Tags = make(map[string]string, len(tags))
+	}
+
+	for k, v := range tags {
+		if _, ok := e.Tags[k]; ok {
+			continue
+		}
+		e.Tags[k] = v
+	}
+	w.next.Write(e)
+}
@@ -0,0 +1,83 @@
+package enrichment_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"metron/internal/enrichment"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("V1Writer", func() {
+	var (
+		file      *os.File
+		spyWriter *SpyEnvelopeWriter
+		writer    *enrichment.V1Writer
+	)
+
+	BeforeEach(func() {
+		var err error
+		file, err = ioutil.TempFile("", "tags")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.WriteString(`
+rules:
+- source_id: ^app-1$
+  tags:
+    team: payments
+- source_id: ^some-origin$
+  tags:
+    team: platform
+`)
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		e, err := enrichment.New(file.Name())
+		Expect(err).ToNot(HaveOccurred())
+
+		spyWriter = &SpyEnvelopeWriter{}
+		writer = enrichment.NewV1Writer(spyWriter, e)
+	})
+
+	AfterEach(func() {
+		os.Remove(file.Name())
+	})
+
+	It("matches app events by app ID", func() {
+		writer.Write(&events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_LogMessage.Enum(),
+			LogMessage: &events.LogMessage{
+				Message:     []byte("some-message"),
+				MessageType: events.LogMessage_OUT.Enum(),
+				Timestamp:   proto.Int64(0),
+				AppId:       proto.String("app-1"),
+			},
+		})
+
+		Expect(spyWriter.envelopes).To(HaveLen(1))
+		Expect(spyWriter.envelopes[0].GetTags()).To(Equal(map[string]string{"team": "payments"}))
+	})
+
+	It("matches other events by origin", func() {
+		writer.Write(&events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_ValueMetric.Enum(),
+		})
+
+		Expect(spyWriter.envelopes).To(HaveLen(1))
+		Expect(spyWriter.envelopes[0].GetTags()).To(Equal(map[string]string{"team": "platform"}))
+	})
+})
+
+type SpyEnvelopeWriter struct {
+	envelopes []*events.Envelope
+}
+
+func (s *SpyEnvelopeWriter) Write(e *events.Envelope) {
+	s.envelopes = append(s.envelopes, e)
+}
@@ -0,0 +1,40 @@
+package enrichment
+
+import v2 "plumbing/v2"
+
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// V2Setter adds the tags for the source ID of each envelope before setting
+// it on the next setter. Tags already on an envelope are not overwritten.
+type V2Setter struct {
+	next     DataSetter
+	enricher *Enricher
+}
+
+func NewV2Setter(next DataSetter, e *Enricher) *V2Setter {
+	return &V2Setter{
+		next:     next,
+		enricher: e,
+	}
+}
+
+func (s *V2Setter) Set(e *v2.Envelope) {
+	tags := s.enricher.Tags(e.SourceId)
+	if len(tags) > 0 && e.Tags == nil {
+		e.Tags = make(map[string]*v2.Value, len(tags))
+	}
+
+	for k, v := range tags {
+		if _, ok := e.Tags[k]; ok {
+			continue
+		}
+		e.Tags[k] = &v2.Value{
+			Data: &v2.Value_Text{
+				Text: v,
+			},
+		}
+	}
+	s.next.Set(e)
+}
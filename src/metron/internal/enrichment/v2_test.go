@@ -0,0 +1,77 @@
+package enrichment_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"metron/internal/enrichment"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("V2Setter", func() {
+	var (
+		file      *os.File
+		spySetter *SpySetter
+		setter    *enrichment.V2Setter
+	)
+
+	BeforeEach(func() {
+		var err error
+		file, err = ioutil.TempFile("", "tags")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.WriteString(`
+tags:
+  rack: r12
+rules:
+- source_id: ^app-1$
+  tags:
+    team: payments
+`)
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		e, err := enrichment.New(file.Name())
+		Expect(err).ToNot(HaveOccurred())
+
+		spySetter = &SpySetter{}
+		setter = enrichment.NewV2Setter(spySetter, e)
+	})
+
+	AfterEach(func() {
+		os.Remove(file.Name())
+	})
+
+	It("adds the tags for the source ID", func() {
+		setter.Set(&v2.Envelope{SourceId: "app-1"})
+		setter.Set(&v2.Envelope{SourceId: "app-2"})
+
+		Expect(spySetter.envelopes).To(HaveLen(2))
+		Expect(spySetter.envelopes[0].Tags).To(HaveLen(2))
+		Expect(spySetter.envelopes[0].Tags["rack"].GetText()).To(Equal("r12"))
+		Expect(spySetter.envelopes[0].Tags["team"].GetText()).To(Equal("payments"))
+		Expect(spySetter.envelopes[1].Tags).To(HaveLen(1))
+		Expect(spySetter.envelopes[1].Tags["rack"].GetText()).To(Equal("r12"))
+	})
+
+	It("does not overwrite tags of the envelope", func() {
+		setter.Set(&v2.Envelope{
+			SourceId: "app-1",
+			Tags: map[string]*v2.Value{
+				"rack": {Data: &v2.Value_Text{Text: "r1"}},
+			},
+		})
+
+		Expect(spySetter.envelopes[0].Tags["rack"].GetText()).To(Equal("r1"))
+	})
+})
+
+type SpySetter struct {
+	envelopes []*v2.Envelope
+}
+
+func (s *SpySetter) Set(e *v2.Envelope) {
+	s.envelopes = append(s.envelopes, e)
+}
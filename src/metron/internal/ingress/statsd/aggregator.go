@@ -0,0 +1,322 @@
+package statsd
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"metric"
+	"sync"
+	"time"
+
+	v2 "plumbing/v2"
+)
+
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// Aggregator aggregates StatsD samples over a flush interval. At the end of
+// each interval it sets a counter envelope with the sum of each counter, a
+// gauge envelope with the latest value of each gauge and the number of
+// unique values of each set, and a timer envelope for each timing. Timings
+// are also counted in a counter <name>_count that is scaled by their sample
+// rate. Counter deltas are turned into totals further down the pipeline.
+type Aggregator struct {
+	setter    DataSetter
+	sourceID  string
+	interval  time.Duration
+	maxSeries int
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	sets     map[string]*set
+	timers   map[string]*timer
+	dropped  uint64
+
+	// lastGauges holds the last value of every gauge set within gaugeTTL so
+	// that relative updates can be applied to it.
+	lastGauges map[string]lastGauge
+}
+
+// gaugeTTL is how long the last value of a gauge that is not updated is
+// kept as the base of relative updates.
+const gaugeTTL = 10 * time.Minute
+
+type lastGauge struct {
+	value   float64
+	updated time.Time
+}
+
+type counter struct {
+	sample
+	sum float64
+}
+
+type gauge struct {
+	sample
+	value float64
+}
+
+type set struct {
+	sample
+	values map[string]struct{}
+}
+
+// maxTimings is the number of timings per timer series that are set as
+// timer envelopes each interval. Further timings replace kept ones at
+// random.
+const maxTimings = 1000
+
+type timer struct {
+	sample
+	count   float64
+	seen    int
+	timings []timing
+}
+
+type timing struct {
+	stop     time.Time
+	duration time.Duration
+}
+
+func (t *timer) add(s sample) {
+	t.count += 1 / s.sampleRate
+	t.seen++
+
+	tm := timing{
+		stop:     time.Now(),
+		duration: time.Duration(s.value * float64(time.Millisecond)),
+	}
+	if len(t.timings) < maxTimings {
+		t.timings = append(t.timings, tm)
+		return
+	}
+	if i := rand.Intn(t.seen); i < maxTimings {
+		t.timings[i] = tm
+	}
+}
+
+// NewAggregator returns an Aggregator that sets envelopes with the given
+// source ID every interval. At most maxSeries distinct series are kept per
+// interval. Samples of further series are dropped.
+func NewAggregator(setter DataSetter, sourceID string, interval time.Duration, maxSeries int) *Aggregator {
+	return &Aggregator{
+		setter:     setter,
+		sourceID:   sourceID,
+		interval:   interval,
+		maxSeries:  maxSeries,
+		counters:   make(map[string]*counter),
+		gauges:     make(map[string]*gauge),
+		sets:       make(map[string]*set),
+		timers:     make(map[string]*timer),
+		lastGauges: make(map[string]lastGauge),
+	}
+}
+
+// Add parses a single StatsD line and records it.
+func (a *Aggregator) Add(line string) error {
+	s, err := parse(line)
+	if err != nil {
+		return err
+	}
+
+	a.add(s)
+	return nil
+}
+
+func (a *Aggregator) add(s sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := s.key()
+	if !a.exists(s.metricType, key) {
+		if a.series() >= a.maxSeries {
+			a.dropped++
+			return
+		}
+		a.create(s, key)
+	}
+
+	switch s.metricType {
+	case counterType:
+		a.counters[key].sum += s.value / s.sampleRate
+	case gaugeType:
+		g := a.gauges[key]
+		if s.relative {
+			g.value += s.value
+			return
+		}
+		g.value = s.value
+	case setType:
+		a.sets[key].values[s.setValue] = struct{}{}
+	case timerType:
+		a.timers[key].add(s)
+	}
+}
+
+func (a *Aggregator) exists(t metricType, key string) bool {
+	var ok bool
+	switch t {
+	case counterType:
+		_, ok = a.counters[key]
+	case gaugeType:
+		_, ok = a.gauges[key]
+	case setType:
+		_, ok = a.sets[key]
+	case timerType:
+		_, ok = a.timers[key]
+	}
+	return ok
+}
+
+func (a *Aggregator) create(s sample, key string) {
+	switch s.metricType {
+	case counterType:
+		a.counters[key] = &counter{sample: s}
+	case gaugeType:
+		a.gauges[key] = &gauge{sample: s, value: a.lastGauges[key].value}
+	case setType:
+		a.sets[key] = &set{sample: s, values: make(map[string]struct{})}
+	case timerType:
+		a.timers[key] = &timer{sample: s}
+	}
+}
+
+func (a *Aggregator) series() int {
+	return len(a.counters) + len(a.gauges) + len(a.sets) + len(a.timers)
+}
+
+// Start flushes every interval. It blocks forever.
+func (a *Aggregator) Start() {
+	for range time.Tick(a.interval) {
+		a.Flush()
+	}
+}
+
+// Flush sets the envelopes for every series recorded since the last flush.
+func (a *Aggregator) Flush() {
+	a.mu.Lock()
+	counters, gauges, sets, timers := a.counters, a.gauges, a.sets, a.timers
+	dropped := a.dropped
+	a.counters = make(map[string]*counter)
+	a.gauges = make(map[string]*gauge)
+	a.sets = make(map[string]*set)
+	a.timers = make(map[string]*timer)
+	a.dropped = 0
+
+	now := time.Now()
+	for k, g := range a.lastGauges {
+		if now.Sub(g.updated) > gaugeTTL {
+			delete(a.lastGauges, k)
+		}
+	}
+	for k, g := range gauges {
+		a.lastGauges[k] = lastGauge{value: g.value, updated: now}
+	}
+	a.mu.Unlock()
+
+	if dropped > 0 {
+		// metric-documentation-v2: (loggregator.metron.statsd_dropped) Number
+		// of StatsD samples dropped for exceeding the series limit
+		metric.IncCounter("statsd_dropped",
+			metric.WithIncrement(dropped),
+			metric.WithVersion(2, 0),
+		)
+		log.Printf("dropped %d StatsD samples exceeding the limit of %d series", dropped, a.maxSeries)
+	}
+
+	var negative uint64
+	for _, c := range counters {
+		if c.sum < 0 {
+			negative++
+			continue
+		}
+		a.setCounter(now, c.sample, c.name, c.sum)
+	}
+
+	if negative > 0 {
+		// metric-documentation-v2: (loggregator.metron.statsd_negative_counters)
+		// Number of StatsD counters dropped because their sum over a flush
+		// interval was negative. v2 counter deltas cannot be negative.
+		metric.IncCounter("statsd_negative_counters",
+			metric.WithIncrement(negative),
+			metric.WithVersion(2, 0),
+		)
+		log.Printf("dropped %d StatsD counters with a negative sum", negative)
+	}
+
+	for _, g := range gauges {
+		a.setter.Set(a.newGauge(now, g.sample, g.value, "gauge"))
+	}
+
+	for _, s := range sets {
+		a.setter.Set(a.newGauge(now, s.sample, float64(len(s.values)), "count"))
+	}
+
+	for _, t := range timers {
+		a.setCounter(now, t.sample, t.name+"_count", t.count)
+
+		for _, tm := range t.timings {
+			e := a.newEnvelope(tm.stop, t.tags)
+			e.Message = &v2.Envelope_Timer{
+				Timer: &v2.Timer{
+					Name:  t.name,
+					Start: tm.stop.Add(-tm.duration).UnixNano(),
+					Stop:  tm.stop.UnixNano(),
+				},
+			}
+			a.setter.Set(e)
+		}
+	}
+}
+
+func (a *Aggregator) setCounter(now time.Time, s sample, name string, sum float64) {
+	delta := uint64(math.Floor(sum + 0.5))
+	if delta == 0 {
+		return
+	}
+
+	e := a.newEnvelope(now, s.tags)
+	e.Message = &v2.Envelope_Counter{
+		Counter: &v2.Counter{
+			Name: name,
+			Value: &v2.Counter_Delta{
+				Delta: delta,
+			},
+		},
+	}
+	a.setter.Set(e)
+}
+
+func (a *Aggregator) newGauge(now time.Time, s sample, value float64, unit string) *v2.Envelope {
+	e := a.newEnvelope(now, s.tags)
+	e.Message = &v2.Envelope_Gauge{
+		Gauge: &v2.Gauge{
+			Metrics: map[string]*v2.GaugeValue{
+				s.name: {
+					Unit:  unit,
+					Value: value,
+				},
+			},
+		},
+	}
+	return e
+}
+
+func (a *Aggregator) newEnvelope(t time.Time, tags map[string]string) *v2.Envelope {
+	envelopeTags := make(map[string]*v2.Value, len(tags))
+	for k, v := range tags {
+		envelopeTags[k] = &v2.Value{
+			Data: &v2.Value_Text{
+				Text: v,
+			},
+		}
+	}
+
+	return &v2.Envelope{
+		SourceId:  a.sourceID,
+		Timestamp: t.UnixNano(),
+		Tags:      envelopeTags,
+	}
+}
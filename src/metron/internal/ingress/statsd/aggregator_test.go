@@ -0,0 +1,206 @@
+package statsd_test
+
+import (
+	"time"
+
+	"metron/internal/ingress/statsd"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Aggregator", func() {
+	var (
+		setter     *mockDataSetter
+		aggregator *statsd.Aggregator
+	)
+
+	BeforeEach(func() {
+		setter = newMockDataSetter()
+		aggregator = statsd.NewAggregator(setter, "some-source-id", time.Hour, 3)
+	})
+
+	flush := func() []*v2.Envelope {
+		aggregator.Flush()
+
+		var envelopes []*v2.Envelope
+		for len(setter.SetInput.E) > 0 {
+			envelopes = append(envelopes, <-setter.SetInput.E)
+		}
+		return envelopes
+	}
+
+	It("sums counters and scales them by their sample rate", func() {
+		Expect(aggregator.Add("requests:1|c")).To(Succeed())
+		Expect(aggregator.Add("requests:2|c|@0.5")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].SourceId).To(Equal("some-source-id"))
+		Expect(envelopes[0].GetCounter().Name).To(Equal("requests"))
+		Expect(envelopes[0].GetCounter().GetDelta()).To(Equal(uint64(5)))
+	})
+
+	It("sums negative counter samples and drops counters with a negative sum", func() {
+		Expect(aggregator.Add("requests:3|c")).To(Succeed())
+		Expect(aggregator.Add("requests:-1|c")).To(Succeed())
+		Expect(aggregator.Add("errors:-1|c")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].GetCounter().Name).To(Equal("requests"))
+		Expect(envelopes[0].GetCounter().GetDelta()).To(Equal(uint64(2)))
+	})
+
+	It("keeps the latest value of gauges", func() {
+		Expect(aggregator.Add("temperature:10|g")).To(Succeed())
+		Expect(aggregator.Add("temperature:20|g")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].GetGauge().Metrics).To(HaveKeyWithValue("temperature", &v2.GaugeValue{
+			Unit:  "gauge",
+			Value: 20,
+		}))
+	})
+
+	It("applies relative gauge updates to the previous interval", func() {
+		Expect(aggregator.Add("temperature:10|g")).To(Succeed())
+		flush()
+
+		Expect(aggregator.Add("temperature:+5|g")).To(Succeed())
+		Expect(aggregator.Add("temperature:-2|g")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].GetGauge().Metrics["temperature"].Value).To(Equal(13.0))
+	})
+
+	It("applies relative gauge updates to the last value after idle intervals", func() {
+		Expect(aggregator.Add("temperature:10|g")).To(Succeed())
+		flush()
+		Expect(flush()).To(BeEmpty())
+
+		Expect(aggregator.Add("temperature:+5|g")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].GetGauge().Metrics["temperature"].Value).To(Equal(15.0))
+	})
+
+	It("counts the unique values of sets", func() {
+		Expect(aggregator.Add("users:alice|s")).To(Succeed())
+		Expect(aggregator.Add("users:bob|s")).To(Succeed())
+		Expect(aggregator.Add("users:alice|s")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(1))
+		Expect(envelopes[0].GetGauge().Metrics).To(HaveKeyWithValue("users", &v2.GaugeValue{
+			Unit:  "count",
+			Value: 2,
+		}))
+	})
+
+	It("sets a timer for every timing", func() {
+		Expect(aggregator.Add("latency:250|ms")).To(Succeed())
+		Expect(aggregator.Add("latency:100|h")).To(Succeed())
+
+		var timers []*v2.Timer
+		for _, e := range flush() {
+			if e.GetTimer() != nil {
+				timers = append(timers, e.GetTimer())
+			}
+		}
+		Expect(timers).To(HaveLen(2))
+		Expect(timers[0].Name).To(Equal("latency"))
+		Expect(timers[0].Stop - timers[0].Start).To(Equal(int64(250 * time.Millisecond)))
+		Expect(timers[1].Stop - timers[1].Start).To(Equal(int64(100 * time.Millisecond)))
+	})
+
+	It("counts timings and scales them by their sample rate", func() {
+		Expect(aggregator.Add("latency:250|ms")).To(Succeed())
+		Expect(aggregator.Add("latency:100|h|@0.5")).To(Succeed())
+
+		var counter *v2.Counter
+		for _, e := range flush() {
+			if e.GetCounter() != nil {
+				counter = e.GetCounter()
+			}
+		}
+		Expect(counter.Name).To(Equal("latency_count"))
+		Expect(counter.GetDelta()).To(Equal(uint64(3)))
+	})
+
+	It("sets a bounded number of timers", func() {
+		spy := &spySetter{}
+		aggregator = statsd.NewAggregator(spy, "some-source-id", time.Hour, 3)
+		for i := 0; i < 5000; i++ {
+			Expect(aggregator.Add("latency:1|ms")).To(Succeed())
+		}
+		aggregator.Flush()
+
+		var timers int
+		for _, e := range spy.envelopes {
+			if e.GetTimer() != nil {
+				timers++
+			}
+			if e.GetCounter() != nil {
+				Expect(e.GetCounter().GetDelta()).To(Equal(uint64(5000)))
+			}
+		}
+		Expect(timers).To(Equal(1000))
+	})
+
+	It("converts DogStatsD tags and keeps series with different tags apart", func() {
+		Expect(aggregator.Add("requests:1|c|#status:200,canary")).To(Succeed())
+		Expect(aggregator.Add("requests:1|c|#status:500")).To(Succeed())
+
+		envelopes := flush()
+		Expect(envelopes).To(HaveLen(2))
+
+		tags := map[string]map[string]*v2.Value{}
+		for _, e := range envelopes {
+			tags[e.Tags["status"].GetText()] = e.Tags
+		}
+		Expect(tags["200"]).To(HaveKey("canary"))
+		Expect(tags["500"]).To(HaveLen(1))
+	})
+
+	It("drops samples of series beyond the limit", func() {
+		Expect(aggregator.Add("a:1|c")).To(Succeed())
+		Expect(aggregator.Add("b:1|c")).To(Succeed())
+		Expect(aggregator.Add("c:1|c")).To(Succeed())
+		Expect(aggregator.Add("d:1|c")).To(Succeed())
+		Expect(aggregator.Add("a:1|c")).To(Succeed())
+
+		Expect(flush()).To(HaveLen(3))
+	})
+
+	It("starts each interval empty", func() {
+		Expect(aggregator.Add("requests:1|c")).To(Succeed())
+		flush()
+
+		Expect(flush()).To(BeEmpty())
+	})
+
+	DescribeTable("rejects invalid lines", func(line string) {
+		Expect(aggregator.Add(line)).ToNot(Succeed())
+	},
+		Entry("no name", ":1|c"),
+		Entry("no type", "requests:1"),
+		Entry("unknown type", "requests:1|x"),
+		Entry("invalid value", "requests:one|c"),
+		Entry("invalid sample rate", "requests:1|c|@2"),
+		Entry("unknown field", "requests:1|c|foo"),
+	)
+})
+
+type spySetter struct {
+	envelopes []*v2.Envelope
+}
+
+func (s *spySetter) Set(e *v2.Envelope) {
+	s.envelopes = append(s.envelopes, e)
+}
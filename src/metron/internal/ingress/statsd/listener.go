@@ -0,0 +1,83 @@
+package statsd
+
+import (
+	"log"
+	"metric"
+	"net"
+	"strings"
+)
+
+// Listener reads StatsD datagrams. A datagram may contain several
+// newline separated metrics.
+type Listener struct {
+	conn       net.PacketConn
+	aggregator *Aggregator
+}
+
+// NewListener listens for StatsD datagrams on addr and adds every metric
+// they contain to the aggregator.
+func NewListener(addr string, a *Aggregator) (*Listener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("StatsD listening on %s", conn.LocalAddr())
+
+	return &Listener{
+		conn:       conn,
+		aggregator: a,
+	}, nil
+}
+
+// Addr returns the address the listener is bound to.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Start reads datagrams until the listener is stopped.
+func (l *Listener) Start() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("StatsD listener stopped: %s", err)
+			return
+		}
+
+		var received, invalid uint64
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			if err := l.aggregator.Add(line); err != nil {
+				invalid++
+				continue
+			}
+			received++
+		}
+
+		if received > 0 {
+			// metric-documentation-v2: (loggregator.metron.statsd_ingress) Number
+			// of StatsD metrics received
+			metric.IncCounter("statsd_ingress",
+				metric.WithIncrement(received),
+				metric.WithVersion(2, 0),
+			)
+		}
+		if invalid > 0 {
+			// metric-documentation-v2: (loggregator.metron.statsd_invalid)
+			// Number of StatsD metrics that could not be parsed
+			metric.IncCounter("statsd_invalid",
+				metric.WithIncrement(invalid),
+				metric.WithVersion(2, 0),
+			)
+		}
+	}
+}
+
+// Stop closes the listener.
+func (l *Listener) Stop() {
+	l.conn.Close()
+}
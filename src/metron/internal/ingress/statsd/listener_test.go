@@ -0,0 +1,49 @@
+package statsd_test
+
+import (
+	"net"
+	"time"
+
+	"metron/internal/ingress/statsd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener", func() {
+	It("adds every metric of a datagram to the aggregator", func() {
+		setter := newMockDataSetter()
+		aggregator := statsd.NewAggregator(setter, "some-source-id", 10*time.Millisecond, 100)
+		go aggregator.Start()
+
+		l, err := statsd.NewListener("127.0.0.1:0", aggregator)
+		Expect(err).ToNot(HaveOccurred())
+		go l.Start()
+		defer l.Stop()
+
+		conn, err := net.Dial("udp", l.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("requests:1|c\ninvalid\ntemperature:10|g\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		names := make(map[string]bool)
+		Eventually(func() map[string]bool {
+			select {
+			case e := <-setter.SetInput.E:
+				if e.GetCounter() != nil {
+					names[e.GetCounter().Name] = true
+				}
+				for name := range e.GetGauge().GetMetrics() {
+					names[name] = true
+				}
+			default:
+			}
+			return names
+		}).Should(Equal(map[string]bool{
+			"requests":    true,
+			"temperature": true,
+		}))
+	})
+})
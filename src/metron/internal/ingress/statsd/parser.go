@@ -0,0 +1,129 @@
+// Package statsd receives StatsD metrics over UDP and converts them to v2
+// counters, gauges and timers.
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type metricType int
+
+const (
+	counterType metricType = iota
+	gaugeType
+	timerType
+	setType
+)
+
+// sample is a single parsed StatsD metric.
+type sample struct {
+	name       string
+	metricType metricType
+	value      float64
+	setValue   string
+	sampleRate float64
+
+	// relative is true for gauges that are set to a delta ("+1" or "-1")
+	// of their previous value.
+	relative bool
+
+	tags map[string]string
+}
+
+// parse parses a StatsD line of the form
+// <name>:<value>|<type>[|@<sample rate>][|#<tag>:<value>,<tag>]. Tags use the
+// DogStatsD syntax. Histograms ("h") are treated as timers.
+func parse(line string) (sample, error) {
+	colon := strings.LastIndex(strings.SplitN(line, "|", 2)[0], ":")
+	if colon < 1 {
+		return sample{}, errors.New("missing metric name")
+	}
+
+	s := sample{
+		name:       line[:colon],
+		sampleRate: 1,
+	}
+
+	fields := strings.Split(line[colon+1:], "|")
+	if len(fields) < 2 {
+		return sample{}, errors.New("missing metric type")
+	}
+	value := fields[0]
+
+	switch fields[1] {
+	case "c":
+		s.metricType = counterType
+	case "g":
+		s.metricType = gaugeType
+		s.relative = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+	case "ms", "h":
+		s.metricType = timerType
+	case "s":
+		s.metricType = setType
+		s.setValue = value
+	default:
+		return sample{}, fmt.Errorf("unknown metric type %q", fields[1])
+	}
+
+	if s.metricType != setType {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return sample{}, fmt.Errorf("invalid value %q", value)
+		}
+		s.value = v
+	}
+
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, "@"):
+			rate, err := strconv.ParseFloat(f[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample{}, fmt.Errorf("invalid sample rate %q", f[1:])
+			}
+			s.sampleRate = rate
+		case strings.HasPrefix(f, "#"):
+			s.tags = parseTags(f[1:])
+		default:
+			return sample{}, fmt.Errorf("unknown field %q", f)
+		}
+	}
+
+	return s, nil
+}
+
+// parseTags parses comma separated DogStatsD tags. Tags without a value
+// are given an empty value.
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, t := range strings.Split(s, ",") {
+		if t == "" {
+			continue
+		}
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) == 1 {
+			tags[kv[0]] = ""
+			continue
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags
+}
+
+// key identifies the series of a sample by its name and tags.
+func (s sample) key() string {
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{s.name}
+	for _, k := range keys {
+		parts = append(parts, k+"="+s.tags[k])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,199 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"metric"
+	"net"
+	"strconv"
+	"time"
+
+	v2 "plumbing/v2"
+)
+
+// maxMessageSize is the largest syslog message that is accepted. It matches
+// the largest UDP datagram.
+const maxMessageSize = 65535
+
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// UDPListener receives one syslog message per datagram.
+type UDPListener struct {
+	conn   net.PacketConn
+	setter DataSetter
+}
+
+// NewUDPListener listens for syslog datagrams on addr.
+func NewUDPListener(addr string, setter DataSetter) (*UDPListener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("syslog UDP listening on %s", conn.LocalAddr())
+
+	return &UDPListener{
+		conn:   conn,
+		setter: setter,
+	}, nil
+}
+
+// Addr returns the address the listener is bound to.
+func (l *UDPListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Start reads datagrams until the listener is stopped.
+func (l *UDPListener) Start() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("syslog UDP listener stopped: %s", err)
+			return
+		}
+
+		set(l.setter, buf[:n], "udp")
+	}
+}
+
+// Stop closes the listener.
+func (l *UDPListener) Stop() {
+	l.conn.Close()
+}
+
+// TCPListener receives syslog messages over TCP, optionally with TLS. Each
+// connection may frame its messages with octet counting (RFC6587 3.4.1) or
+// newlines (RFC6587 3.4.2). The framing is detected from the first byte of
+// each message.
+type TCPListener struct {
+	lis      net.Listener
+	setter   DataSetter
+	protocol string
+}
+
+// NewTCPListener listens for syslog connections on addr. Connections use TLS
+// if tlsConfig is not nil.
+func NewTCPListener(addr string, tlsConfig *tls.Config, setter DataSetter) (*TCPListener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := "tcp"
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+		protocol = "tls"
+	}
+	log.Printf("syslog %s listening on %s", protocol, lis.Addr())
+
+	return &TCPListener{
+		lis:      lis,
+		setter:   setter,
+		protocol: protocol,
+	}, nil
+}
+
+// Addr returns the address the listener is bound to.
+func (l *TCPListener) Addr() net.Addr {
+	return l.lis.Addr()
+}
+
+// Start accepts connections until the listener is stopped.
+func (l *TCPListener) Start() {
+	for {
+		conn, err := l.lis.Accept()
+		if err != nil {
+			log.Printf("syslog %s listener stopped: %s", l.protocol, err)
+			return
+		}
+
+		go l.handle(conn)
+	}
+}
+
+// Stop closes the listener. Open connections are closed by their clients.
+func (l *TCPListener) Stop() {
+	l.lis.Close()
+}
+
+func (l *TCPListener) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		msg, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("syslog %s connection from %s closed: %s", l.protocol, conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		set(l.setter, msg, l.protocol)
+	}
+}
+
+// readFrame reads a single message. Octet counted messages start with their
+// length whereas newline delimited messages start with '<'.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] < '0' || first[0] > '9' {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, errors.New("message exceeds maximum size")
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		return line, nil
+	}
+
+	header, err := r.ReadSlice(' ')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(string(bytes.TrimSuffix(header, []byte(" "))))
+	if err != nil || n < 1 || n > maxMessageSize {
+		return nil, errors.New("invalid octet count")
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// set parses msg and hands the envelope to the setter. The envelope payload
+// refers to msg so it is copied out of the read buffer first.
+func set(setter DataSetter, msg []byte, protocol string) {
+	msg = append([]byte(nil), msg...)
+	e, err := Parse(msg, time.Now())
+	if err != nil {
+		// metric-documentation-v2: (loggregator.metron.syslog_invalid) Number of
+		// syslog messages that could not be parsed, tagged by protocol
+		metric.IncCounter("syslog_invalid",
+			metric.WithVersion(2, 0),
+			metric.WithTag("protocol", protocol),
+		)
+		return
+	}
+
+	// metric-documentation-v2: (loggregator.metron.syslog_ingress) Number of
+	// syslog messages received, tagged by protocol
+	metric.IncCounter("syslog_ingress",
+		metric.WithVersion(2, 0),
+		metric.WithTag("protocol", protocol),
+	)
+	setter.Set(e)
+}
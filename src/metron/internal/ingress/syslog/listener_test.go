@@ -0,0 +1,129 @@
+package syslog_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"metron/internal/ingress/syslog"
+	v2 "plumbing/v2"
+	"testservers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listeners", func() {
+	var setter *mockDataSetter
+
+	BeforeEach(func() {
+		setter = newMockDataSetter()
+	})
+
+	Describe("UDPListener", func() {
+		It("sets an envelope for each datagram", func() {
+			l, err := syslog.NewUDPListener("127.0.0.1:0", setter)
+			Expect(err).ToNot(HaveOccurred())
+			go l.Start()
+			defer l.Stop()
+
+			conn, err := net.Dial("udp", l.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("<14>1 - - some-app 1 - - first"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = conn.Write([]byte("<14>1 - - some-app 1 - - second"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("first")))
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("second")))
+		})
+
+		It("drops messages that cannot be parsed", func() {
+			l, err := syslog.NewUDPListener("127.0.0.1:0", setter)
+			Expect(err).ToNot(HaveOccurred())
+			go l.Start()
+			defer l.Stop()
+
+			conn, err := net.Dial("udp", l.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("invalid"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Consistently(setter.SetCalled).ShouldNot(Receive())
+		})
+	})
+
+	Describe("TCPListener", func() {
+		It("reads newline delimited messages", func() {
+			l, err := syslog.NewTCPListener("127.0.0.1:0", nil, setter)
+			Expect(err).ToNot(HaveOccurred())
+			go l.Start()
+			defer l.Stop()
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("<14>1 - - some-app 1 - - first\n<14>1 - - some-app 1 - - second\n"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("first")))
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("second")))
+		})
+
+		It("reads octet counted messages", func() {
+			l, err := syslog.NewTCPListener("127.0.0.1:0", nil, setter)
+			Expect(err).ToNot(HaveOccurred())
+			go l.Start()
+			defer l.Stop()
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			first := "<14>1 - - some-app 1 - - first\nline"
+			second := "<14>1 - - some-app 1 - - second"
+			_, err = fmt.Fprintf(conn, "%d %s%d %s", len(first), first, len(second), second)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("first\nline")))
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("second")))
+		})
+
+		It("reads messages over TLS", func() {
+			cert, err := tls.LoadX509KeyPair(
+				testservers.Cert("metron.crt"),
+				testservers.Cert("metron.key"),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			l, err := syslog.NewTCPListener("127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{cert},
+			}, setter)
+			Expect(err).ToNot(HaveOccurred())
+			go l.Start()
+			defer l.Stop()
+
+			conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+				InsecureSkipVerify: true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("<14>1 - - some-app 1 - - some message\n"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(setter.SetInput.E).Should(Receive(HaveLogPayload("some message")))
+		})
+	})
+})
+
+func HaveLogPayload(payload string) OmegaMatcher {
+	return WithTransform(func(e *v2.Envelope) string {
+		return string(e.GetLog().Payload)
+	}, Equal(payload))
+}
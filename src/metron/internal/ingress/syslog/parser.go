@@ -0,0 +1,301 @@
+// Package syslog receives RFC5424 and RFC3164 syslog messages over UDP, TCP
+// and TLS and converts them to v2 log envelopes.
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	v2 "plumbing/v2"
+)
+
+const (
+	nilValue = "-"
+
+	// maxErrSeverity is the least severe syslog severity that is mapped to
+	// an ERR log.
+	maxErrSeverity = 3
+)
+
+var bom = []byte("\xef\xbb\xbf")
+
+// message is a parsed syslog message.
+type message struct {
+	priority  int
+	timestamp time.Time
+	hostname  string
+	appName   string
+	procID    string
+	msgID     string
+	params    map[string]string
+	msg       []byte
+}
+
+// Parse converts an RFC5424 or RFC3164 syslog message to a v2 log
+// envelope. APP-NAME becomes the source ID (falling back to the hostname),
+// PROCID the instance ID and every structured data parameter a tag. Messages
+// with a severity of error or worse become ERR logs and all others OUT logs.
+// Timestamps missing from the message are set to now.
+func Parse(data []byte, now time.Time) (*v2.Envelope, error) {
+	m, err := parse(bytes.TrimRight(data, "\r\n\x00"), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.envelope(), nil
+}
+
+func parse(data []byte, now time.Time) (*message, error) {
+	priority, rest, err := parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		return parseRFC5424(priority, rest[2:], now)
+	}
+	return parseRFC3164(priority, rest, now), nil
+}
+
+func parsePriority(data []byte) (int, []byte, error) {
+	end := bytes.IndexByte(data, '>')
+	if len(data) < 3 || data[0] != '<' || end < 2 || end > 4 {
+		return 0, nil, errors.New("missing PRI")
+	}
+
+	priority, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, nil, fmt.Errorf("invalid PRI %q", data[1:end])
+	}
+
+	return priority, data[end+1:], nil
+}
+
+func parseRFC5424(priority int, data []byte, now time.Time) (*message, error) {
+	fields := bytes.SplitN(data, []byte(" "), 6)
+	if len(fields) < 6 {
+		return nil, errors.New("missing RFC5424 header fields")
+	}
+
+	m := &message{
+		priority:  priority,
+		timestamp: now,
+		hostname:  field(fields[1]),
+		appName:   field(fields[2]),
+		procID:    field(fields[3]),
+		msgID:     field(fields[4]),
+	}
+
+	if ts := field(fields[0]); ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIMESTAMP %q", ts)
+		}
+		m.timestamp = t
+	}
+
+	params, rest, err := parseStructuredData(fields[5])
+	if err != nil {
+		return nil, err
+	}
+	m.params = params
+
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	m.msg = bytes.TrimPrefix(rest, bom)
+
+	return m, nil
+}
+
+func field(f []byte) string {
+	if string(f) == nilValue {
+		return ""
+	}
+	return string(f)
+}
+
+// parseStructuredData parses the STRUCTURED-DATA of an RFC5424 message and
+// returns its parameters along with the remainder of the message.
+func parseStructuredData(data []byte) (map[string]string, []byte, error) {
+	if len(data) > 0 && data[0] == '-' {
+		return nil, data[1:], nil
+	}
+
+	if len(data) == 0 || data[0] != '[' {
+		return nil, nil, errors.New("invalid STRUCTURED-DATA")
+	}
+
+	params := make(map[string]string)
+	for len(data) > 0 && data[0] == '[' {
+		end := bytes.IndexAny(data, " ]")
+		if end < 0 {
+			return nil, nil, errors.New("unterminated SD-ELEMENT")
+		}
+		data = data[end:]
+
+		for len(data) > 0 && data[0] == ' ' {
+			var (
+				name, value string
+				err         error
+			)
+			name, value, data, err = parseParam(data[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			params[name] = value
+		}
+
+		if len(data) == 0 || data[0] != ']' {
+			return nil, nil, errors.New("unterminated SD-ELEMENT")
+		}
+		data = data[1:]
+	}
+
+	return params, data, nil
+}
+
+// parseParam parses an SD-PARAM of the form name="value" where the value
+// may contain escaped '"', '\' and ']' characters.
+func parseParam(data []byte) (string, string, []byte, error) {
+	eq := bytes.IndexByte(data, '=')
+	if eq < 1 || len(data) < eq+2 || data[eq+1] != '"' {
+		return "", "", nil, errors.New("invalid SD-PARAM")
+	}
+	name := string(data[:eq])
+
+	var value []byte
+	for i := eq + 2; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+				i++
+			}
+			value = append(value, data[i])
+		case '"':
+			return name, string(value), data[i+1:], nil
+		default:
+			value = append(value, data[i])
+		}
+	}
+
+	return "", "", nil, errors.New("unterminated SD-PARAM")
+}
+
+// parseRFC3164 parses a BSD syslog message. The whole message after the
+// PRI is used as the MSG when it does not start with a valid timestamp.
+func parseRFC3164(priority int, data []byte, now time.Time) *message {
+	m := &message{
+		priority:  priority,
+		timestamp: now,
+		msg:       data,
+	}
+
+	if len(data) < len(time.Stamp)+1 || data[len(time.Stamp)] != ' ' {
+		return m
+	}
+
+	t, err := time.ParseInLocation(time.Stamp, string(data[:len(time.Stamp)]), now.Location())
+	if err != nil {
+		return m
+	}
+
+	// RFC3164 timestamps have no year. A timestamp that would be in the
+	// future belongs to the previous year.
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	m.timestamp = t
+
+	rest := data[len(time.Stamp)+1:]
+	end := bytes.IndexByte(rest, ' ')
+	if end < 0 {
+		m.msg = rest
+		return m
+	}
+	m.hostname = string(rest[:end])
+	rest = rest[end+1:]
+
+	m.appName, m.procID, m.msg = parseTag(rest)
+	return m
+}
+
+// parseTag splits the TAG of the form "name[pid]: " or "name: " off an
+// RFC3164 MSG.
+func parseTag(data []byte) (string, string, []byte) {
+	end := bytes.IndexAny(data, "[: ")
+	if end < 1 {
+		return "", "", data
+	}
+
+	name := string(data[:end])
+	rest := data[end:]
+
+	var procID string
+	if rest[0] == '[' {
+		close := bytes.IndexByte(rest, ']')
+		if close < 0 {
+			return "", "", data
+		}
+		procID = string(rest[1:close])
+		rest = rest[close+1:]
+	}
+
+	if len(rest) == 0 || rest[0] != ':' {
+		return "", "", data
+	}
+	rest = rest[1:]
+
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	return name, procID, rest
+}
+
+func (m *message) envelope() *v2.Envelope {
+	logType := v2.Log_OUT
+	if m.priority%8 <= maxErrSeverity {
+		logType = v2.Log_ERR
+	}
+
+	tags := make(map[string]*v2.Value)
+	for k, v := range m.params {
+		tags[k] = textValue(v)
+	}
+	if m.hostname != "" {
+		tags["hostname"] = textValue(m.hostname)
+	}
+	if m.msgID != "" {
+		tags["msg_id"] = textValue(m.msgID)
+	}
+
+	sourceID := m.appName
+	if sourceID == "" {
+		sourceID = m.hostname
+	}
+
+	return &v2.Envelope{
+		Timestamp:  m.timestamp.UnixNano(),
+		SourceId:   sourceID,
+		InstanceId: m.procID,
+		Tags:       tags,
+		Message: &v2.Envelope_Log{
+			Log: &v2.Log{
+				Payload: m.msg,
+				Type:    logType,
+			},
+		},
+	}
+}
+
+func textValue(s string) *v2.Value {
+	return &v2.Value{
+		Data: &v2.Value_Text{
+			Text: s,
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package syslog_test
+
+import (
+	"time"
+
+	"metron/internal/ingress/syslog"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	var now = time.Date(2017, time.March, 2, 10, 0, 0, 0, time.UTC)
+
+	Context("RFC5424", func() {
+		It("maps the header to the envelope", func() {
+			e, err := syslog.Parse([]byte(
+				"<14>1 2017-03-01T15:04:05.123Z some-host some-app 2 some-msg-id - some message\n",
+			), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Timestamp).To(Equal(time.Date(2017, time.March, 1, 15, 4, 5, 123000000, time.UTC).UnixNano()))
+			Expect(e.SourceId).To(Equal("some-app"))
+			Expect(e.InstanceId).To(Equal("2"))
+			Expect(e.Tags).To(HaveLen(2))
+			Expect(e.Tags["hostname"].GetText()).To(Equal("some-host"))
+			Expect(e.Tags["msg_id"].GetText()).To(Equal("some-msg-id"))
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+			Expect(e.GetLog().Type).To(Equal(v2.Log_OUT))
+		})
+
+		It("maps error severities to ERR logs", func() {
+			e, err := syslog.Parse([]byte("<11>1 - - some-app - - - some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e.GetLog().Type).To(Equal(v2.Log_ERR))
+
+			e, err = syslog.Parse([]byte("<12>1 - - some-app - - - some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e.GetLog().Type).To(Equal(v2.Log_OUT))
+		})
+
+		It("uses now and the hostname for nil values", func() {
+			e, err := syslog.Parse([]byte("<14>1 - some-host - - - - some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Timestamp).To(Equal(now.UnixNano()))
+			Expect(e.SourceId).To(Equal("some-host"))
+			Expect(e.InstanceId).To(BeEmpty())
+			Expect(e.Tags).To(HaveLen(1))
+		})
+
+		It("maps structured data parameters to tags", func() {
+			e, err := syslog.Parse([]byte(
+				`<14>1 - - some-app - - [tags@47450 env="prod" quote="a \"b\" \] \\c"][other foo="bar"][empty@47450] some message`,
+			), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Tags["env"].GetText()).To(Equal("prod"))
+			Expect(e.Tags["quote"].GetText()).To(Equal(`a "b" ] \c`))
+			Expect(e.Tags["foo"].GetText()).To(Equal("bar"))
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		})
+
+		It("strips the BOM from the message", func() {
+			e, err := syslog.Parse([]byte("<14>1 - - some-app - - - \xef\xbb\xbfsome message"), now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		})
+
+		It("accepts messages without a MSG", func() {
+			e, err := syslog.Parse([]byte("<14>1 - - some-app - - -"), now)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e.GetLog().Payload).To(BeEmpty())
+		})
+
+		DescribeTable("rejects invalid messages", func(msg string) {
+			_, err := syslog.Parse([]byte(msg), now)
+			Expect(err).To(HaveOccurred())
+		},
+			Entry("no PRI", "some message"),
+			Entry("PRI out of range", "<192>1 - - - - - - some message"),
+			Entry("non-numeric PRI", "<ab>1 - - - - - - some message"),
+			Entry("missing fields", "<14>1 - - some-app"),
+			Entry("invalid timestamp", "<14>1 yesterday - some-app - - - some message"),
+			Entry("unterminated structured data", `<14>1 - - some-app - - [tags env="prod" some message`),
+			Entry("invalid structured data", "<14>1 - - some-app - - some message"),
+		)
+	})
+
+	Context("RFC3164", func() {
+		It("maps the header to the envelope", func() {
+			e, err := syslog.Parse([]byte("<11>Mar  1 15:04:05 some-host some-app[123]: some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Timestamp).To(Equal(time.Date(2017, time.March, 1, 15, 4, 5, 0, time.UTC).UnixNano()))
+			Expect(e.SourceId).To(Equal("some-app"))
+			Expect(e.InstanceId).To(Equal("123"))
+			Expect(e.Tags["hostname"].GetText()).To(Equal("some-host"))
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+			Expect(e.GetLog().Type).To(Equal(v2.Log_ERR))
+		})
+
+		It("accepts a tag without a PID", func() {
+			e, err := syslog.Parse([]byte("<14>Mar  1 15:04:05 some-host some-app: some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.SourceId).To(Equal("some-app"))
+			Expect(e.InstanceId).To(BeEmpty())
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		})
+
+		It("uses the hostname when there is no tag", func() {
+			e, err := syslog.Parse([]byte("<14>Mar  1 15:04:05 some-host some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.SourceId).To(Equal("some-host"))
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		})
+
+		It("places timestamps in the future in the previous year", func() {
+			e, err := syslog.Parse([]byte("<14>Dec 31 23:59:59 some-host some-app: some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Timestamp).To(Equal(time.Date(2016, time.December, 31, 23, 59, 59, 0, time.UTC).UnixNano()))
+		})
+
+		It("uses the whole message when there is no header", func() {
+			e, err := syslog.Parse([]byte("<14>some message"), now)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(e.Timestamp).To(Equal(now.UnixNano()))
+			Expect(e.SourceId).To(BeEmpty())
+			Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		})
+	})
+})
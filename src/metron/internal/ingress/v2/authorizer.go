@@ -0,0 +1,66 @@
+package v2
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// AuthorizeCNs returns a stream interceptor that only accepts streams from
+// remote clients whose certificate common name is one of cns. Clients
+// connecting over a loopback interface are always accepted so that
+// co-located components keep working when Metron listens on other
+// interfaces.
+func AuthorizeCNs(cns []string) grpc.StreamServerInterceptor {
+	allowed := make(map[string]bool)
+	for _, cn := range cns {
+		allowed[cn] = true
+	}
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		p, ok := peer.FromContext(ss.Context())
+		if !ok {
+			return grpc.Errorf(codes.PermissionDenied, "unknown client")
+		}
+
+		if isLoopback(p.Addr) {
+			return handler(srv, ss)
+		}
+
+		cn, err := commonName(p)
+		if err != nil {
+			log.Printf("Rejected client %s: %s", p.Addr, err)
+			return grpc.Errorf(codes.PermissionDenied, err.Error())
+		}
+
+		if !allowed[cn] {
+			log.Printf("Rejected client %s with common name %q", p.Addr, cn)
+			return grpc.Errorf(codes.PermissionDenied, "client certificate is not allowed")
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+func commonName(p *peer.Peer) (string, error) {
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", errors.New("client did not connect over TLS")
+	}
+
+	if len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", errors.New("client did not present a verified certificate")
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
@@ -0,0 +1,83 @@
+package v2_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+
+	ingress "metron/internal/ingress/v2"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuthorizeCNs", func() {
+	var (
+		interceptor grpc.StreamServerInterceptor
+		stream      *mockSender
+		handled     bool
+		handler     grpc.StreamHandler
+	)
+
+	BeforeEach(func() {
+		interceptor = ingress.AuthorizeCNs([]string{"allowed-client"})
+		stream = newMockSender()
+		handled = false
+		handler = func(interface{}, grpc.ServerStream) error {
+			handled = true
+			return nil
+		}
+	})
+
+	It("accepts remote clients with an allowed common name", func() {
+		stream.ContextOutput.Ret0 <- peerContext("10.0.0.1", "allowed-client")
+
+		Expect(interceptor(nil, stream, nil, handler)).To(Succeed())
+		Expect(handled).To(BeTrue())
+	})
+
+	It("rejects remote clients with any other common name", func() {
+		stream.ContextOutput.Ret0 <- peerContext("10.0.0.1", "other-client")
+
+		Expect(interceptor(nil, stream, nil, handler)).ToNot(Succeed())
+		Expect(handled).To(BeFalse())
+	})
+
+	It("rejects remote clients without a verified certificate", func() {
+		stream.ContextOutput.Ret0 <- peer.NewContext(context.Background(), &peer.Peer{
+			Addr:     &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+			AuthInfo: credentials.TLSInfo{},
+		})
+
+		Expect(interceptor(nil, stream, nil, handler)).ToNot(Succeed())
+		Expect(handled).To(BeFalse())
+	})
+
+	It("accepts loopback clients with any common name", func() {
+		stream.ContextOutput.Ret0 <- peerContext("127.0.0.1", "other-client")
+
+		Expect(interceptor(nil, stream, nil, handler)).To(Succeed())
+		Expect(handled).To(BeTrue())
+	})
+})
+
+func peerContext(ip, cn string) context.Context {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: cn},
+	}
+
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234},
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			},
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package v2
+
+import v2 "plumbing/v2"
+
+// ClassSetter routes envelopes to a DataSetter per message class so that a
+// burst of one class can not push another out of a shared buffer.
+// Envelopes without a message are routed with logs.
+type ClassSetter struct {
+	log     DataSetter
+	counter DataSetter
+	gauge   DataSetter
+	timer   DataSetter
+}
+
+func NewClassSetter(log, counter, gauge, timer DataSetter) *ClassSetter {
+	return &ClassSetter{
+		log:     log,
+		counter: counter,
+		gauge:   gauge,
+		timer:   timer,
+	}
+}
+
+func (s *ClassSetter) Set(e *v2.Envelope) {
+	switch e.Message.(type) {
+	case *v2.Envelope_Counter:
+		s.counter.Set(e)
+	case *v2.Envelope_Gauge:
+		s.gauge.Set(e)
+	case *v2.Envelope_Timer:
+		s.timer.Set(e)
+	default:
+		s.log.Set(e)
+	}
+}
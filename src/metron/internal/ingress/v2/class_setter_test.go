@@ -0,0 +1,51 @@
+package v2_test
+
+import (
+	ingress "metron/internal/ingress/v2"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClassSetter", func() {
+	var (
+		logSetter     *mockDataSetter
+		counterSetter *mockDataSetter
+		gaugeSetter   *mockDataSetter
+		timerSetter   *mockDataSetter
+		setter        *ingress.ClassSetter
+	)
+
+	BeforeEach(func() {
+		logSetter = newMockDataSetter()
+		counterSetter = newMockDataSetter()
+		gaugeSetter = newMockDataSetter()
+		timerSetter = newMockDataSetter()
+		setter = ingress.NewClassSetter(logSetter, counterSetter, gaugeSetter, timerSetter)
+	})
+
+	It("routes each envelope to the setter of its class", func() {
+		logEnvelope := &v2.Envelope{Message: &v2.Envelope_Log{Log: &v2.Log{}}}
+		counterEnvelope := &v2.Envelope{Message: &v2.Envelope_Counter{Counter: &v2.Counter{}}}
+		gaugeEnvelope := &v2.Envelope{Message: &v2.Envelope_Gauge{Gauge: &v2.Gauge{}}}
+		timerEnvelope := &v2.Envelope{Message: &v2.Envelope_Timer{Timer: &v2.Timer{}}}
+
+		setter.Set(logEnvelope)
+		setter.Set(counterEnvelope)
+		setter.Set(gaugeEnvelope)
+		setter.Set(timerEnvelope)
+
+		Expect(logSetter.SetInput.E).To(Receive(Equal(logEnvelope)))
+		Expect(counterSetter.SetInput.E).To(Receive(Equal(counterEnvelope)))
+		Expect(gaugeSetter.SetInput.E).To(Receive(Equal(gaugeEnvelope)))
+		Expect(timerSetter.SetInput.E).To(Receive(Equal(timerEnvelope)))
+	})
+
+	It("routes envelopes without a message with logs", func() {
+		e := &v2.Envelope{SourceId: "some-id"}
+		setter.Set(e)
+
+		Expect(logSetter.SetInput.E).To(Receive(Equal(e)))
+	})
+})
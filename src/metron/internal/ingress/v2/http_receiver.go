@@ -0,0 +1,139 @@
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"metric"
+	"net/http"
+	"time"
+
+	v2 "plumbing/v2"
+
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// maxRequestBytes is the largest request body accepted by the HTTPReceiver.
+const maxRequestBytes = 1 << 20
+
+// HTTPReceiver accepts v2 envelopes encoded with the protobuf JSON mapping
+// of envelope.proto. A request body is either a single envelope object or
+// an array of envelopes. The request is rejected without writing any
+// envelope if one of them is invalid.
+type HTTPReceiver struct {
+	dataSetter DataSetter
+}
+
+// NewHTTPReceiver returns an HTTPReceiver that writes to the given setter.
+func NewHTTPReceiver(dataSetter DataSetter) *HTTPReceiver {
+	return &HTTPReceiver{
+		dataSetter: dataSetter,
+	}
+}
+
+func (h *HTTPReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBytes+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRequestBytes {
+		http.Error(w, fmt.Sprintf("request body exceeds %d bytes", maxRequestBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	envelopes, err := decodeEnvelopes(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, e := range envelopes {
+		h.dataSetter.Set(e)
+	}
+
+	// metric-documentation-v2: (loggregator.metron.http_ingress) The number of
+	// received envelopes over Metrons HTTP API.
+	metric.IncCounter("http_ingress",
+		metric.WithIncrement(uint64(len(envelopes))),
+		metric.WithVersion(2, 0),
+	)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func decodeEnvelopes(body []byte) ([]*v2.Envelope, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+
+	raw := []json.RawMessage{body}
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %s", err)
+		}
+		if len(raw) == 0 {
+			return nil, errors.New("batch contains no envelopes")
+		}
+	}
+
+	now := time.Now().UnixNano()
+	envelopes := make([]*v2.Envelope, 0, len(raw))
+	for i, r := range raw {
+		e := &v2.Envelope{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(r), e); err != nil {
+			return nil, fmt.Errorf("envelope %d: invalid JSON: %s", i, err)
+		}
+		if err := validate(e); err != nil {
+			return nil, fmt.Errorf("envelope %d: %s", i, err)
+		}
+
+		if e.Timestamp == 0 {
+			e.Timestamp = now
+		}
+		envelopes = append(envelopes, e)
+	}
+	return envelopes, nil
+}
+
+func validate(e *v2.Envelope) error {
+	if e.SourceId == "" {
+		return errors.New("source_id is required")
+	}
+
+	switch m := e.Message.(type) {
+	case *v2.Envelope_Log:
+	case *v2.Envelope_Counter:
+		if m.Counter.Name == "" {
+			return errors.New("counter name is required")
+		}
+	case *v2.Envelope_Gauge:
+		if len(m.Gauge.Metrics) == 0 {
+			return errors.New("gauge requires at least one metric")
+		}
+		for name, v := range m.Gauge.Metrics {
+			if v == nil {
+				return fmt.Errorf("gauge metric %q has no value", name)
+			}
+		}
+	case *v2.Envelope_Timer:
+		if m.Timer.Name == "" {
+			return errors.New("timer name is required")
+		}
+		if m.Timer.Stop < m.Timer.Start {
+			return errors.New("timer stop is before start")
+		}
+	default:
+		return errors.New("one of log, counter, gauge or timer is required")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package v2_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	ingress "metron/internal/ingress/v2"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPReceiver", func() {
+	var (
+		rx             *ingress.HTTPReceiver
+		mockDataSetter *mockDataSetter
+		recorder       *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		mockDataSetter = newMockDataSetter()
+		rx = ingress.NewHTTPReceiver(mockDataSetter)
+		recorder = httptest.NewRecorder()
+	})
+
+	post := func(body string) {
+		req, err := http.NewRequest("POST", "/v2/envelopes", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		rx.ServeHTTP(recorder, req)
+	}
+
+	It("sets a single envelope", func() {
+		post(`{
+			"timestamp": "1490000000000000000",
+			"source_id": "some-source-id",
+			"instance_id": "3",
+			"tags": {"some-tag": {"text": "some-value"}},
+			"log": {"payload": "c29tZSBtZXNzYWdl", "type": "ERR"}
+		}`)
+
+		Expect(recorder.Code).To(Equal(http.StatusAccepted))
+
+		var e *v2.Envelope
+		Expect(mockDataSetter.SetInput.E).To(Receive(&e))
+		Expect(e.Timestamp).To(Equal(int64(1490000000000000000)))
+		Expect(e.SourceId).To(Equal("some-source-id"))
+		Expect(e.InstanceId).To(Equal("3"))
+		Expect(e.Tags["some-tag"].GetText()).To(Equal("some-value"))
+		Expect(e.GetLog().Payload).To(Equal([]byte("some message")))
+		Expect(e.GetLog().Type).To(Equal(v2.Log_ERR))
+	})
+
+	It("sets a batch of envelopes", func() {
+		post(`[
+			{"source_id": "some-source-id", "counter": {"name": "some-counter", "delta": 5}},
+			{"source_id": "some-source-id", "gauge": {"metrics": {"some-gauge": {"unit": "ms", "value": 1.5}}}},
+			{"source_id": "some-source-id", "timer": {"name": "some-timer", "start": 1, "stop": 2}}
+		]`)
+
+		Expect(recorder.Code).To(Equal(http.StatusAccepted))
+
+		var e *v2.Envelope
+		Expect(mockDataSetter.SetInput.E).To(Receive(&e))
+		Expect(e.GetCounter().GetDelta()).To(Equal(uint64(5)))
+		Expect(mockDataSetter.SetInput.E).To(Receive(&e))
+		Expect(e.GetGauge().GetMetrics()["some-gauge"].Value).To(Equal(1.5))
+		Expect(mockDataSetter.SetInput.E).To(Receive(&e))
+		Expect(e.GetTimer().Name).To(Equal("some-timer"))
+	})
+
+	It("sets the timestamp when it is missing", func() {
+		post(`{"source_id": "some-source-id", "log": {"payload": "c29tZSBtZXNzYWdl"}}`)
+
+		var e *v2.Envelope
+		Expect(mockDataSetter.SetInput.E).To(Receive(&e))
+		Expect(e.Timestamp).ToNot(BeZero())
+	})
+
+	It("rejects methods other than POST", func() {
+		req, err := http.NewRequest("GET", "/v2/envelopes", nil)
+		Expect(err).ToNot(HaveOccurred())
+		rx.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("rejects bodies that are too large", func() {
+		post(`{"source_id": "` + strings.Repeat("a", 1<<20) + `"}`)
+
+		Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("rejects bodies that fail to be read", func() {
+		req, err := http.NewRequest("POST", "/v2/envelopes", errReader{})
+		Expect(err).ToNot(HaveOccurred())
+		rx.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("does not set any envelope of a batch with an invalid envelope", func() {
+		post(`[
+			{"source_id": "some-source-id", "counter": {"name": "some-counter"}},
+			{"source_id": "some-source-id"}
+		]`)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("envelope 1"))
+		Expect(mockDataSetter.SetCalled).ToNot(Receive())
+	})
+
+	DescribeTable("rejects invalid envelopes", func(body, message string) {
+		post(body)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring(message))
+		Expect(mockDataSetter.SetCalled).ToNot(Receive())
+	},
+		Entry("empty body", "", "request body is empty"),
+		Entry("empty batch", "[]", "batch contains no envelopes"),
+		Entry("malformed JSON", "{", "invalid JSON"),
+		Entry("unknown field", `{"source_id": "a", "unknown": 1}`, "invalid JSON"),
+		Entry("no source ID", `{"log": {}}`, "source_id is required"),
+		Entry("no message", `{"source_id": "a"}`, "one of log, counter, gauge or timer is required"),
+		Entry("counter without name", `{"source_id": "a", "counter": {"delta": 1}}`, "counter name is required"),
+		Entry("gauge without metrics", `{"source_id": "a", "gauge": {}}`, "gauge requires at least one metric"),
+		Entry("timer without name", `{"source_id": "a", "timer": {"start": 1, "stop": 2}}`, "timer name is required"),
+		Entry("timer ending before it starts", `{"source_id": "a", "timer": {"name": "t", "start": 2, "stop": 1}}`, "timer stop is before start"),
+	)
+})
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
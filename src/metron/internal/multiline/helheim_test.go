@@ -0,0 +1,48 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package multiline_test
+
+import (
+	v2 "plumbing/v2"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type mockEnvelopeWriter struct {
+	WriteCalled chan bool
+	WriteInput  struct {
+		Arg0 chan *events.Envelope
+	}
+}
+
+func newMockEnvelopeWriter() *mockEnvelopeWriter {
+	m := &mockEnvelopeWriter{}
+	m.WriteCalled = make(chan bool, 100)
+	m.WriteInput.Arg0 = make(chan *events.Envelope, 100)
+	return m
+}
+func (m *mockEnvelopeWriter) Write(arg0 *events.Envelope) {
+	m.WriteCalled <- true
+	m.WriteInput.Arg0 <- arg0
+}
+
+type mockDataSetter struct {
+	SetCalled chan bool
+	SetInput  struct {
+		E chan *v2.Envelope
+	}
+}
+
+func newMockDataSetter() *mockDataSetter {
+	m := &mockDataSetter{}
+	m.SetCalled = make(chan bool, 100)
+	m.SetInput.E = make(chan *v2.Envelope, 100)
+	return m
+}
+func (m *mockDataSetter) Set(e *v2.Envelope) {
+	m.SetCalled <- true
+	m.SetInput.E <- e
+}
@@ -0,0 +1,15 @@
+//go:generate hel
+
+package multiline_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMultiline(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Multiline Suite")
+}
@@ -0,0 +1,179 @@
+// Package multiline joins log lines that continue a previous line, such as
+// the lines of a stack trace, back into a single log message.
+package multiline
+
+import (
+	"bytes"
+	"metric"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Config configures a reassembler.
+type Config struct {
+	// Patterns match continuation lines. A line matching any of them is
+	// appended to the previous line of the same source instance.
+	Patterns []*regexp.Regexp
+
+	// MaxLines is the most lines joined into one message.
+	MaxLines int
+
+	// MaxWait is the longest a line is held waiting for continuation lines.
+	MaxWait time.Duration
+
+	// SourceIDs limits reassembly to the given source IDs. Lines of every
+	// source are reassembled when it is empty.
+	SourceIDs []string
+}
+
+// line is a log line of a v1 or v2 envelope.
+type line interface {
+	// key identifies the stream of the line. It reports false when the
+	// envelope is not a log message.
+	key() (string, bool)
+	sourceID() string
+	message() []byte
+	setMessage([]byte)
+}
+
+type reassembler struct {
+	conf    Config
+	sources map[string]bool
+	emit    func(line)
+
+	mu      sync.Mutex
+	pending map[string]*pendingLine
+}
+
+type pendingLine struct {
+	first    line
+	messages [][]byte
+	deadline time.Time
+}
+
+func newReassembler(c Config, emit func(line)) *reassembler {
+	if c.MaxLines < 1 {
+		c.MaxLines = 1
+	}
+
+	var sources map[string]bool
+	if len(c.SourceIDs) > 0 {
+		sources = make(map[string]bool)
+		for _, id := range c.SourceIDs {
+			sources[id] = true
+		}
+	}
+
+	r := &reassembler{
+		conf:    c,
+		sources: sources,
+		emit:    emit,
+		pending: make(map[string]*pendingLine),
+	}
+	go r.run()
+
+	return r
+}
+
+func (r *reassembler) write(l line) {
+	key, ok := l.key()
+	if !ok || (r.sources != nil && !r.sources[l.sourceID()]) {
+		r.emit(l)
+		return
+	}
+
+	ready := r.add(key, l)
+	for _, l := range ready {
+		r.emit(l)
+	}
+}
+
+// add joins the line to the pending line of its stream or makes it the
+// pending line. It returns the lines that are complete.
+func (r *reassembler) add(key string, l line) []line {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg := l.message()
+	p, ok := r.pending[key]
+	if ok && r.continues(msg) {
+		p.messages = append(p.messages, msg)
+		if len(p.messages) < r.conf.MaxLines {
+			return nil
+		}
+		delete(r.pending, key)
+		return []line{p.join()}
+	}
+
+	var ready []line
+	if ok {
+		ready = append(ready, p.join())
+	}
+
+	if r.conf.MaxLines == 1 {
+		return append(ready, l)
+	}
+
+	r.pending[key] = &pendingLine{
+		first:    l,
+		messages: [][]byte{msg},
+		deadline: time.Now().Add(r.conf.MaxWait),
+	}
+	return ready
+}
+
+func (r *reassembler) continues(msg []byte) bool {
+	for _, p := range r.conf.Patterns {
+		if p.Match(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reassembler) run() {
+	interval := r.conf.MaxWait / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+
+	for range time.Tick(interval) {
+		for _, l := range r.expired(time.Now()) {
+			r.emit(l)
+		}
+	}
+}
+
+// expired removes and returns the pending lines that have waited for
+// continuation lines for longer than the configured maximum.
+func (r *reassembler) expired(now time.Time) []line {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ready []line
+	for key, p := range r.pending {
+		if now.Before(p.deadline) {
+			continue
+		}
+		delete(r.pending, key)
+		ready = append(ready, p.join())
+	}
+	return ready
+}
+
+func (p *pendingLine) join() line {
+	if len(p.messages) == 1 {
+		return p.first
+	}
+
+	// metric-documentation-v2: (loggregator.metron.reassembled_lines) Number
+	// of continuation lines joined into the log line they continue
+	metric.IncCounter("reassembled_lines",
+		metric.WithIncrement(uint64(len(p.messages)-1)),
+		metric.WithVersion(2, 0),
+	)
+
+	p.first.setMessage(bytes.Join(p.messages, []byte("\n")))
+	return p.first
+}
@@ -0,0 +1,152 @@
+package multiline_test
+
+import (
+	"metron/internal/multiline"
+	v2 "plumbing/v2"
+	"regexp"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reassembler", func() {
+	var config multiline.Config
+
+	BeforeEach(func() {
+		config = multiline.Config{
+			Patterns: []*regexp.Regexp{
+				regexp.MustCompile(`^[ \t]+`),
+				regexp.MustCompile(`^Caused by:`),
+			},
+			MaxLines: 4,
+			MaxWait:  50 * time.Millisecond,
+		}
+	})
+
+	Describe("V2Reassembler", func() {
+		var (
+			setter *mockDataSetter
+			r      *multiline.V2Reassembler
+		)
+
+		JustBeforeEach(func() {
+			setter = newMockDataSetter()
+			r = multiline.NewV2Reassembler(setter, config)
+		})
+
+		It("joins continuation lines with the line they continue", func() {
+			r.Set(logEnvelope("some-source", "0", "java.lang.RuntimeException: boom"))
+			r.Set(logEnvelope("some-source", "0", "\tat Foo.bar(Foo.java:1)"))
+			r.Set(logEnvelope("some-source", "0", "Caused by: java.io.IOException"))
+			r.Set(logEnvelope("some-source", "0", "next line"))
+
+			var e *v2.Envelope
+			Eventually(setter.SetInput.E).Should(Receive(&e))
+			Expect(string(e.GetLog().Payload)).To(Equal(
+				"java.lang.RuntimeException: boom\n\tat Foo.bar(Foo.java:1)\nCaused by: java.io.IOException",
+			))
+
+			Eventually(setter.SetInput.E).Should(Receive(&e))
+			Expect(string(e.GetLog().Payload)).To(Equal("next line"))
+		})
+
+		It("does not join lines of different instances", func() {
+			r.Set(logEnvelope("some-source", "0", "first"))
+			r.Set(logEnvelope("some-source", "1", "  second"))
+
+			Eventually(setter.SetCalled).Should(HaveLen(2))
+		})
+
+		It("flushes a line after the max wait", func() {
+			r.Set(logEnvelope("some-source", "0", "only line"))
+
+			Consistently(setter.SetCalled, 25*time.Millisecond).ShouldNot(Receive())
+			Eventually(setter.SetCalled).Should(Receive())
+		})
+
+		It("flushes a line when it reaches the max lines", func() {
+			r.Set(logEnvelope("some-source", "0", "first"))
+			for i := 0; i < 3; i++ {
+				r.Set(logEnvelope("some-source", "0", "  more"))
+			}
+
+			var e *v2.Envelope
+			Expect(setter.SetInput.E).To(Receive(&e))
+			Expect(string(e.GetLog().Payload)).To(Equal("first\n  more\n  more\n  more"))
+		})
+
+		It("passes through envelopes that are not logs", func() {
+			counter := &v2.Envelope{
+				SourceId: "some-source",
+				Message: &v2.Envelope_Counter{
+					Counter: &v2.Counter{Name: "some-counter"},
+				},
+			}
+			r.Set(counter)
+
+			Expect(setter.SetInput.E).To(Receive(Equal(counter)))
+		})
+
+		Context("when limited to source IDs", func() {
+			BeforeEach(func() {
+				config.SourceIDs = []string{"some-source"}
+			})
+
+			It("passes through the logs of other sources", func() {
+				r.Set(logEnvelope("other-source", "0", "first"))
+				r.Set(logEnvelope("other-source", "0", "  second"))
+
+				Expect(setter.SetCalled).To(HaveLen(2))
+			})
+		})
+	})
+
+	Describe("V1Reassembler", func() {
+		It("joins continuation lines with the line they continue", func() {
+			writer := newMockEnvelopeWriter()
+			r := multiline.NewV1Reassembler(writer, config)
+
+			r.Write(logMessage("some-app", "0", "java.lang.RuntimeException: boom"))
+			r.Write(logMessage("some-app", "0", "\tat Foo.bar(Foo.java:1)"))
+			r.Write(logMessage("some-app", "0", "next line"))
+
+			var e *events.Envelope
+			Eventually(writer.WriteInput.Arg0).Should(Receive(&e))
+			Expect(string(e.GetLogMessage().GetMessage())).To(Equal(
+				"java.lang.RuntimeException: boom\n\tat Foo.bar(Foo.java:1)",
+			))
+		})
+	})
+})
+
+func logEnvelope(sourceID, instanceID, payload string) *v2.Envelope {
+	return &v2.Envelope{
+		SourceId:   sourceID,
+		InstanceId: instanceID,
+		Message: &v2.Envelope_Log{
+			Log: &v2.Log{
+				Payload: []byte(payload),
+				Type:    v2.Log_ERR,
+			},
+		},
+	}
+}
+
+func logMessage(appID, instance, msg string) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String("some-origin"),
+		EventType: events.Envelope_LogMessage.Enum(),
+		LogMessage: &events.LogMessage{
+			Message:        []byte(msg),
+			MessageType:    events.LogMessage_ERR.Enum(),
+			Timestamp:      proto.Int64(1),
+			AppId:          proto.String(appID),
+			SourceType:     proto.String("APP"),
+			SourceInstance: proto.String(instance),
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package multiline
+
+import "github.com/cloudfoundry/sonde-go/events"
+
+type EnvelopeWriter interface {
+	Write(*events.Envelope)
+}
+
+// V1Reassembler reassembles the log messages of v1 envelopes before writing
+// them to the next writer. Streams are keyed by app ID, source type, source
+// instance and message type.
+type V1Reassembler struct {
+	r *reassembler
+}
+
+func NewV1Reassembler(next EnvelopeWriter, c Config) *V1Reassembler {
+	return &V1Reassembler{
+		r: newReassembler(c, func(l line) {
+			next.Write(l.(v1Line).e)
+		}),
+	}
+}
+
+func (r *V1Reassembler) Write(e *events.Envelope) {
+	r.r.write(v1Line{e: e})
+}
+
+type v1Line struct {
+	e *events.Envelope
+}
+
+func (l v1Line) key() (string, bool) {
+	if l.e.GetEventType() != events.Envelope_LogMessage {
+		return "", false
+	}
+
+	m := l.e.GetLogMessage()
+	return m.GetAppId() + "/" + m.GetSourceType() + "/" + m.GetSourceInstance() + "/" + m.GetMessageType().String(), true
+}
+
+func (l v1Line) sourceID() string {
+	return l.e.GetLogMessage().GetAppId()
+}
+
+func (l v1Line) message() []byte {
+	return l.e.GetLogMessage().GetMessage()
+}
+
+func (l v1Line) setMessage(msg []byte) {
+	l.e.LogMessage.Message = msg
+}
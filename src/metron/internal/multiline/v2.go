@@ -0,0 +1,50 @@
+package multiline
+
+import v2 "plumbing/v2"
+
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// V2Reassembler reassembles the logs of v2 envelopes before setting them on
+// the next setter. Streams are keyed by source ID, instance ID and log type.
+type V2Reassembler struct {
+	r *reassembler
+}
+
+func NewV2Reassembler(next DataSetter, c Config) *V2Reassembler {
+	return &V2Reassembler{
+		r: newReassembler(c, func(l line) {
+			next.Set(l.(v2Line).e)
+		}),
+	}
+}
+
+func (r *V2Reassembler) Set(e *v2.Envelope) {
+	r.r.write(v2Line{e: e})
+}
+
+type v2Line struct {
+	e *v2.Envelope
+}
+
+func (l v2Line) key() (string, bool) {
+	log := l.e.GetLog()
+	if log == nil {
+		return "", false
+	}
+
+	return l.e.SourceId + "/" + l.e.InstanceId + "/" + log.Type.String(), true
+}
+
+func (l v2Line) sourceID() string {
+	return l.e.SourceId
+}
+
+func (l v2Line) message() []byte {
+	return l.e.GetLog().Payload
+}
+
+func (l v2Line) setMessage(msg []byte) {
+	l.e.GetLog().Payload = msg
+}
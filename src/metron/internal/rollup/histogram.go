@@ -0,0 +1,76 @@
+package rollup
+
+import (
+	"math"
+	"time"
+)
+
+// bounds are the upper bounds of the histogram buckets in milliseconds.
+// Durations above the last bound fall into an overflow bucket.
+var bounds = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// histogram counts durations in fixed buckets so that its size does not
+// depend on the number of durations observed.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	max    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms < 0 {
+		ms = 0
+	}
+
+	i := 0
+	for i < len(bounds) && ms > bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	if ms > h.max {
+		h.max = ms
+	}
+}
+
+// percentile estimates the duration in milliseconds below which the given
+// fraction of durations fall. It interpolates linearly within the bucket
+// that holds the percentile and never exceeds the largest duration.
+func (h *histogram) percentile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		if seen+c < rank {
+			seen += c
+			continue
+		}
+
+		lower, upper := 0.0, h.max
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		if i < len(bounds) && bounds[i] < upper {
+			upper = bounds[i]
+		}
+		if upper < lower {
+			return upper
+		}
+		return lower + (upper-lower)*float64(rank-seen)/float64(c)
+	}
+	return h.max
+}
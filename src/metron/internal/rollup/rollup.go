@@ -0,0 +1,220 @@
+// Package rollup aggregates timer envelopes into request rate, error rate
+// and latency metrics.
+package rollup
+
+import (
+	"fmt"
+	"log"
+	"metric"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "plumbing/v2"
+)
+
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// Rollup aggregates timers per source ID, timer name, route and status
+// class. At the end of each interval it sets a counter envelope with the
+// number of timers of each series and gauge envelopes with their latency
+// percentiles. The route is the host of the uri tag and the status class is
+// derived from the status_code tag, so that HTTP timers converted from v1
+// HttpStartStop events yield request, error and duration metrics.
+type Rollup struct {
+	setter      DataSetter
+	interval    time.Duration
+	maxSeries   int
+	passThrough bool
+
+	mu      sync.Mutex
+	series  map[seriesKey]*histogram
+	dropped uint64
+}
+
+type seriesKey struct {
+	sourceID    string
+	name        string
+	route       string
+	statusClass string
+}
+
+var percentiles = []struct {
+	suffix   string
+	fraction float64
+}{
+	{"p50", 0.5},
+	{"p95", 0.95},
+	{"p99", 0.99},
+}
+
+// New returns a Rollup that sets envelopes every interval. At most
+// maxSeries distinct series are kept per interval. Timers of further series
+// are dropped from the rollup. When passThrough is true every timer is
+// also set as is.
+func New(setter DataSetter, interval time.Duration, maxSeries int, passThrough bool) *Rollup {
+	return &Rollup{
+		setter:      setter,
+		interval:    interval,
+		maxSeries:   maxSeries,
+		passThrough: passThrough,
+		series:      make(map[seriesKey]*histogram),
+	}
+}
+
+// Set records timer envelopes and passes every other envelope through.
+func (r *Rollup) Set(e *v2.Envelope) {
+	if e.GetTimer() == nil {
+		r.setter.Set(e)
+		return
+	}
+
+	r.Record(e)
+	if r.passThrough {
+		r.setter.Set(e)
+	}
+}
+
+// Record adds a timer envelope to the rollup without passing it through.
+// Envelopes that are not timers are ignored.
+func (r *Rollup) Record(e *v2.Envelope) {
+	t := e.GetTimer()
+	if t == nil {
+		return
+	}
+
+	key := seriesKey{
+		sourceID:    e.SourceId,
+		name:        t.Name,
+		route:       route(e.Tags["uri"].GetText()),
+		statusClass: statusClass(e.Tags["status_code"]),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.series[key]
+	if !ok {
+		if len(r.series) >= r.maxSeries {
+			r.dropped++
+			return
+		}
+		h = newHistogram()
+		r.series[key] = h
+	}
+	h.observe(time.Duration(t.Stop - t.Start))
+}
+
+// Start flushes every interval. It blocks forever.
+func (r *Rollup) Start() {
+	for range time.Tick(r.interval) {
+		r.Flush()
+	}
+}
+
+// Flush sets the envelopes for every series recorded since the last flush.
+func (r *Rollup) Flush() {
+	r.mu.Lock()
+	series := r.series
+	dropped := r.dropped
+	r.series = make(map[seriesKey]*histogram)
+	r.dropped = 0
+	r.mu.Unlock()
+
+	if dropped > 0 {
+		// metric-documentation-v2: (loggregator.metron.rollup_dropped) Number
+		// of timers left out of the rollup for exceeding the series limit
+		metric.IncCounter("rollup_dropped",
+			metric.WithIncrement(dropped),
+			metric.WithVersion(2, 0),
+		)
+		log.Printf("dropped %d timers exceeding the limit of %d rollup series", dropped, r.maxSeries)
+	}
+
+	now := time.Now()
+	for key, h := range series {
+		e := newEnvelope(now, key)
+		e.Message = &v2.Envelope_Counter{
+			Counter: &v2.Counter{
+				Name: key.name + "_count",
+				Value: &v2.Counter_Delta{
+					Delta: h.count,
+				},
+			},
+		}
+		r.setter.Set(e)
+
+		for _, p := range percentiles {
+			r.setter.Set(newGauge(now, key, key.name+"_latency_"+p.suffix, h.percentile(p.fraction)))
+		}
+		r.setter.Set(newGauge(now, key, key.name+"_latency_max", h.max))
+	}
+}
+
+func newGauge(now time.Time, key seriesKey, name string, value float64) *v2.Envelope {
+	e := newEnvelope(now, key)
+	e.Message = &v2.Envelope_Gauge{
+		Gauge: &v2.Gauge{
+			Metrics: map[string]*v2.GaugeValue{
+				name: {
+					Unit:  "ms",
+					Value: value,
+				},
+			},
+		},
+	}
+	return e
+}
+
+func newEnvelope(now time.Time, key seriesKey) *v2.Envelope {
+	tags := make(map[string]*v2.Value)
+	if key.route != "" {
+		tags["route"] = textValue(key.route)
+	}
+	if key.statusClass != "" {
+		tags["status_class"] = textValue(key.statusClass)
+	}
+
+	return &v2.Envelope{
+		SourceId:  key.sourceID,
+		Timestamp: now.UnixNano(),
+		Tags:      tags,
+	}
+}
+
+func textValue(s string) *v2.Value {
+	return &v2.Value{
+		Data: &v2.Value_Text{
+			Text: s,
+		},
+	}
+}
+
+// route returns the host of a request URI, which may or may not include a
+// scheme. Paths are left out to keep the number of series bounded.
+func route(uri string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	if i := strings.IndexAny(uri, "/?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	return strings.ToLower(uri)
+}
+
+// statusClass returns the class of an HTTP status code such as 2xx. It
+// returns an empty string for a missing or invalid status code.
+func statusClass(v *v2.Value) string {
+	code := v.GetInteger()
+	if code == 0 {
+		code, _ = strconv.ParseInt(v.GetText(), 10, 64)
+	}
+
+	if code < 100 || code > 599 {
+		return ""
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
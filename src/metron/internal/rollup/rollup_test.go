@@ -0,0 +1,153 @@
+package rollup_test
+
+import (
+	"sync"
+	"time"
+
+	"metron/internal/rollup"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollup", func() {
+	var (
+		spySetter *SpySetter
+		r         *rollup.Rollup
+	)
+
+	BeforeEach(func() {
+		spySetter = &SpySetter{}
+		r = rollup.New(spySetter, time.Hour, 10, false)
+	})
+
+	It("passes envelopes that are not timers through", func() {
+		e := &v2.Envelope{
+			Message: &v2.Envelope_Log{Log: &v2.Log{Payload: []byte("some-log")}},
+		}
+		r.Set(e)
+
+		Expect(spySetter.Envelopes()).To(ConsistOf(e))
+	})
+
+	It("passes timers through when configured to", func() {
+		r = rollup.New(spySetter, time.Hour, 10, true)
+		e := buildTimer("app-1", "http://app.example.com/path", 200, 10*time.Millisecond)
+		r.Set(e)
+
+		Expect(spySetter.Envelopes()).To(ConsistOf(e))
+	})
+
+	It("does not pass timers through by default", func() {
+		r.Set(buildTimer("app-1", "http://app.example.com/path", 200, 10*time.Millisecond))
+
+		Expect(spySetter.Envelopes()).To(BeEmpty())
+	})
+
+	It("counts timers per app, route and status class", func() {
+		r.Set(buildTimer("app-1", "http://app.example.com/a", 200, 10*time.Millisecond))
+		r.Set(buildTimer("app-1", "app.example.com/b?c=d", 201, 10*time.Millisecond))
+		r.Set(buildTimer("app-1", "http://app.example.com/a", 503, 10*time.Millisecond))
+		r.Set(buildTimer("app-2", "http://other.example.com/a", 200, 10*time.Millisecond))
+		r.Flush()
+
+		counts := make(map[string]uint64)
+		for _, e := range spySetter.Envelopes() {
+			if e.GetCounter() == nil {
+				continue
+			}
+			Expect(e.GetCounter().Name).To(Equal("http_count"))
+			key := e.SourceId + " " + e.Tags["route"].GetText() + " " + e.Tags["status_class"].GetText()
+			counts[key] += e.GetCounter().GetDelta()
+		}
+
+		Expect(counts).To(Equal(map[string]uint64{
+			"app-1 app.example.com 2xx":   2,
+			"app-1 app.example.com 5xx":   1,
+			"app-2 other.example.com 2xx": 1,
+		}))
+	})
+
+	It("sets latency gauges for each series", func() {
+		for i := 1; i <= 100; i++ {
+			r.Set(buildTimer("app-1", "http://app.example.com/", 200, time.Duration(i)*time.Millisecond))
+		}
+		r.Flush()
+
+		gauges := make(map[string]float64)
+		for _, e := range spySetter.Envelopes() {
+			for name, v := range e.GetGauge().GetMetrics() {
+				Expect(v.Unit).To(Equal("ms"))
+				gauges[name] = v.Value
+			}
+		}
+
+		Expect(gauges).To(HaveLen(4))
+		Expect(gauges["http_latency_p50"]).To(BeNumerically("~", 50, 10))
+		Expect(gauges["http_latency_p95"]).To(BeNumerically("~", 95, 10))
+		Expect(gauges["http_latency_p99"]).To(BeNumerically("~", 99, 10))
+		Expect(gauges["http_latency_max"]).To(Equal(100.0))
+	})
+
+	It("starts a new interval after flushing", func() {
+		r.Set(buildTimer("app-1", "http://app.example.com/", 200, time.Millisecond))
+		r.Flush()
+		spySetter.Reset()
+
+		r.Flush()
+		Expect(spySetter.Envelopes()).To(BeEmpty())
+	})
+
+	It("drops timers of series beyond the limit", func() {
+		r = rollup.New(spySetter, time.Hour, 1, false)
+		r.Set(buildTimer("app-1", "http://app.example.com/", 200, time.Millisecond))
+		r.Set(buildTimer("app-2", "http://app.example.com/", 200, time.Millisecond))
+		r.Flush()
+
+		for _, e := range spySetter.Envelopes() {
+			Expect(e.SourceId).To(Equal("app-1"))
+		}
+	})
+})
+
+func buildTimer(sourceID, uri string, statusCode int64, d time.Duration) *v2.Envelope {
+	stop := time.Now()
+	return &v2.Envelope{
+		SourceId: sourceID,
+		Message: &v2.Envelope_Timer{
+			Timer: &v2.Timer{
+				Name:  "http",
+				Start: stop.Add(-d).UnixNano(),
+				Stop:  stop.UnixNano(),
+			},
+		},
+		Tags: map[string]*v2.Value{
+			"uri":         {Data: &v2.Value_Text{Text: uri}},
+			"status_code": {Data: &v2.Value_Integer{Integer: statusCode}},
+		},
+	}
+}
+
+type SpySetter struct {
+	mu        sync.Mutex
+	envelopes []*v2.Envelope
+}
+
+func (s *SpySetter) Set(e *v2.Envelope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.envelopes = append(s.envelopes, e)
+}
+
+func (s *SpySetter) Envelopes() []*v2.Envelope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.envelopes
+}
+
+func (s *SpySetter) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.envelopes = nil
+}
@@ -0,0 +1,74 @@
+package rollup
+
+import (
+	"plumbing/conversion"
+	v2 "plumbing/v2"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+type EnvelopeWriter interface {
+	Write(*events.Envelope)
+}
+
+// V1Writer records v1 HttpStartStop events in a Rollup after converting
+// them to v2 timers. Every other event is written as is.
+type V1Writer struct {
+	writer      EnvelopeWriter
+	rollup      *Rollup
+	passThrough bool
+}
+
+// NewV1Writer returns a V1Writer that records into r and writes to w. When
+// passThrough is true HttpStartStop events are written to w as well.
+func NewV1Writer(w EnvelopeWriter, r *Rollup, passThrough bool) *V1Writer {
+	return &V1Writer{
+		writer:      w,
+		rollup:      r,
+		passThrough: passThrough,
+	}
+}
+
+func (w *V1Writer) Write(e *events.Envelope) {
+	if e.GetEventType() == events.Envelope_HttpStartStop {
+		w.rollup.Record(conversion.ToV2(e))
+		if !w.passThrough {
+			return
+		}
+	}
+	w.writer.Write(e)
+}
+
+// V1Setter converts the envelopes set by a Rollup to v1 and writes them.
+type V1Setter struct {
+	writer EnvelopeWriter
+	origin string
+}
+
+// NewV1Setter returns a V1Setter that writes to w with the given origin.
+func NewV1Setter(w EnvelopeWriter, origin string) *V1Setter {
+	return &V1Setter{
+		writer: w,
+		origin: origin,
+	}
+}
+
+func (s *V1Setter) Set(e *v2.Envelope) {
+	if _, ok := e.Tags["origin"]; !ok {
+		e.Tags["origin"] = textValue(s.origin)
+	}
+
+	v1e := conversion.ToV1(e)
+	if v1e == nil {
+		return
+	}
+
+	// Rolled up counters carry deltas which the v1 aggregator turns into
+	// totals.
+	if c := e.GetCounter(); c != nil {
+		v1e.CounterEvent.Delta = proto.Uint64(c.GetDelta())
+		v1e.CounterEvent.Total = nil
+	}
+	s.writer.Write(v1e)
+}
@@ -0,0 +1,98 @@
+package rollup_test
+
+import (
+	"time"
+
+	"metron/internal/rollup"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("V1", func() {
+	var (
+		spyWriter *SpyEnvelopeWriter
+		r         *rollup.Rollup
+	)
+
+	BeforeEach(func() {
+		spyWriter = &SpyEnvelopeWriter{}
+		r = rollup.New(rollup.NewV1Setter(spyWriter, "MetronAgent"), time.Hour, 10, false)
+	})
+
+	It("rolls up HttpStartStop events", func() {
+		w := rollup.NewV1Writer(spyWriter, r, false)
+		w.Write(buildHTTPStartStop(200))
+		w.Write(buildHTTPStartStop(500))
+
+		Expect(spyWriter.envelopes).To(BeEmpty())
+
+		r.Flush()
+
+		var counters, valueMetrics int
+		for _, e := range spyWriter.envelopes {
+			Expect(e.GetOrigin()).To(Equal("MetronAgent"))
+			Expect(e.GetTags()).To(HaveKeyWithValue("route", "app.example.com"))
+
+			switch e.GetEventType() {
+			case events.Envelope_CounterEvent:
+				counters++
+				Expect(e.GetCounterEvent().GetName()).To(Equal("http_count"))
+				Expect(e.GetCounterEvent().GetDelta()).To(Equal(uint64(1)))
+			case events.Envelope_ValueMetric:
+				valueMetrics++
+				Expect(e.GetValueMetric().GetUnit()).To(Equal("ms"))
+			}
+		}
+		Expect(counters).To(Equal(2))
+		Expect(valueMetrics).To(Equal(8))
+	})
+
+	It("writes HttpStartStop events when configured to", func() {
+		w := rollup.NewV1Writer(spyWriter, r, true)
+		e := buildHTTPStartStop(200)
+		w.Write(e)
+
+		Expect(spyWriter.envelopes).To(ConsistOf(e))
+	})
+
+	It("writes other events", func() {
+		w := rollup.NewV1Writer(spyWriter, r, false)
+		e := &events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_LogMessage.Enum(),
+		}
+		w.Write(e)
+
+		Expect(spyWriter.envelopes).To(ConsistOf(e))
+	})
+})
+
+func buildHTTPStartStop(statusCode int32) *events.Envelope {
+	stop := time.Now()
+	return &events.Envelope{
+		Origin:    proto.String("gorouter"),
+		EventType: events.Envelope_HttpStartStop.Enum(),
+		HttpStartStop: &events.HttpStartStop{
+			StartTimestamp: proto.Int64(stop.Add(-10 * time.Millisecond).UnixNano()),
+			StopTimestamp:  proto.Int64(stop.UnixNano()),
+			RequestId:      &events.UUID{Low: proto.Uint64(1), High: proto.Uint64(2)},
+			PeerType:       events.PeerType_Client.Enum(),
+			Method:         events.Method_GET.Enum(),
+			Uri:            proto.String("http://app.example.com/path"),
+			StatusCode:     proto.Int32(statusCode),
+			ApplicationId:  &events.UUID{Low: proto.Uint64(3), High: proto.Uint64(4)},
+		},
+	}
+}
+
+type SpyEnvelopeWriter struct {
+	envelopes []*events.Envelope
+}
+
+func (s *SpyEnvelopeWriter) Write(e *events.Envelope) {
+	s.envelopes = append(s.envelopes, e)
+}
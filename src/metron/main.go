@@ -1,18 +1,23 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"health"
 	"log"
 	"math/rand"
 	"metric"
 	"profiler"
+	"prometheus"
 	"runtime"
+	"signalmanager"
 	"time"
 
 	"google.golang.org/grpc"
 
 	"metron/app"
+	"metron/internal/enrichment"
 	"plumbing"
 )
 
@@ -54,10 +59,25 @@ func main() {
 		log.Fatalf("Could not use GRPC creds for server: %s", err)
 	}
 
-	appV1 := app.NewV1App(config, clientCreds)
+	var promRegistry *prometheus.Registry
+	if config.PrometheusPort != 0 {
+		promRegistry = prometheus.NewRegistry("metron")
+	}
+
+	// The tags file is loaded and watched once for both APIs.
+	var enricher *enrichment.Enricher
+	if config.TagsFile != "" {
+		enricher, err = enrichment.New(config.TagsFile)
+		if err != nil {
+			log.Fatalf("Unable to load tags file: %s", err)
+		}
+		go enricher.Start()
+	}
+
+	appV1 := app.NewV1App(config, clientCreds, promRegistry, enricher)
 	go appV1.Start()
 
-	appV2 := app.NewV2App(config, clientCreds, serverCreds)
+	appV2 := app.NewV2App(config, clientCreds, serverCreds, enricher)
 	go appV2.Start()
 
 	metricsCreds, err := plumbing.NewCredentials(
@@ -77,9 +97,31 @@ func main() {
 		metric.WithOrigin("loggregator.metron"),
 		metric.WithAddr(fmt.Sprintf("localhost:%d", config.GRPC.Port)),
 		metric.WithDeploymentMeta(config.Deployment, config.Job, config.Index),
+		metric.WithPrometheus(promRegistry),
 	)
 
+	if config.HealthPort != 0 {
+		registry := health.NewRegistry()
+		registry.Register("doppler_connections", func() error {
+			if appV1.ConnectedDopplers()+appV2.ConnectedDopplers() == 0 {
+				return errors.New("no connections to dopplers")
+			}
+			return nil
+		})
+		go health.NewServer(config.HealthPort, registry).Start()
+	}
+
+	if promRegistry != nil {
+		go prometheus.NewServer(config.PrometheusPort, promRegistry).Start()
+	}
+
+	killChan := signalmanager.RegisterKillSignalChannel()
+
 	// We start the profiler last so that we can definitively say that we're
 	// all connected and ready for data by the time the profiler starts up.
-	profiler.New(config.PPROFPort).Start()
+	go profiler.New(config.PPROFPort).Start()
+
+	<-killChan
+	log.Print("Shutting down")
+	metric.Flush()
 }
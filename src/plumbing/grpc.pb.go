@@ -62,6 +62,9 @@ func (*PushResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []in
 type SubscriptionRequest struct {
 	ShardID string  `protobuf:"bytes,1,opt,name=shardID" json:"shardID,omitempty"`
 	Filter  *Filter `protobuf:"bytes,2,opt,name=filter" json:"filter,omitempty"`
+	// sampleRate delivers 1 in sampleRate envelopes to a firehose
+	// subscription. Every envelope is delivered when it is 0 or 1.
+	SampleRate uint32 `protobuf:"varint,3,opt,name=sampleRate" json:"sampleRate,omitempty"`
 }
 
 func (m *SubscriptionRequest) Reset()                    { *m = SubscriptionRequest{} }
@@ -502,29 +505,30 @@ var _DopplerIngestor_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("grpc.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 381 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x93, 0xdf, 0x8f, 0xd2, 0x40,
-	0x10, 0xc7, 0xa9, 0xc4, 0x42, 0x07, 0xa2, 0xb8, 0x18, 0x6d, 0xaa, 0x26, 0xb8, 0x31, 0xb1, 0xbe,
-	0x54, 0x83, 0x3e, 0xfa, 0x84, 0x68, 0x24, 0x81, 0x68, 0xd6, 0x27, 0xe3, 0xd3, 0xb6, 0x8c, 0xa5,
-	0x49, 0x6f, 0x77, 0x6f, 0x77, 0x7b, 0xc9, 0xfd, 0xe1, 0xf7, 0x7e, 0x01, 0x5a, 0xda, 0xe3, 0x7a,
-	0xf0, 0xb8, 0xf3, 0xe3, 0x33, 0xf3, 0x9d, 0x99, 0x05, 0x48, 0xb5, 0x4a, 0x22, 0xa5, 0xa5, 0x95,
-	0xa4, 0xaf, 0xf2, 0xe2, 0x22, 0xce, 0x44, 0x4a, 0x43, 0x18, 0x7e, 0x17, 0x57, 0x98, 0x4b, 0x85,
-	0x73, 0x6e, 0x39, 0xf1, 0xa1, 0xa7, 0xf8, 0x75, 0x2e, 0xf9, 0xda, 0x77, 0x26, 0x4e, 0x38, 0x64,
-	0xd5, 0x93, 0x3e, 0x81, 0xe1, 0xef, 0xc2, 0x6c, 0x18, 0x1a, 0x25, 0x85, 0x41, 0xfa, 0x17, 0xc6,
-	0x7f, 0x8a, 0xd8, 0x24, 0x3a, 0x53, 0x36, 0x93, 0x82, 0xe1, 0x65, 0x81, 0xc6, 0x6e, 0x01, 0x66,
-	0xc3, 0xf5, 0x7a, 0x31, 0xdf, 0x01, 0x3c, 0x56, 0x3d, 0x49, 0x08, 0xee, 0xff, 0x2c, 0xb7, 0xa8,
-	0xfd, 0x47, 0x13, 0x27, 0x1c, 0x4c, 0x47, 0x51, 0xd5, 0x45, 0xf4, 0x63, 0x67, 0x67, 0xa5, 0x9f,
-	0x32, 0x70, 0xf7, 0x16, 0xf2, 0x1c, 0x1e, 0x73, 0xa5, 0x0e, 0xac, 0xfd, 0x83, 0xbc, 0x87, 0x6e,
-	0x2e, 0xd3, 0x12, 0x33, 0xae, 0x31, 0x4b, 0x99, 0xee, 0xf3, 0x7e, 0x76, 0xd8, 0x36, 0x62, 0xe6,
-	0x41, 0x6f, 0x85, 0xc6, 0xf0, 0x14, 0xe9, 0x00, 0xbc, 0x83, 0x9b, 0xbe, 0x83, 0x7e, 0xa5, 0xe3,
-	0x84, 0xe2, 0x8f, 0xf0, 0xf2, 0x9b, 0x14, 0x96, 0x67, 0x02, 0xf5, 0x0a, 0xad, 0xce, 0x12, 0x53,
-	0xa9, 0x6c, 0xed, 0x8b, 0x7e, 0x01, 0xff, 0x7e, 0x42, 0x5b, 0x99, 0x6e, 0xb3, 0xcc, 0x07, 0x78,
-	0xc6, 0x30, 0x41, 0x61, 0x97, 0x32, 0x3d, 0x53, 0x20, 0x02, 0xd2, 0x0c, 0x3d, 0x87, 0x9e, 0xde,
-	0x38, 0xd0, 0x9b, 0x4b, 0xa5, 0x72, 0xd4, 0x64, 0x06, 0x5e, 0xb9, 0xaf, 0x18, 0xc9, 0x9b, 0x7a,
-	0x68, 0x2d, 0x4b, 0x0c, 0x48, 0xed, 0x3e, 0xec, 0xbb, 0xf3, 0xc9, 0x21, 0xff, 0x60, 0x74, 0x2c,
-	0x90, 0xbc, 0xad, 0x63, 0x1f, 0x98, 0x56, 0x40, 0x4f, 0x85, 0x54, 0x78, 0xb2, 0x00, 0xa8, 0xc5,
-	0x91, 0x57, 0xcd, 0x16, 0x8e, 0xa6, 0x13, 0xbc, 0x6e, 0x77, 0x56, 0xa8, 0xe9, 0x2f, 0x78, 0x5a,
-	0xca, 0x5e, 0x88, 0x14, 0x8d, 0x95, 0x9a, 0x7c, 0x05, 0x77, 0x7b, 0xbe, 0xa8, 0xc9, 0x8b, 0x3a,
-	0xb9, 0x79, 0xfa, 0x41, 0xc3, 0x7e, 0xe7, 0xd0, 0x3b, 0xa1, 0x13, 0xbb, 0xbb, 0x7f, 0xf3, 0xf9,
-	0x36, 0x00, 0x00, 0xff, 0xff, 0xb3, 0x5b, 0xca, 0x7a, 0x45, 0x03, 0x00, 0x00,
+	// 389 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x03, 0x85, 0x53, 0x4d, 0x4f, 0xc2, 0x40,
+	0x10, 0xa5, 0x12, 0xf9, 0x18, 0x50, 0x71, 0x31, 0xda, 0xd4, 0x8f, 0x68, 0x63, 0x22, 0x5e, 0xaa,
+	0x41, 0x8f, 0x9e, 0x10, 0x8d, 0x24, 0x12, 0xcd, 0x7a, 0xf4, 0xb4, 0xc0, 0x58, 0x9a, 0x94, 0xee,
+	0xba, 0xbb, 0x35, 0xe1, 0x87, 0x7b, 0xb7, 0x40, 0x4b, 0x2b, 0x22, 0x1c, 0x67, 0xe6, 0xcd, 0x7b,
+	0x33, 0x6f, 0x77, 0x00, 0x5c, 0x29, 0xfa, 0x8e, 0x90, 0x5c, 0x73, 0x52, 0x12, 0x7e, 0x38, 0xea,
+	0x79, 0x81, 0x6b, 0x37, 0xa0, 0xfa, 0x10, 0x7c, 0xa1, 0xcf, 0x05, 0xb6, 0x99, 0x66, 0xc4, 0x84,
+	0xa2, 0x60, 0x63, 0x9f, 0xb3, 0x81, 0x69, 0x9c, 0x1a, 0x8d, 0x2a, 0x4d, 0x42, 0x7b, 0x1b, 0xaa,
+	0xaf, 0xa1, 0x1a, 0x52, 0x54, 0x82, 0x07, 0x0a, 0xed, 0x31, 0xd4, 0xdf, 0xc2, 0x9e, 0xea, 0x4b,
+	0x4f, 0x68, 0x8f, 0x07, 0x14, 0x3f, 0x43, 0x54, 0x7a, 0x42, 0xa0, 0x86, 0x4c, 0x0e, 0x3a, 0xed,
+	0x29, 0x41, 0x99, 0x26, 0x21, 0x69, 0x40, 0xe1, 0xc3, 0xf3, 0x35, 0x4a, 0x73, 0x23, 0x2a, 0x54,
+	0x9a, 0x35, 0x27, 0x99, 0xc2, 0x79, 0x9c, 0xe6, 0x69, 0x5c, 0x27, 0x27, 0x00, 0x8a, 0x8d, 0x84,
+	0x8f, 0x94, 0x69, 0x34, 0xf3, 0x11, 0x7a, 0x8b, 0x66, 0x32, 0x36, 0x85, 0xc2, 0xac, 0x83, 0xec,
+	0xc1, 0x26, 0x13, 0x62, 0xae, 0x35, 0x0b, 0xc8, 0x05, 0xe4, 0x7d, 0xee, 0xc6, 0x32, 0xf5, 0x54,
+	0xe6, 0x99, 0xbb, 0xb3, 0xbe, 0xa7, 0x1c, 0x9d, 0x20, 0x5a, 0x65, 0x28, 0x76, 0x51, 0x29, 0xe6,
+	0xa2, 0x5d, 0x81, 0xf2, 0xbc, 0x6c, 0x9f, 0x43, 0x29, 0xd9, 0x73, 0x85, 0x23, 0x57, 0x70, 0x70,
+	0xcf, 0x03, 0xcd, 0xbc, 0x00, 0x65, 0x17, 0xb5, 0xf4, 0xfa, 0x2a, 0x71, 0x61, 0xe9, 0x5c, 0xf6,
+	0x2d, 0x98, 0x7f, 0x1b, 0x96, 0xc9, 0xe4, 0xb3, 0x32, 0x97, 0xb0, 0x4b, 0xb1, 0x8f, 0x81, 0x8e,
+	0xe6, 0x5b, 0x23, 0xe0, 0x00, 0xc9, 0x42, 0xd7, 0x51, 0x37, 0xbf, 0x0d, 0x28, 0xb6, 0xb9, 0x88,
+	0x7c, 0x95, 0xa4, 0x05, 0xe5, 0xf8, 0x3d, 0x7b, 0x48, 0x8e, 0x53, 0xd3, 0x96, 0x3c, 0xb2, 0x45,
+	0xd2, 0xf2, 0xfc, 0x3f, 0xe4, 0xae, 0x0d, 0xf2, 0x0e, 0xb5, 0xc5, 0x05, 0xc9, 0x59, 0x8a, 0xfd,
+	0xc7, 0x2d, 0xcb, 0x5e, 0x05, 0x49, 0xe8, 0x49, 0x07, 0x20, 0x5d, 0x8e, 0x1c, 0x66, 0x47, 0x58,
+	0x70, 0xc7, 0x3a, 0x5a, 0x5e, 0x4c, 0xa8, 0x9a, 0x2f, 0xb0, 0x13, 0xaf, 0xdd, 0x09, 0xdc, 0xa8,
+	0x81, 0x4b, 0x72, 0x07, 0x85, 0xc9, 0xf7, 0x8e, 0x8c, 0xd8, 0x4f, 0x9b, 0xb3, 0xa7, 0x61, 0x65,
+	0xf2, 0xbf, 0x0e, 0x21, 0xd7, 0x30, 0x7a, 0x85, 0xe9, 0x5d, 0xdd, 0xfc, 0x00, 0x78, 0xfb, 0x12,
+	0xe6, 0x65, 0x03, 0x00, 0x00,
 }
@@ -89,6 +89,21 @@ func (p *Pool) RecentLogs(dopplerAddr string, ctx context.Context, req *RecentLo
 	return client.RecentLogs(ctx, req)
 }
 
+// Connected returns the number of registered dopplers that have at least one
+// established client.
+func (p *Pool) Connected() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var connected int
+	for _, clients := range p.dopplers {
+		if p.fetchClient(clients) != nil {
+			connected++
+		}
+	}
+	return connected
+}
+
 func (p *Pool) Close(dopplerAddr string) {
 	p.mu.Lock()
 	clients := p.dopplers[dopplerAddr]
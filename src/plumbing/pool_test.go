@@ -76,6 +76,26 @@ var _ = Describe("Pool", func() {
 			})
 		})
 
+		Describe("Connected()", func() {
+			It("reports the number of dopplers with a connection", func() {
+				Expect(pool.Connected()).To(Equal(0))
+
+				pool.RegisterDoppler(lis1.Addr().String())
+				pool.RegisterDoppler(lis2.Addr().String())
+
+				Eventually(pool.Connected).Should(Equal(2))
+			})
+
+			It("does not count closed dopplers", func() {
+				pool.RegisterDoppler(lis1.Addr().String())
+				Eventually(pool.Connected).Should(Equal(1))
+
+				pool.Close(lis1.Addr().String())
+
+				Expect(pool.Connected()).To(Equal(0))
+			})
+		})
+
 		Describe("Close()", func() {
 			BeforeEach(func() {
 				pool.RegisterDoppler(lis1.Addr().String())
@@ -0,0 +1,56 @@
+package prometheus
+
+import (
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// Emitter records the value metrics and counter events that pass through it
+// in a Registry before handing them to the wrapped emitter. Wrapping the
+// emitter given to a dropsonde metric sender exposes everything reported
+// via metrics.SendValue, metrics.IncrementCounter and metrics.BatchCounter.
+type Emitter struct {
+	registry *Registry
+	emitter  emitter.EventEmitter
+}
+
+// NewEmitter returns an Emitter that records into r and emits to e.
+func NewEmitter(r *Registry, e emitter.EventEmitter) *Emitter {
+	return &Emitter{
+		registry: r,
+		emitter:  e,
+	}
+}
+
+func (e *Emitter) Emit(event events.Event) error {
+	envelope, err := emitter.Wrap(event, e.Origin())
+	if err != nil {
+		return err
+	}
+
+	return e.EmitEnvelope(envelope)
+}
+
+func (e *Emitter) EmitEnvelope(envelope *events.Envelope) error {
+	e.record(envelope)
+	return e.emitter.EmitEnvelope(envelope)
+}
+
+func (e *Emitter) Origin() string {
+	return e.emitter.Origin()
+}
+
+func (e *Emitter) record(envelope *events.Envelope) {
+	switch envelope.GetEventType() {
+	case events.Envelope_ValueMetric:
+		m := envelope.GetValueMetric()
+		e.registry.SetGauge(m.GetName(), m.GetValue(), envelope.GetTags())
+	case events.Envelope_CounterEvent:
+		c := envelope.GetCounterEvent()
+		if c.GetDelta() == 0 && c.GetTotal() != 0 {
+			e.registry.SetCounter(c.GetName(), float64(c.GetTotal()), envelope.GetTags())
+			return
+		}
+		e.registry.AddCounter(c.GetName(), float64(c.GetDelta()), envelope.GetTags())
+	}
+}
@@ -0,0 +1,63 @@
+package prometheus_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"prometheus"
+
+	"github.com/cloudfoundry/dropsonde/emitter/fake"
+	"github.com/cloudfoundry/dropsonde/metric_sender"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		registry    *prometheus.Registry
+		fakeEmitter *fake.FakeEventEmitter
+		sender      *metric_sender.MetricSender
+	)
+
+	var scrape = func() string {
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).ToNot(HaveOccurred())
+		recorder := httptest.NewRecorder()
+		registry.ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry("tc")
+		fakeEmitter = fake.NewFakeEventEmitter("origin")
+		sender = metric_sender.NewMetricSender(prometheus.NewEmitter(registry, fakeEmitter))
+	})
+
+	It("records value metrics as gauges", func() {
+		Expect(sender.SendValue("dopplerProxy.firehoses", 4, "connections")).To(Succeed())
+
+		Expect(scrape()).To(ContainSubstring("tc_dopplerProxy_firehoses 4\n"))
+		Expect(fakeEmitter.GetEnvelopes()).To(HaveLen(1))
+	})
+
+	It("records counter events as counters", func() {
+		Expect(sender.AddToCounter("listeners.receivedEnvelopes", 3)).To(Succeed())
+		Expect(sender.IncrementCounter("listeners.receivedEnvelopes")).To(Succeed())
+
+		Expect(scrape()).To(ContainSubstring("tc_listeners_receivedEnvelopes 4\n"))
+		Expect(fakeEmitter.GetEnvelopes()).To(HaveLen(2))
+	})
+
+	It("records counter tags as labels", func() {
+		err := sender.Counter("egress").SetTag("protocol", "grpc").Increment()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(scrape()).To(ContainSubstring(`tc_egress{protocol="grpc"} 1`))
+	})
+
+	It("uses the origin of the wrapped emitter", func() {
+		e := prometheus.NewEmitter(registry, fakeEmitter)
+
+		Expect(e.Origin()).To(Equal("origin"))
+	})
+})
@@ -0,0 +1,230 @@
+// Package prometheus exposes a component's internal metrics in the
+// Prometheus text format so they can be scraped without depending on the
+// Loggregator pipeline that delivers them.
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	counterType = "counter"
+	gaugeType   = "gauge"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Registry holds the current value of every counter and gauge reported by
+// a component. A nil *Registry is valid and discards everything reported to
+// it.
+type Registry struct {
+	namespace string
+
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	metricType string
+	series     map[string]*series
+}
+
+type series struct {
+	value float64
+}
+
+// NewRegistry returns an empty Registry. Every metric name is prefixed with
+// the namespace.
+func NewRegistry(namespace string) *Registry {
+	return &Registry{
+		namespace: namespace,
+		families:  make(map[string]*family),
+	}
+}
+
+// AddCounter adds delta to the counter with the given name and tags.
+func (r *Registry) AddCounter(name string, delta float64, tags map[string]string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series(counterType, name, tags)
+	if !ok {
+		return
+	}
+	s.value += delta
+}
+
+// SetCounter sets the counter with the given name and tags to total. It is
+// used when a source reports running totals instead of deltas.
+func (r *Registry) SetCounter(name string, total float64, tags map[string]string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series(counterType, name, tags)
+	if !ok {
+		return
+	}
+	s.value = total
+}
+
+// SetGauge sets the gauge with the given name and tags to value.
+func (r *Registry) SetGauge(name string, value float64, tags map[string]string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series(gaugeType, name, tags)
+	if !ok {
+		return
+	}
+	s.value = value
+}
+
+// series returns the series for the name and tags, creating it if
+// necessary. It reports false if the name is already registered with a
+// different type.
+func (r *Registry) series(metricType, name string, tags map[string]string) (*series, bool) {
+	name = r.metricName(name)
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{
+			metricType: metricType,
+			series:     make(map[string]*series),
+		}
+		r.families[name] = f
+	}
+	if f.metricType != metricType {
+		return nil, false
+	}
+
+	labels := formatLabels(tags)
+	s, ok := f.series[labels]
+	if !ok {
+		s = &series{}
+		f.series[labels] = s
+	}
+	return s, true
+}
+
+func (r *Registry) metricName(name string) string {
+	if r.namespace != "" {
+		name = r.namespace + "_" + name
+	}
+	return sanitize(name)
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(r.format())
+}
+
+func (r *Registry) format() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, f.metricType)
+
+		var labels []string
+		for l := range f.series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		for _, l := range labels {
+			value := strconv.FormatFloat(f.series[l].value, 'g', -1, 64)
+			fmt.Fprintf(&buf, "%s%s %s\n", name, l, value)
+		}
+	}
+	return buf.Bytes()
+}
+
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, sanitize(k), labelEscaper.Replace(tags[k])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sanitize replaces every character that is not valid in a Prometheus
+// metric or label name with an underscore.
+func sanitize(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// Server serves the metrics of a Registry on /metrics.
+type Server struct {
+	port     uint32
+	registry *Registry
+}
+
+// NewServer returns a Server that listens on the given port on all
+// interfaces.
+func NewServer(port uint32, r *Registry) *Server {
+	return &Server{
+		port:     port,
+		registry: r,
+	}
+}
+
+// Start listens for scrape requests. It blocks until the server fails.
+func (s *Server) Start() {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		log.Panicf("Error creating metrics listener: %s", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.registry)
+
+	log.Printf("Starting metrics server on: %s", lis.Addr().String())
+	err = http.Serve(lis, mux)
+	if err != nil {
+		log.Panicf("Error starting metrics server: %s", err)
+	}
+}
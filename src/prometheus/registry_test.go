@@ -0,0 +1,95 @@
+package prometheus_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var registry *prometheus.Registry
+
+	var scrape = func(r *prometheus.Registry) string {
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).ToNot(HaveOccurred())
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		return recorder.Body.String()
+	}
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry("doppler")
+	})
+
+	It("accumulates counters by name and tags", func() {
+		registry.AddCounter("dropped", 2, map[string]string{"direction": "ingress"})
+		registry.AddCounter("dropped", 3, map[string]string{"direction": "ingress"})
+		registry.AddCounter("dropped", 1, map[string]string{"direction": "egress"})
+
+		Expect(scrape(registry)).To(Equal(
+			"# TYPE doppler_dropped counter\n" +
+				"doppler_dropped{direction=\"egress\"} 1\n" +
+				"doppler_dropped{direction=\"ingress\"} 5\n",
+		))
+	})
+
+	It("sets counters reported as totals", func() {
+		registry.AddCounter("sent", 2, nil)
+		registry.SetCounter("sent", 10, nil)
+
+		Expect(scrape(registry)).To(Equal("# TYPE doppler_sent counter\ndoppler_sent 10\n"))
+	})
+
+	It("replaces gauge values", func() {
+		registry.SetGauge("dopplerProxy.firehoses", 3, nil)
+		registry.SetGauge("dopplerProxy.firehoses", 1, nil)
+
+		Expect(scrape(registry)).To(Equal(
+			"# TYPE doppler_dopplerProxy_firehoses gauge\n" +
+				"doppler_dopplerProxy_firehoses 1\n",
+		))
+	})
+
+	It("sorts metrics by name", func() {
+		registry.SetGauge("b", 1, nil)
+		registry.AddCounter("a", 1, nil)
+
+		Expect(scrape(registry)).To(Equal(
+			"# TYPE doppler_a counter\ndoppler_a 1\n" +
+				"# TYPE doppler_b gauge\ndoppler_b 1\n",
+		))
+	})
+
+	It("sanitizes names and escapes label values", func() {
+		registry.AddCounter("grpc.ingress-streams", 1, map[string]string{
+			"metric.version": "2.0",
+			"path":           `C:\logs "a"`,
+		})
+
+		Expect(scrape(registry)).To(Equal(
+			"# TYPE doppler_grpc_ingress_streams counter\n" +
+				`doppler_grpc_ingress_streams{metric_version="2.0",path="C:\\logs \"a\""} 1` + "\n",
+		))
+	})
+
+	It("ignores metrics reported with a different type", func() {
+		registry.AddCounter("conflict", 1, nil)
+		registry.SetGauge("conflict", 5, nil)
+
+		Expect(scrape(registry)).To(Equal("# TYPE doppler_conflict counter\ndoppler_conflict 1\n"))
+	})
+
+	It("discards metrics reported to a nil registry", func() {
+		var nilRegistry *prometheus.Registry
+
+		Expect(func() {
+			nilRegistry.AddCounter("a", 1, nil)
+			nilRegistry.SetCounter("a", 1, nil)
+			nilRegistry.SetGauge("b", 1, nil)
+		}).ToNot(Panic())
+	})
+})
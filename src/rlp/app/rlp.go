@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -21,6 +22,7 @@ type RLP struct {
 
 	ingressAddrs    []string
 	ingressDialOpts []grpc.DialOption
+	ingressPool     *plumbing.Pool
 
 	receiver *ingress.Receiver
 
@@ -39,6 +41,7 @@ func NewRLP(opts ...RLPOption) *RLP {
 	for _, o := range opts {
 		o(rlp)
 	}
+	rlp.ingressPool = plumbing.NewPool(20, rlp.ingressDialOpts...)
 	return rlp
 }
 
@@ -83,13 +86,21 @@ func (r *RLP) Start() {
 	r.serveEgress()
 }
 
+// Ready returns an error if the remote log proxy is not connected to any
+// server to ingress data.
+func (r *RLP) Ready() error {
+	if r.ingressPool.Connected() == 0 {
+		return errors.New("not connected to any dopplers")
+	}
+	return nil
+}
+
 func (r *RLP) setupIngress() {
 	finder := ingress.NewFinder(r.ingressAddrs)
-	pool := plumbing.NewPool(20, r.ingressDialOpts...)
 
 	batcher := &ingress.NullMetricBatcher{} // TODO: Add real metrics
 
-	connector := plumbing.NewGRPCConnector(1000, pool, finder, batcher)
+	connector := plumbing.NewGRPCConnector(1000, r.ingressPool, finder, batcher)
 	converter := ingress.NewConverter()
 	r.receiver = ingress.NewReceiver(converter, ingress.NewRequestConverter(), connector)
 }
@@ -23,7 +23,7 @@ var _ = Describe("Start", func() {
 		doppler, dopplerLis := setupDoppler()
 		defer dopplerLis.Close()
 
-		egressLis := setupRLP(dopplerLis)
+		_, egressLis := setupRLP(dopplerLis)
 		egressStream, cleanup := setupRLPClient(egressLis)
 		defer cleanup()
 
@@ -49,6 +49,23 @@ var _ = Describe("Start", func() {
 	})
 })
 
+var _ = Describe("Ready", func() {
+	It("returns an error before connecting to a doppler", func() {
+		rlp := app.NewRLP()
+
+		Expect(rlp.Ready()).To(HaveOccurred())
+	})
+
+	It("succeeds once connected to a doppler", func() {
+		_, dopplerLis := setupDoppler()
+		defer dopplerLis.Close()
+
+		rlp, _ := setupRLP(dopplerLis)
+
+		Eventually(rlp.Ready, 5).Should(Succeed())
+	})
+})
+
 func buildLogMessage() []byte {
 	e := &events.Envelope{
 		Origin:    proto.String("some-origin"),
@@ -84,7 +101,7 @@ func setupDoppler() (*mockDopplerServer, net.Listener) {
 	return doppler, lis
 }
 
-func setupRLP(dopplerLis net.Listener) net.Listener {
+func setupRLP(dopplerLis net.Listener) (*app.RLP, net.Listener) {
 	egressLis, err := net.Listen("tcp", "localhost:0")
 	egressLis.Close()
 	Expect(err).ToNot(HaveOccurred())
@@ -113,7 +130,7 @@ func setupRLP(dopplerLis net.Listener) net.Listener {
 		app.WithEgressServerOptions(grpc.Creds(egressTLSCredentials)),
 	)
 	go rlp.Start()
-	return egressLis
+	return rlp, egressLis
 }
 
 func setupRLPClient(egressLis net.Listener) (v2.Egress_ReceiverClient, func()) {
@@ -7,6 +7,7 @@ import (
 
 	"google.golang.org/grpc"
 
+	"health"
 	"plumbing"
 	"profiler"
 	"rlp/app"
@@ -16,6 +17,7 @@ func main() {
 	egressPort := flag.Int("egress-port", 0, "The port of the Egress server")
 	ingressAddrsList := flag.String("ingress-addrs", "", "The addresses of Dopplers")
 	pprofPort := flag.Int("pprof-port", 6061, "The port of pprof for health checks")
+	healthPort := flag.Int("health-port", 0, "The port of the health and readiness endpoints, disabled when 0")
 
 	caFile := flag.String("ca", "", "The file path for the CA cert")
 	certFile := flag.String("cert", "", "The file path for the client cert")
@@ -46,5 +48,11 @@ func main() {
 	)
 	go rlp.Start()
 
+	if *healthPort != 0 {
+		registry := health.NewRegistry()
+		registry.Register("ingress", rlp.Ready)
+		go health.NewServer(uint32(*healthPort), registry).Start()
+	}
+
 	profiler.New(uint32(*pprofPort)).Start()
 }
@@ -37,6 +37,8 @@ type Config struct {
 
 	SkipCertVerify bool
 	PPROFPort      uint32
+	HealthPort     uint32
+	PrometheusPort uint32
 }
 
 func ParseConfig(configFile string) (*Config, error) {
@@ -2,11 +2,13 @@ package main
 
 import (
 	"flag"
+	"health"
 	"log"
 	"os"
 	"os/signal"
 	"plumbing"
 	"profiler"
+	"prometheus"
 	"syscall"
 	"syslog_drain_binder/config"
 	"time"
@@ -18,6 +20,8 @@ import (
 
 	"code.cloudfoundry.org/workpool"
 	"github.com/cloudfoundry/dropsonde"
+	"github.com/cloudfoundry/dropsonde/metric_sender"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
 	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/storeadapter/etcdstoreadapter"
 )
@@ -49,6 +53,12 @@ func main() {
 	tlsConfig.InsecureSkipVerify = conf.SkipCertVerify
 
 	dropsonde.Initialize(conf.MetronAddress, "syslog_drain_binder")
+	if conf.PrometheusPort != 0 {
+		promRegistry := prometheus.NewRegistry("syslog_drain_binder")
+		sender := metric_sender.NewMetricSender(prometheus.NewEmitter(promRegistry, dropsonde.AutowiredEmitter()))
+		metrics.Initialize(sender, metricbatcher.New(sender, 5*time.Second))
+		go prometheus.NewServer(conf.PrometheusPort, promRegistry).Start()
+	}
 
 	workPool, err := workpool.NewWorkPool(conf.EtcdMaxConcurrentRequests)
 	if err != nil {
@@ -75,6 +85,14 @@ func main() {
 	drainTTL := time.Duration(conf.DrainUrlTtlSeconds) * time.Second
 	store := etcd_syslog_drain_store.NewEtcdSyslogDrainStore(adapter, drainTTL)
 
+	status := NewStatus(3 * updateInterval)
+	if conf.HealthPort != 0 {
+		registry := health.NewRegistry()
+		registry.Register("etcd_leader_election", status.Election)
+		registry.Register("cloud_controller_poll", status.Poll)
+		go health.NewServer(conf.HealthPort, registry).Start()
+	}
+
 	dumpChan := registerGoRoutineDumpSignalChannel()
 	ticker := time.NewTicker(updateInterval)
 	for {
@@ -84,6 +102,7 @@ func main() {
 		case <-ticker.C:
 			if politician.IsLeader() {
 				err = politician.StayAsLeader()
+				status.RecordElection(err == nil, err)
 				if err != nil {
 					log.Printf("Error when staying leader: %s", err.Error())
 					politician.Vacate()
@@ -91,7 +110,7 @@ func main() {
 				}
 			} else {
 				err = politician.RunForElection()
-
+				status.RecordElection(err == nil, err)
 				if err != nil {
 					log.Printf("Error when running for leader: %s", err.Error())
 					politician.Vacate()
@@ -109,6 +128,7 @@ func main() {
 				politician.Vacate()
 				continue
 			}
+			status.RecordPoll()
 			drainBindings = Filter(drainBindings)
 
 			metrics.IncrementCounter("pollCount")
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status records the outcome of the most recent election and cloud
+// controller poll so that readiness can be reported while the binder runs.
+type Status struct {
+	maxPollAge time.Duration
+
+	mu          sync.Mutex
+	leader      bool
+	electionErr error
+	lastPoll    time.Time
+}
+
+// NewStatus returns a Status that considers the binder unready when it is
+// the leader and has not polled successfully within maxPollAge.
+func NewStatus(maxPollAge time.Duration) *Status {
+	return &Status{
+		maxPollAge: maxPollAge,
+		lastPoll:   time.Now(),
+	}
+}
+
+// RecordElection records the result of running for or staying as leader.
+func (s *Status) RecordElection(leader bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.leader && leader {
+		// A new leader has not had a chance to poll yet.
+		s.lastPoll = time.Now()
+	}
+	s.leader = leader
+	s.electionErr = err
+}
+
+// RecordPoll records a successful poll of the cloud controller.
+func (s *Status) RecordPoll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPoll = time.Now()
+}
+
+// Election returns the error from the last election, if any.
+func (s *Status) Election() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.electionErr != nil {
+		return fmt.Errorf("leader election failed: %s", s.electionErr)
+	}
+	return nil
+}
+
+// Poll returns an error if the binder is the leader and has not polled the
+// cloud controller successfully within the maximum poll age.
+func (s *Status) Poll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.leader {
+		return nil
+	}
+
+	age := time.Since(s.lastPoll)
+	if age > s.maxPollAge {
+		return fmt.Errorf("last successful poll was %s ago", age)
+	}
+	return nil
+}
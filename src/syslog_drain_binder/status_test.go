@@ -0,0 +1,58 @@
+package main_test
+
+import (
+	"errors"
+	"time"
+
+	syslog_drain_binder "syslog_drain_binder"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Status", func() {
+	var status *syslog_drain_binder.Status
+
+	BeforeEach(func() {
+		status = syslog_drain_binder.NewStatus(50 * time.Millisecond)
+	})
+
+	Describe("Election()", func() {
+		It("reports the last election error", func() {
+			status.RecordElection(false, errors.New("etcd is down"))
+
+			Expect(status.Election()).To(MatchError("leader election failed: etcd is down"))
+		})
+
+		It("clears the error after a successful election", func() {
+			status.RecordElection(false, errors.New("etcd is down"))
+			status.RecordElection(true, nil)
+
+			Expect(status.Election()).To(Succeed())
+		})
+	})
+
+	Describe("Poll()", func() {
+		It("succeeds when not the leader", func() {
+			time.Sleep(100 * time.Millisecond)
+
+			Expect(status.Poll()).To(Succeed())
+		})
+
+		It("succeeds when the leader has polled recently", func() {
+			status.RecordElection(true, nil)
+			status.RecordPoll()
+
+			Expect(status.Poll()).To(Succeed())
+		})
+
+		It("fails when the leader has not polled recently", func() {
+			status.RecordElection(true, nil)
+
+			Eventually(status.Poll).Should(HaveOccurred())
+
+			status.RecordPoll()
+			Expect(status.Poll()).To(Succeed())
+		})
+	})
+})
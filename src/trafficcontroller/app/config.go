@@ -26,6 +26,7 @@ type Config struct {
 	EtcdRequireTLS            bool
 	EtcdTLSClientConfig       EtcdTLSClientConfig
 
+	DeploymentName         string
 	JobName                string
 	Index                  string
 	IP                     string
@@ -34,6 +35,7 @@ type Config struct {
 	OutgoingDropsondePort  uint32
 	MetronHost             string
 	MetronPort             int
+	MetronGRPCPort         int
 	GRPC                   GRPC
 	SystemDomain           string
 	SkipCertVerify         bool
@@ -43,6 +45,27 @@ type Config struct {
 	MonitorIntervalSeconds uint
 	SecurityEventLog       string
 	PPROFPort              uint32
+	HealthPort             uint32
+	PrometheusPort         uint32
+
+	SecurityEventLogFormat     string
+	SecurityEventLogSyslog     bool
+	SecurityEventLogMaxSizeMB  int
+	SecurityEventLogMaxBackups int
+
+	UaaLocalTokenValidation            bool
+	UaaTokenKeysRefreshIntervalSeconds uint
+
+	CCRequestTimeoutSeconds          uint
+	LogAccessCacheTTLSeconds         uint
+	LogAccessCacheNegativeTTLSeconds uint
+	LogAccessCacheMaxEntries         int
+
+	MaxStreamsPerClient        int
+	MaxFirehosesPerClient      int
+	MaxBytesPerSecondPerClient int64
+
+	DrainTimeoutSeconds uint
 }
 
 func ParseConfig(configFile string) (*Config, error) {
@@ -84,6 +107,26 @@ func (c *Config) setDefaults() {
 		c.MonitorIntervalSeconds = 60
 	}
 
+	if c.UaaTokenKeysRefreshIntervalSeconds == 0 {
+		c.UaaTokenKeysRefreshIntervalSeconds = 600
+	}
+
+	if c.CCRequestTimeoutSeconds == 0 {
+		c.CCRequestTimeoutSeconds = 5
+	}
+
+	if c.LogAccessCacheMaxEntries < 1 {
+		c.LogAccessCacheMaxEntries = 10000
+	}
+
+	if c.DrainTimeoutSeconds == 0 {
+		c.DrainTimeoutSeconds = 30
+	}
+
+	if c.SecurityEventLogFormat == "" {
+		c.SecurityEventLogFormat = "cef"
+	}
+
 	if c.MetronHost == "" {
 		c.MetronHost = "127.0.0.1"
 	}
@@ -92,6 +135,10 @@ func (c *Config) setDefaults() {
 		c.MetronPort = 3457
 	}
 
+	if c.MetronGRPCPort == 0 {
+		c.MetronGRPCPort = 3458
+	}
+
 	if c.GRPC.Port == 0 {
 		c.GRPC.Port = 8082
 	}
@@ -124,6 +171,10 @@ func (c *Config) validate() error {
 		return errors.New("invalid doppler config, no GRPC.KeyFile provided")
 	}
 
+	if c.SecurityEventLogFormat != "cef" && c.SecurityEventLogFormat != "json" {
+		return errors.New("invalid security event log format, must be cef or json")
+	}
+
 	if c.UaaClientSecret == "" {
 		return errors.New("missing UAA client secret")
 	}
@@ -27,3 +27,19 @@ func init() {
 func SetInsecureSkipVerify(skipCert bool) {
 	transport.TLSClientConfig.InsecureSkipVerify = skipCert
 }
+
+// newUaaHTTPClient returns a client for background requests to UAA. It has
+// its own transport and timeout so that a hanging UAA cannot block callers
+// indefinitely.
+func newUaaHTTPClient(skipCert bool) *http.Client {
+	tlsConf := plumbing.NewTLSConfig()
+	tlsConf.InsecureSkipVerify = skipCert
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     tlsConf,
+		},
+	}
+}
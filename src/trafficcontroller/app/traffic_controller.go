@@ -3,12 +3,19 @@ package app
 import (
 	"errors"
 	"fmt"
+	"health"
+	"io"
 	"log"
+	"log/syslog"
+	"metric"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"profiler"
+	"prometheus"
 	"strconv"
+	"syscall"
 	"time"
 
 	"dopplerservice"
@@ -53,10 +60,16 @@ func (t *trafficController) Start() {
 
 	log.Print("Startup: Setting up the loggregator traffic controller")
 
-	batcher, err := t.initializeMetrics("LoggregatorTrafficController", net.JoinHostPort(t.conf.MetronHost, strconv.Itoa(t.conf.MetronPort)))
+	var promRegistry *prometheus.Registry
+	if t.conf.PrometheusPort != 0 {
+		promRegistry = prometheus.NewRegistry("traffic_controller")
+	}
+
+	batcher, err := t.initializeMetrics("LoggregatorTrafficController", net.JoinHostPort(t.conf.MetronHost, strconv.Itoa(t.conf.MetronPort)), promRegistry)
 	if err != nil {
 		log.Printf("Error initializing dropsonde: %s", err)
 	}
+	t.setupMetricsEmitter(promRegistry)
 
 	monitorInterval := time.Duration(t.conf.MonitorIntervalSeconds) * time.Second
 	uptimeMonitor := monitor.NewUptime(monitorInterval)
@@ -73,25 +86,50 @@ func (t *trafficController) Start() {
 		panic(fmt.Errorf("Unable to connect to ETCD: %s", err))
 	}
 
-	logAuthorizer := auth.NewLogAccessAuthorizer(t.disableAccessControl, t.conf.ApiHost)
+	logAuthorizer := auth.NewLogAccessAuthorizer(
+		t.disableAccessControl,
+		t.conf.ApiHost,
+		time.Duration(t.conf.CCRequestTimeoutSeconds)*time.Second,
+	)
+	if t.conf.LogAccessCacheTTLSeconds > 0 && !t.disableAccessControl {
+		logAuthorizer = auth.NewCachingLogAccessAuthorizer(
+			logAuthorizer,
+			time.Duration(t.conf.LogAccessCacheTTLSeconds)*time.Second,
+			time.Duration(t.conf.LogAccessCacheNegativeTTLSeconds)*time.Second,
+			t.conf.LogAccessCacheMaxEntries,
+		)
+	}
 
 	uaaClient := auth.NewUaaClient(t.conf.UaaHost, t.conf.UaaClient, t.conf.UaaClientSecret)
-	adminAuthorizer := auth.NewAdminAccessAuthorizer(t.disableAccessControl, &uaaClient)
+	var adminUaaClient auth.UaaClient = &uaaClient
+	if t.conf.UaaLocalTokenValidation && !t.disableAccessControl {
+		tokenKeys := auth.NewUaaTokenKeys(
+			t.conf.UaaHost,
+			time.Duration(t.conf.UaaTokenKeysRefreshIntervalSeconds)*time.Second,
+			newUaaHTTPClient(t.conf.SkipCertVerify),
+		)
+		go tokenKeys.Start()
+
+		adminUaaClient = auth.NewLocalUaaClient(tokenKeys, &uaaClient)
+		logAuthorizer = auth.NewVerifyingLogAccessAuthorizer(tokenKeys, logAuthorizer)
+	}
+	adminAuthorizer := auth.NewAdminAccessAuthorizer(t.disableAccessControl, adminUaaClient)
 
 	finder := dopplerservice.NewFinder(etcdAdapter, int(t.conf.DopplerPort), int(t.conf.GRPC.Port), []string{"ws"}, "")
 	finder.Start()
 
 	var accessMiddleware func(auth.HttpHandler) *auth.AccessHandler
-	if t.conf.SecurityEventLog != "" {
-		accessLog, err := os.OpenFile(t.conf.SecurityEventLog, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	if t.conf.SecurityEventLog != "" || t.conf.SecurityEventLogSyslog {
+		accessLog, err := t.openAccessLog()
 		if err != nil {
 			panic(fmt.Errorf("Unable to open access log: %s", err))
 		}
-		defer func() {
-			accessLog.Sync()
-			accessLog.Close()
-		}()
-		accessLogger := auth.NewAccessLogger(accessLog)
+		defer accessLog.Close()
+
+		var accessLogger auth.AccessLogger = auth.NewAccessLogger(accessLog)
+		if t.conf.SecurityEventLogFormat == "json" {
+			accessLogger = auth.NewJSONAccessLogger(accessLog)
+		}
 		accessMiddleware = auth.Access(accessLogger, t.conf.IP, t.conf.OutgoingDropsondePort)
 	}
 
@@ -108,15 +146,42 @@ func (t *trafficController) Start() {
 	pool := plumbing.NewPool(20, grpc.WithTransportCredentials(creds))
 	grpcConnector := plumbing.NewGRPCConnector(1000, pool, finder, batcher)
 
-	dopplerHandler := http.Handler(proxy.NewDopplerProxy(logAuthorizer, adminAuthorizer, grpcConnector, "doppler."+t.conf.SystemDomain, 15*time.Second))
+	limits := proxy.ClientLimits{
+		MaxStreams:        t.conf.MaxStreamsPerClient,
+		MaxFirehoses:      t.conf.MaxFirehosesPerClient,
+		MaxBytesPerSecond: t.conf.MaxBytesPerSecondPerClient,
+	}
+	dopplerProxy := proxy.NewDopplerProxy(logAuthorizer, adminAuthorizer, grpcConnector, "doppler."+t.conf.SystemDomain, 15*time.Second, limits)
+	dopplerHandler := http.Handler(dopplerProxy)
 	if accessMiddleware != nil {
 		dopplerHandler = accessMiddleware(dopplerHandler)
 	}
-	t.startOutgoingProxy(fmt.Sprintf(":%d", t.conf.OutgoingDropsondePort), dopplerHandler)
+	stopOutgoingProxy := t.startOutgoingProxy(fmt.Sprintf(":%d", t.conf.OutgoingDropsondePort), dopplerHandler)
 
 	killChan := signalmanager.RegisterKillSignalChannel()
 	dumpChan := signalmanager.RegisterGoRoutineDumpSignalChannel()
 
+	// monit stops the traffic controller with SIGTERM. Open streams are
+	// drained on it so that clients can reconnect elsewhere.
+	termChan := make(chan os.Signal, 1)
+	signal.Notify(termChan, syscall.SIGTERM)
+
+	if t.conf.HealthPort != 0 {
+		registry := health.NewRegistry()
+		registry.Register("proxy", dopplerProxy.Ready)
+		registry.Register("dopplers", func() error {
+			if pool.Connected() == 0 {
+				return errors.New("not connected to any dopplers")
+			}
+			return nil
+		})
+		go health.NewServer(t.conf.HealthPort, registry).Start()
+	}
+
+	if promRegistry != nil {
+		go prometheus.NewServer(t.conf.PrometheusPort, promRegistry).Start()
+	}
+
 	// We start the profiler last so that we can definitively claim that we're ready for
 	// connections by the time we're listening on the PPROFPort.
 	p := profiler.New(t.conf.PPROFPort)
@@ -128,11 +193,43 @@ func (t *trafficController) Start() {
 			signalmanager.DumpGoRoutine()
 		case <-killChan:
 			log.Print("Shutting down")
+			metric.Flush()
+			return
+		case <-termChan:
+			log.Print("Draining connections and shutting down")
+			stopOutgoingProxy()
+
+			drainTimeout := time.Duration(t.conf.DrainTimeoutSeconds) * time.Second
+			if !dopplerProxy.Drain(drainTimeout) {
+				log.Printf("Connections did not drain within %s", drainTimeout)
+			}
+			metric.Flush()
 			return
 		}
 	}
 }
 
+func (t *trafficController) setupMetricsEmitter(promRegistry *prometheus.Registry) {
+	creds, err := plumbing.NewCredentials(
+		t.conf.GRPC.CertFile,
+		t.conf.GRPC.KeyFile,
+		t.conf.GRPC.CAFile,
+		"metron",
+	)
+	if err != nil {
+		log.Fatalf("Could not use GRPC creds for metrics: %s", err)
+	}
+
+	// metric-documentation-v2: setup function
+	metric.Setup(
+		metric.WithGrpcDialOpts(grpc.WithTransportCredentials(creds)),
+		metric.WithOrigin("loggregator.trafficcontroller"),
+		metric.WithAddr(net.JoinHostPort(t.conf.MetronHost, strconv.Itoa(t.conf.MetronGRPCPort))),
+		metric.WithDeploymentMeta(t.conf.DeploymentName, t.conf.JobName, t.conf.Index),
+		metric.WithPrometheus(promRegistry),
+	)
+}
+
 func (t *trafficController) setupDefaultEmitter(origin, destination string) error {
 	if origin == "" {
 		return errors.New("Cannot initialize metrics with an empty origin")
@@ -151,7 +248,7 @@ func (t *trafficController) setupDefaultEmitter(origin, destination string) erro
 	return nil
 }
 
-func (t *trafficController) initializeMetrics(origin, destination string) (*metricbatcher.MetricBatcher, error) {
+func (t *trafficController) initializeMetrics(origin, destination string, promRegistry *prometheus.Registry) (*metricbatcher.MetricBatcher, error) {
 	err := t.setupDefaultEmitter(origin, destination)
 	if err != nil {
 		// Legacy holdover.  We would prefer to panic, rather than just throwing our metrics
@@ -162,12 +259,13 @@ func (t *trafficController) initializeMetrics(origin, destination string) (*metr
 
 	// Copied from dropsonde.initialize(), since we stopped using dropsonde.Initialize
 	// but needed it to continue operating the same.
-	sender := metric_sender.NewMetricSender(dropsonde.DefaultEmitter)
+	metricEmitter := prometheus.NewEmitter(promRegistry, dropsonde.DefaultEmitter)
+	sender := metric_sender.NewMetricSender(metricEmitter)
 	batcher := metricbatcher.New(sender, time.Second)
 	metrics.Initialize(sender, batcher)
 	logs.Initialize(log_sender.NewLogSender(dropsonde.DefaultEmitter))
 	envelopes.Initialize(envelope_sender.NewEnvelopeSender(dropsonde.DefaultEmitter))
-	go runtime_stats.NewRuntimeStats(dropsonde.DefaultEmitter, 10*time.Second).Run(nil)
+	go runtime_stats.NewRuntimeStats(metricEmitter, 10*time.Second).Run(nil)
 	http.DefaultTransport = dropsonde.InstrumentedRoundTripper(http.DefaultTransport)
 	return batcher, err
 }
@@ -193,11 +291,57 @@ func (t *trafficController) defaultStoreAdapterProvider(conf *Config) storeadapt
 	return etcdStoreAdapter
 }
 
-func (t *trafficController) startOutgoingProxy(host string, h http.Handler) {
+func (t *trafficController) openAccessLog() (io.WriteCloser, error) {
+	if t.conf.SecurityEventLogSyslog {
+		return syslog.New(syslog.LOG_INFO|syslog.LOG_USER, t.conf.JobName)
+	}
+
+	if t.conf.SecurityEventLogMaxSizeMB > 0 {
+		return auth.NewRotatingFile(
+			t.conf.SecurityEventLog,
+			int64(t.conf.SecurityEventLogMaxSizeMB)*1024*1024,
+			t.conf.SecurityEventLogMaxBackups,
+		)
+	}
+
+	f, err := os.OpenFile(t.conf.SecurityEventLog, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	if err != nil {
+		return nil, err
+	}
+	return syncingCloser{f}, nil
+}
+
+type syncingCloser struct {
+	*os.File
+}
+
+func (s syncingCloser) Close() error {
+	s.Sync()
+	return s.File.Close()
+}
+
+// startOutgoingProxy serves h on host. The returned function stops
+// accepting new connections and disables keep-alives on open ones.
+func (t *trafficController) startOutgoingProxy(host string, h http.Handler) func() {
+	lis, err := net.Listen("tcp", host)
+	if err != nil {
+		panic(err)
+	}
+
+	server := &http.Server{Handler: h}
+	stopped := make(chan struct{})
 	go func() {
-		err := http.ListenAndServe(host, h)
-		if err != nil {
+		err := server.Serve(lis)
+		select {
+		case <-stopped:
+		default:
 			panic(err)
 		}
 	}()
+
+	return func() {
+		close(stopped)
+		server.SetKeepAlivesEnabled(false)
+		lis.Close()
+	}
 }
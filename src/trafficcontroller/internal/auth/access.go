@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"bufio"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 //go:generate hel --type HttpHandler --output mock_http_writer_test.go
@@ -17,6 +22,21 @@ type AccessLogger interface {
 	LogAccess(req *http.Request, host string, port uint32) error
 }
 
+//go:generate hel --type ResponseLogger --output mock_response_logger_test.go
+
+// ResponseLogger is implemented by AccessLoggers that log requests once the
+// response has completed.
+type ResponseLogger interface {
+	LogResponse(req *http.Request, host string, port uint32, stats ResponseStats) error
+}
+
+type ResponseStats struct {
+	Start      time.Time
+	Duration   time.Duration
+	StatusCode int
+	BytesSent  int64
+}
+
 type AccessHandler struct {
 	handler      HttpHandler
 	accessLogger AccessLogger
@@ -40,5 +60,84 @@ func (h *AccessHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		log.Printf("access handler : %s", err)
 	}
 
-	h.handler.ServeHTTP(rw, req)
+	responseLogger, ok := h.accessLogger.(ResponseLogger)
+	if !ok {
+		h.handler.ServeHTTP(rw, req)
+		return
+	}
+
+	req = withClientIDRecorder(req)
+	start := time.Now()
+	recorder := &responseRecorder{ResponseWriter: rw}
+	h.handler.ServeHTTP(recorder, req)
+
+	stats := ResponseStats{
+		Start:      start,
+		Duration:   time.Since(start),
+		StatusCode: recorder.status(),
+		BytesSent:  atomic.LoadInt64(&recorder.bytesSent),
+	}
+	if err := responseLogger.LogResponse(req, h.host, h.port, stats); err != nil {
+		log.Printf("access handler : %s", err)
+	}
+}
+
+// responseRecorder records the status code and number of bytes written to a
+// response, including bytes written to a hijacked connection.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int32
+	bytesSent  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	atomic.CompareAndSwapInt32(&r.statusCode, 0, int32(code))
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	atomic.CompareAndSwapInt32(&r.statusCode, 0, http.StatusOK)
+	n, err := r.ResponseWriter.Write(b)
+	atomic.AddInt64(&r.bytesSent, int64(n))
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	atomic.CompareAndSwapInt32(&r.statusCode, 0, http.StatusSwitchingProtocols)
+
+	return &countingConn{Conn: conn, bytesSent: &r.bytesSent}, rw, nil
+}
+
+func (r *responseRecorder) status() int {
+	code := int(atomic.LoadInt32(&r.statusCode))
+	if code == 0 {
+		return http.StatusOK
+	}
+	return code
+}
+
+type countingConn struct {
+	net.Conn
+	bytesSent *int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.bytesSent, int64(n))
+	return n, err
 }
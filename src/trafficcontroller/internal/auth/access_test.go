@@ -1,6 +1,7 @@
 package auth_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"trafficcontroller/internal/auth"
@@ -44,5 +45,104 @@ var _ = Describe("AccessHandler", func() {
 			Expect(mockAccessLogger.LogAccessInput.Host).To(BeCalled(With(host)))
 			Expect(mockAccessLogger.LogAccessInput.Port).To(BeCalled(With(uint32(port))))
 		})
+
+		Context("with a response logger", func() {
+			var mockResponseLogger *mockResponseLogger
+
+			BeforeEach(func() {
+				mockResponseLogger = newMockResponseLogger()
+				logger := mockAccessAndResponseLogger{mockAccessLogger, mockResponseLogger}
+
+				accessHandler = auth.Access(logger, host, port)(http.HandlerFunc(
+					func(rw http.ResponseWriter, req *http.Request) {
+						rw.WriteHeader(http.StatusTeapot)
+						rw.Write([]byte("some-body"))
+					},
+				))
+			})
+
+			It("logs the response status and bytes sent", func() {
+				mockAccessLogger.LogAccessOutput.Ret0 <- nil
+				mockResponseLogger.LogResponseOutput.Ret0 <- nil
+
+				req, err := newServerRequest("GET", "https://foo.bar/baz", nil)
+				Expect(err).ToNot(HaveOccurred())
+				resp := httptest.NewRecorder()
+
+				accessHandler.ServeHTTP(resp, req)
+
+				Expect(resp.Code).To(Equal(http.StatusTeapot))
+				var loggedReq *http.Request
+				Expect(mockResponseLogger.LogResponseInput.Req).To(Receive(&loggedReq))
+				Expect(loggedReq.URL).To(Equal(req.URL))
+				Expect(mockResponseLogger.LogResponseInput.Host).To(BeCalled(With(host)))
+				Expect(mockResponseLogger.LogResponseInput.Port).To(BeCalled(With(uint32(port))))
+
+				var stats auth.ResponseStats
+				Expect(mockResponseLogger.LogResponseInput.Stats).To(Receive(&stats))
+				Expect(stats.StatusCode).To(Equal(http.StatusTeapot))
+				Expect(stats.BytesSent).To(BeEquivalentTo(len("some-body")))
+				Expect(stats.Start).ToNot(BeZero())
+			})
+		})
+
+		Context("with a JSON access logger", func() {
+			var (
+				writer   *mockWriter
+				clientID string
+			)
+
+			BeforeEach(func() {
+				writer = newMockWriter()
+				writer.WriteOutput.Err <- nil
+				writer.WriteOutput.Sent <- 0
+				clientID = ""
+
+				accessHandler = auth.Access(auth.NewJSONAccessLogger(writer), host, port)(http.HandlerFunc(
+					func(rw http.ResponseWriter, req *http.Request) {
+						if clientID != "" {
+							auth.SetVerifiedClientID(req, clientID)
+						}
+						rw.WriteHeader(http.StatusOK)
+					},
+				))
+			})
+
+			It("logs the client ID once it has been verified", func() {
+				clientID = "some-client"
+				req, err := newServerRequest("GET", "https://foo.bar/baz", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Authorization", "bearer iAmAnAdmin")
+
+				accessHandler.ServeHTTP(httptest.NewRecorder(), req)
+
+				var log map[string]interface{}
+				var msg []byte
+				Expect(writer.WriteInput.Message).To(Receive(&msg))
+				Expect(json.Unmarshal(msg, &log)).To(Succeed())
+				Expect(log).To(HaveKeyWithValue("client_id", "some-client"))
+				Expect(log).ToNot(HaveKey("unverified_client_id"))
+			})
+
+			It("marks the client ID as unverified otherwise", func() {
+				req, err := newServerRequest("GET", "https://foo.bar/baz", nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Authorization", "bearer iAmAnAdmin")
+
+				accessHandler.ServeHTTP(httptest.NewRecorder(), req)
+
+				var log map[string]interface{}
+				var msg []byte
+				Expect(writer.WriteInput.Message).To(Receive(&msg))
+				Expect(json.Unmarshal(msg, &log)).To(Succeed())
+				Expect(log).To(HaveKeyWithValue("client_id", ""))
+				Expect(log).To(HaveKeyWithValue("unverified_client_id", auth.ClientID("bearer iAmAnAdmin")))
+			})
+		})
 	})
 })
+
+type mockAccessAndResponseLogger struct {
+	*mockAccessLogger
+	*mockResponseLogger
+}
@@ -2,11 +2,13 @@ package auth
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -45,10 +47,7 @@ func NewAccessLog(req *http.Request, ts time.Time, host string, port uint32) *Ac
 
 func (al *AccessLog) String() string {
 	vcapRequestId := al.request.Header.Get(REQUEST_ID_HEADER)
-	path := al.request.URL.Path
-	if al.request.URL.RawQuery != "" {
-		path = fmt.Sprintf("%s?%s", al.request.URL.Path, al.request.URL.RawQuery)
-	}
+	path := al.path()
 	remoteHost, remotePort := al.extractRemoteInfo()
 
 	context := templateContext{
@@ -70,6 +69,75 @@ func (al *AccessLog) String() string {
 	return buf.String()
 }
 
+type jsonAccessLog struct {
+	Timestamp       int64   `json:"timestamp"`
+	RequestID       string  `json:"request_id"`
+	Method          string  `json:"method"`
+	Path            string  `json:"path"`
+	SourceHost      string  `json:"source_host"`
+	SourcePort      string  `json:"source_port"`
+	DestinationHost string  `json:"destination_host"`
+	DestinationPort string  `json:"destination_port"`
+	ClientID        string  `json:"client_id"`
+	UnverifiedID    string  `json:"unverified_client_id,omitempty"`
+	StatusCode      int     `json:"status_code"`
+	DurationMillis  float64 `json:"duration_ms"`
+	BytesSent       int64   `json:"bytes_sent"`
+}
+
+// JSON renders the access log along with the response stats as a JSON
+// object.
+func (al *AccessLog) JSON(stats ResponseStats) ([]byte, error) {
+	remoteHost, remotePort := al.extractRemoteInfo()
+
+	// Only client IDs of authorized tokens are trusted. The client ID
+	// claimed by any other token is logged separately as it may be forged.
+	clientID, verified := verifiedClientID(al.request)
+	var unverifiedID string
+	if !verified {
+		unverifiedID = ClientID(al.authToken())
+	}
+
+	return json.Marshal(jsonAccessLog{
+		Timestamp:       toMillis(al.timestamp),
+		RequestID:       al.request.Header.Get(REQUEST_ID_HEADER),
+		Method:          al.request.Method,
+		Path:            al.path(),
+		SourceHost:      remoteHost,
+		SourcePort:      remotePort,
+		DestinationHost: al.host,
+		DestinationPort: strconv.Itoa(int(al.port)),
+		ClientID:        clientID,
+		UnverifiedID:    unverifiedID,
+		StatusCode:      stats.StatusCode,
+		DurationMillis:  float64(stats.Duration) / float64(time.Millisecond),
+		BytesSent:       stats.BytesSent,
+	})
+}
+
+func (al *AccessLog) path() string {
+	if al.request.URL.RawQuery != "" {
+		return fmt.Sprintf("%s?%s", al.request.URL.Path, al.request.URL.RawQuery)
+	}
+	return al.request.URL.Path
+}
+
+func (al *AccessLog) authToken() string {
+	if token := al.request.Header.Get("Authorization"); token != "" {
+		return token
+	}
+
+	cookie, err := al.request.Cookie("authorization")
+	if err != nil {
+		return ""
+	}
+	token, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
 func (al *AccessLog) extractRemoteInfo() (string, string) {
 	remoteAddr := al.request.Header.Get("X-Forwarded-For")
 	index := strings.Index(remoteAddr, ",")
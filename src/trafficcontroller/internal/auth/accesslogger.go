@@ -27,3 +27,29 @@ func (a *DefaultAccessLogger) LogAccess(req *http.Request, host string, port uin
 	_, err := a.writer.Write([]byte(al.String() + "\n"))
 	return err
 }
+
+type JSONAccessLogger struct {
+	writer io.Writer
+}
+
+// NewJSONAccessLogger returns an AccessLogger that writes a JSON object per
+// request once the response has completed.
+func NewJSONAccessLogger(writer io.Writer) *JSONAccessLogger {
+	return &JSONAccessLogger{
+		writer: writer,
+	}
+}
+
+func (a *JSONAccessLogger) LogAccess(req *http.Request, host string, port uint32) error {
+	return nil
+}
+
+func (a *JSONAccessLogger) LogResponse(req *http.Request, host string, port uint32, stats ResponseStats) error {
+	al := NewAccessLog(req, stats.Start, host, port)
+	data, err := al.JSON(stats)
+	if err != nil {
+		return err
+	}
+	_, err = a.writer.Write(append(data, '\n'))
+	return err
+}
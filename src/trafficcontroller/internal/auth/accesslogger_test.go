@@ -2,6 +2,8 @@ package auth_test
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"trafficcontroller/internal/auth"
 
@@ -97,3 +99,61 @@ var _ = Describe("DefaultAccessLogger", func() {
 		})
 	})
 })
+
+var _ = Describe("JSONAccessLogger", func() {
+	var (
+		writer *mockWriter
+		logger *auth.JSONAccessLogger
+	)
+
+	BeforeEach(func() {
+		writer = newMockWriter()
+		logger = auth.NewJSONAccessLogger(writer)
+	})
+
+	It("does not log when the request starts", func() {
+		req, err := newServerRequest("GET", "http://some.url.com/foo", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(logger.LogAccess(req, "1.1.1.1", 1)).To(Succeed())
+		Expect(writer.WriteCalled).ToNot(Receive())
+	})
+
+	It("logs the request and response as JSON", func() {
+		writer.WriteOutput.Err <- nil
+		writer.WriteOutput.Sent <- 0
+
+		req, err := newServerRequest("GET", "http://some.url.com/foo?bar=baz", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.RemoteAddr = "127.0.0.1:4567"
+		req.Header.Set("X-Vcap-Request-ID", "some-request-id")
+		req.Header.Set("Authorization", "bearer iAmAnAdmin")
+		start := time.Unix(1, 0)
+
+		Expect(logger.LogResponse(req, "1.1.1.1", 1, auth.ResponseStats{
+			Start:      start,
+			Duration:   1500 * time.Microsecond,
+			StatusCode: 200,
+			BytesSent:  1234,
+		})).To(Succeed())
+
+		var log []byte
+		Expect(writer.WriteInput.Message).To(Receive(&log))
+		Expect(log).To(HaveSuffix("\n"))
+		Expect(log).To(MatchJSON(fmt.Sprintf(`{
+			"timestamp": 1000,
+			"request_id": "some-request-id",
+			"method": "GET",
+			"path": "/foo?bar=baz",
+			"source_host": "127.0.0.1",
+			"source_port": "4567",
+			"destination_host": "1.1.1.1",
+			"destination_port": "1",
+			"client_id": "",
+			"unverified_client_id": %q,
+			"status_code": 200,
+			"duration_ms": 1.5,
+			"bytes_sent": 1234
+		}`, auth.ClientID("bearer iAmAnAdmin"))))
+	})
+})
@@ -0,0 +1,40 @@
+package auth_test
+
+import (
+	"trafficcontroller/internal/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClientID", func() {
+	It("returns the subject of a JWT", func() {
+		token := signToken("key-1", newTokenKey(), map[string]interface{}{
+			"sub":       "some-user-id",
+			"client_id": "cf",
+		})
+
+		Expect(auth.ClientID("bearer " + token)).To(Equal("some-user-id"))
+	})
+
+	It("returns the client ID of a JWT without a subject", func() {
+		token := signToken("key-1", newTokenKey(), map[string]interface{}{
+			"client_id": "some-client",
+		})
+
+		Expect(auth.ClientID("bearer " + token)).To(Equal("some-client"))
+	})
+
+	It("returns a stable identifier for opaque tokens", func() {
+		id := auth.ClientID("bearer iAmAnAdmin")
+
+		Expect(id).To(HavePrefix("token-"))
+		Expect(id).ToNot(ContainSubstring("iAmAnAdmin"))
+		Expect(auth.ClientID("bearer iAmAnAdmin")).To(Equal(id))
+		Expect(auth.ClientID("bearer iAmNotAnAdmin")).ToNot(Equal(id))
+	})
+
+	It("returns an empty ID for an empty token", func() {
+		Expect(auth.ClientID("")).To(BeEmpty())
+	})
+})
@@ -1,7 +1,15 @@
 package auth_test
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
@@ -65,3 +73,43 @@ func buildExpectedLog(timestamp time.Time, requestId, method, path, sourceHost,
 	}
 	return "CEF:" + strings.Join(fields, "|")
 }
+
+func newTokenKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return key
+}
+
+func tokenKeysJSON(kid string, key *rsa.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	keys := map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kid":   kid,
+				"kty":   "RSA",
+				"alg":   "RS256",
+				"use":   "sig",
+				"value": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			},
+		},
+	}
+	data, err := json.Marshal(keys)
+	Expect(err).ToNot(HaveOccurred())
+	return data
+}
+
+func signToken(kid string, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	Expect(err).ToNot(HaveOccurred())
+	payload, err := json.Marshal(claims)
+	Expect(err).ToNot(HaveOccurred())
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	Expect(err).ToNot(HaveOccurred())
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenExpired          = errors.New("Token has expired")
+	ErrInvalidTokenSignature = errors.New("Token signature is invalid")
+
+	// errUnverifiable is returned when a token can not be verified locally,
+	// e.g. it is not a JWT or is signed by a key that is not known.
+	errUnverifiable = errors.New("token can not be verified locally")
+)
+
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+type TokenKeys interface {
+	Key(kid string) (*rsa.PublicKey, error)
+}
+
+type localUaaClient struct {
+	keys     TokenKeys
+	fallback UaaClient
+}
+
+// NewLocalUaaClient returns a UaaClient that verifies JWTs against the UAA
+// signing keys without a round trip to UAA. Tokens that can not be verified
+// locally are checked with the fallback client.
+func NewLocalUaaClient(keys TokenKeys, fallback UaaClient) UaaClient {
+	return &localUaaClient{
+		keys:     keys,
+		fallback: fallback,
+	}
+}
+
+func (c *localUaaClient) GetAuthData(token string) (*AuthData, error) {
+	authData, err := VerifyToken(c.keys, token)
+	if err == errUnverifiable {
+		return c.fallback.GetAuthData(token)
+	}
+	return authData, err
+}
+
+// VerifyToken checks the signature and expiry of the given JWT and returns
+// the auth data from its claims.
+func VerifyToken(keys TokenKeys, token string) (*AuthData, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errUnverifiable
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errUnverifiable
+	}
+
+	hash, ok := signingHashes[header.Alg]
+	if !ok {
+		return nil, errUnverifiable
+	}
+
+	key, err := keys.Key(header.Kid)
+	if err != nil {
+		log.Printf("Unable to verify token locally: %s", err)
+		return nil, errUnverifiable
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return nil, ErrInvalidTokenSignature
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature); err != nil {
+		return nil, ErrInvalidTokenSignature
+	}
+
+	var claims struct {
+		AuthData
+		Expiry int64 `json:"exp"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() >= claims.Expiry {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims.AuthData, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
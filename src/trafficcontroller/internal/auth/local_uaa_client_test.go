@@ -0,0 +1,144 @@
+package auth_test
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"time"
+
+	"trafficcontroller/internal/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LocalUaaClient", func() {
+	var (
+		privateKey *rsa.PrivateKey
+		keys       *stubTokenKeys
+		fallback   *LoggregatorAdminUaaClient
+		client     auth.UaaClient
+	)
+
+	BeforeEach(func() {
+		privateKey = newTokenKey()
+		keys = &stubTokenKeys{
+			keys: map[string]*rsa.PublicKey{"key-1": &privateKey.PublicKey},
+		}
+		fallback = &LoggregatorAdminUaaClient{}
+		client = auth.NewLocalUaaClient(keys, fallback)
+	})
+
+	It("returns the scopes of a valid token without using the fallback", func() {
+		token := signToken("key-1", privateKey, map[string]interface{}{
+			"scope": []string{"doppler.firehose"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+
+		authData, err := client.GetAuthData(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authData.HasPermission("doppler.firehose")).To(BeTrue())
+		Expect(fallback.UsedToken).To(BeEmpty())
+	})
+
+	It("rejects expired tokens", func() {
+		token := signToken("key-1", privateKey, map[string]interface{}{
+			"scope": []string{"doppler.firehose"},
+			"exp":   time.Now().Add(-time.Minute).Unix(),
+		})
+
+		_, err := client.GetAuthData(token)
+		Expect(err).To(Equal(auth.ErrTokenExpired))
+		Expect(fallback.UsedToken).To(BeEmpty())
+	})
+
+	It("rejects tokens with an invalid signature", func() {
+		token := signToken("key-1", newTokenKey(), map[string]interface{}{
+			"scope": []string{"doppler.firehose"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+
+		_, err := client.GetAuthData(token)
+		Expect(err).To(Equal(auth.ErrInvalidTokenSignature))
+		Expect(fallback.UsedToken).To(BeEmpty())
+	})
+
+	It("falls back for tokens signed with an unknown key", func() {
+		token := signToken("key-2", newTokenKey(), map[string]interface{}{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+
+		authData, err := client.GetAuthData(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authData.HasPermission("doppler.firehose")).To(BeTrue())
+		Expect(fallback.UsedToken).To(Equal(token))
+	})
+
+	It("falls back for tokens that are not JWTs", func() {
+		_, err := client.GetAuthData("iAmAnAdmin")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallback.UsedToken).To(Equal("iAmAnAdmin"))
+	})
+})
+
+var _ = Describe("VerifyingLogAccessAuthorizer", func() {
+	var (
+		privateKey *rsa.PrivateKey
+		keys       *stubTokenKeys
+		called     bool
+		authorizer auth.LogAccessAuthorizer
+	)
+
+	BeforeEach(func() {
+		privateKey = newTokenKey()
+		keys = &stubTokenKeys{
+			keys: map[string]*rsa.PublicKey{"key-1": &privateKey.PublicKey},
+		}
+		called = false
+		authorizer = auth.NewVerifyingLogAccessAuthorizer(keys, func(string, string) (int, error) {
+			called = true
+			return http.StatusOK, nil
+		})
+	})
+
+	It("checks valid tokens with the wrapped authorizer", func() {
+		token := signToken("key-1", privateKey, map[string]interface{}{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+
+		status, err := authorizer("bearer "+token, "myAppId")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(called).To(BeTrue())
+	})
+
+	It("rejects expired tokens without the wrapped authorizer", func() {
+		token := signToken("key-1", privateKey, map[string]interface{}{
+			"exp": time.Now().Add(-time.Minute).Unix(),
+		})
+
+		status, err := authorizer("bearer "+token, "myAppId")
+		Expect(err).To(MatchError(auth.INVALID_AUTH_TOKEN_ERROR_MESSAGE))
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		Expect(called).To(BeFalse())
+	})
+
+	It("checks tokens that can not be verified with the wrapped authorizer", func() {
+		status, err := authorizer("bearer something", "myAppId")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(called).To(BeTrue())
+	})
+})
+
+type stubTokenKeys struct {
+	keys map[string]*rsa.PublicKey
+}
+
+func (s *stubTokenKeys) Key(kid string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown key")
+	}
+	return key, nil
+}
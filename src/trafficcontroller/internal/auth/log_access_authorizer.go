@@ -1,9 +1,12 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const (
@@ -18,7 +21,7 @@ func disableLogAccessControlAuthorizer(_, _ string) (int, error) {
 	return http.StatusOK, nil
 }
 
-func NewLogAccessAuthorizer(disableAccessControl bool, apiHost string) LogAccessAuthorizer {
+func NewLogAccessAuthorizer(disableAccessControl bool, apiHost string, timeout time.Duration) LogAccessAuthorizer {
 
 	if disableAccessControl {
 		return LogAccessAuthorizer(disableLogAccessControlAuthorizer)
@@ -30,7 +33,11 @@ func NewLogAccessAuthorizer(disableAccessControl bool, apiHost string) LogAccess
 			return http.StatusUnauthorized, errors.New(NO_AUTH_TOKEN_PROVIDED_ERROR_MESSAGE)
 		}
 
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
 		req, _ := http.NewRequest("GET", apiHost+"/internal/log_access/"+target, nil)
+		req = req.WithContext(ctx)
 		req.Header.Set("Authorization", authToken)
 		res, err := http.DefaultClient.Do(req)
 		if err != nil {
@@ -51,3 +58,23 @@ func NewLogAccessAuthorizer(disableAccessControl bool, apiHost string) LogAccess
 
 	return LogAccessAuthorizer(isAccessAllowed)
 }
+
+// NewVerifyingLogAccessAuthorizer rejects tokens that fail local
+// verification without a round trip to the Cloud Controller. Tokens that
+// pass, or can not be verified locally, are checked with the given
+// authorizer.
+func NewVerifyingLogAccessAuthorizer(keys TokenKeys, authorize LogAccessAuthorizer) LogAccessAuthorizer {
+	return func(authToken string, target string) (int, error) {
+		if authToken == "" {
+			return authorize(authToken, target)
+		}
+
+		_, err := VerifyToken(keys, strings.TrimPrefix(authToken, BEARER_PREFIX))
+		if err != nil && err != errUnverifiable {
+			log.Printf("Rejecting token for %s: %s", target, err)
+			return http.StatusUnauthorized, errors.New(INVALID_AUTH_TOKEN_ERROR_MESSAGE)
+		}
+
+		return authorize(authToken, target)
+	}
+}
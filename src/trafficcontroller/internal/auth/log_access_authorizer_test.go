@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"time"
 
 	"trafficcontroller/internal/auth"
 
@@ -32,7 +33,7 @@ var _ = Describe("LogAccessAuthorizer", func() {
 
 	Context("Disable Access Control", func() {
 		It("returns http.StatusOK", func() {
-			authorizer := auth.NewLogAccessAuthorizer(true, "http://cloudcontroller.example.com")
+			authorizer := auth.NewLogAccessAuthorizer(true, "http://cloudcontroller.example.com", time.Second)
 			Expect(authorizer("bearer anything", "myAppId")).To(Equal(http.StatusOK))
 		})
 	})
@@ -48,7 +49,7 @@ var _ = Describe("LogAccessAuthorizer", func() {
 		})
 
 		It("does not allow access for requests with empty AuthTokens", func() {
-			authorizer := auth.NewLogAccessAuthorizer(false, server.URL)
+			authorizer := auth.NewLogAccessAuthorizer(false, server.URL, time.Second)
 
 			status, err := authorizer("", "myAppId")
 			Expect(status).To(Equal(http.StatusUnauthorized))
@@ -56,7 +57,7 @@ var _ = Describe("LogAccessAuthorizer", func() {
 		})
 
 		It("allows access when the api returns 200, and otherwise denies access", func() {
-			authorizer := auth.NewLogAccessAuthorizer(false, server.URL)
+			authorizer := auth.NewLogAccessAuthorizer(false, server.URL, time.Second)
 
 			status, err := authorizer("bearer something", "myAppId")
 			Expect(status).To(Equal(http.StatusOK))
@@ -72,6 +73,24 @@ var _ = Describe("LogAccessAuthorizer", func() {
 		})
 	})
 
+	Context("Server is slow to respond", func() {
+		BeforeEach(func() {
+			server = startHTTPServer()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("does not allow access when the request times out", func() {
+			authorizer := auth.NewLogAccessAuthorizer(false, server.URL, 10*time.Millisecond)
+
+			status, err := authorizer("bearer something", "slowAppId")
+			Expect(status).To(Equal(http.StatusInternalServerError))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Context("Server uses SSL without valid certificate", func() {
 		BeforeEach(func() {
 			server = startHTTPSServer()
@@ -82,7 +101,7 @@ var _ = Describe("LogAccessAuthorizer", func() {
 		})
 
 		It("does allow access when cert verification is skipped", func() {
-			authorizer := auth.NewLogAccessAuthorizer(false, server.URL)
+			authorizer := auth.NewLogAccessAuthorizer(false, server.URL, time.Second)
 			status, err := authorizer("bearer something", "myAppId")
 			Expect(status).To(Equal(http.StatusOK))
 			Expect(err).To(BeNil())
@@ -90,7 +109,7 @@ var _ = Describe("LogAccessAuthorizer", func() {
 
 		It("does not allow access when cert verifcation is not skipped", func() {
 			transport.TLSClientConfig.InsecureSkipVerify = false
-			authorizer := auth.NewLogAccessAuthorizer(false, server.URL)
+			authorizer := auth.NewLogAccessAuthorizer(false, server.URL, time.Second)
 			status, err := authorizer("bearer something", "myAppId")
 			Expect(status).To(Equal(http.StatusInternalServerError))
 			Expect(err).To(BeAssignableToTypeOf(&url.Error{}))
@@ -116,6 +135,9 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("{}"))
 	case "notMyAppId":
 		w.WriteHeader(403)
+	case "slowAppId":
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("{}"))
 	default:
 		w.WriteHeader(404)
 	}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"metric"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+type logAccessCache struct {
+	authorize   LogAccessAuthorizer
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type logAccessDecision struct {
+	key     string
+	status  int
+	err     error
+	expires time.Time
+}
+
+// NewCachingLogAccessAuthorizer caches the decisions of the given authorizer
+// per token and app ID. Successful decisions are cached for ttl and
+// unauthorized, forbidden and not found decisions are cached for
+// negativeTTL. Any other result is not cached. Once maxEntries decisions are
+// cached the least recently used decision is evicted.
+func NewCachingLogAccessAuthorizer(
+	authorize LogAccessAuthorizer,
+	ttl time.Duration,
+	negativeTTL time.Duration,
+	maxEntries int,
+) LogAccessAuthorizer {
+	c := &logAccessCache{
+		authorize:   authorize,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+
+	return c.isAccessAllowed
+}
+
+func (c *logAccessCache) isAccessAllowed(authToken string, target string) (int, error) {
+	if authToken == "" {
+		return c.authorize(authToken, target)
+	}
+
+	key := cacheKey(authToken, target)
+	if d, ok := c.get(key); ok {
+		// metric-documentation-v1: (logAccessAuthorizer.cacheHits) Number of
+		// app log access decisions served from the cache
+		metrics.BatchIncrementCounter("logAccessAuthorizer.cacheHits")
+		return d.status, d.err
+	}
+	// metric-documentation-v1: (logAccessAuthorizer.cacheMisses) Number of
+	// app log access decisions that required a request to the Cloud Controller
+	metrics.BatchIncrementCounter("logAccessAuthorizer.cacheMisses")
+
+	start := time.Now()
+	status, err := c.authorize(authToken, target)
+	// metric-documentation-v1: (logAccessAuthorizer.latency) DEPRECATED
+	// Duration of the last app log access request to the Cloud Controller.
+	// Replaced by loggregator.trafficcontroller.log_access_request.
+	metrics.SendValue("logAccessAuthorizer.latency", float64(time.Since(start))/float64(time.Millisecond), "ms")
+	// metric-documentation-v2: (loggregator.trafficcontroller.log_access_request)
+	// Timer for each app log access request to the Cloud Controller
+	metric.RecordTimer("log_access_request", start, time.Now(), metric.WithVersion(2, 0))
+
+	switch status {
+	case http.StatusOK:
+		c.set(key, status, err, c.ttl)
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		c.set(key, status, err, c.negativeTTL)
+	}
+
+	return status, err
+}
+
+func (c *logAccessCache) get(key string) (*logAccessDecision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	d := e.Value.(*logAccessDecision)
+	if time.Now().After(d.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	return d, true
+}
+
+func (c *logAccessCache) set(key string, status int, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := &logAccessDecision{
+		key:     key,
+		status:  status,
+		err:     err,
+		expires: time.Now().Add(ttl),
+	}
+
+	if e, ok := c.entries[key]; ok {
+		e.Value = d
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(d)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*logAccessDecision).key)
+	}
+}
+
+func cacheKey(authToken, target string) string {
+	sum := sha256.Sum256([]byte(authToken))
+	return hex.EncodeToString(sum[:]) + ":" + target
+}
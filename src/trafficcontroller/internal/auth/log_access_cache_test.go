@@ -0,0 +1,112 @@
+package auth_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"trafficcontroller/internal/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachingLogAccessAuthorizer", func() {
+	var (
+		calls      int
+		status     int
+		err        error
+		authorizer auth.LogAccessAuthorizer
+	)
+
+	stubAuthorizer := func(string, string) (int, error) {
+		calls++
+		return status, err
+	}
+
+	BeforeEach(func() {
+		calls = 0
+		status = http.StatusOK
+		err = nil
+		authorizer = auth.NewCachingLogAccessAuthorizer(stubAuthorizer, time.Minute, time.Minute, 2)
+	})
+
+	It("caches successful decisions", func() {
+		Expect(authorizer("bearer token", "app-id")).To(Equal(http.StatusOK))
+		Expect(authorizer("bearer token", "app-id")).To(Equal(http.StatusOK))
+
+		Expect(calls).To(Equal(1))
+	})
+
+	It("caches decisions per token and app ID", func() {
+		authorizer("bearer token", "app-id")
+		authorizer("bearer other-token", "app-id")
+		authorizer("bearer token", "other-app-id")
+
+		Expect(calls).To(Equal(3))
+	})
+
+	It("caches negative decisions", func() {
+		status = http.StatusForbidden
+		err = errors.New(http.StatusText(http.StatusForbidden))
+
+		authorizer("bearer token", "app-id")
+		s, e := authorizer("bearer token", "app-id")
+
+		Expect(s).To(Equal(http.StatusForbidden))
+		Expect(e).To(MatchError(http.StatusText(http.StatusForbidden)))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("does not cache failed requests", func() {
+		status = http.StatusInternalServerError
+		err = errors.New("connection refused")
+
+		authorizer("bearer token", "app-id")
+		authorizer("bearer token", "app-id")
+
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not cache requests without a token", func() {
+		authorizer("", "app-id")
+		authorizer("", "app-id")
+
+		Expect(calls).To(Equal(2))
+	})
+
+	It("expires decisions after the TTL", func() {
+		authorizer = auth.NewCachingLogAccessAuthorizer(stubAuthorizer, 10*time.Millisecond, time.Minute, 2)
+
+		authorizer("bearer token", "app-id")
+		time.Sleep(20 * time.Millisecond)
+		authorizer("bearer token", "app-id")
+
+		Expect(calls).To(Equal(2))
+	})
+
+	It("expires negative decisions after the negative TTL", func() {
+		authorizer = auth.NewCachingLogAccessAuthorizer(stubAuthorizer, time.Minute, 10*time.Millisecond, 2)
+		status = http.StatusNotFound
+
+		authorizer("bearer token", "app-id")
+		time.Sleep(20 * time.Millisecond)
+		authorizer("bearer token", "app-id")
+
+		Expect(calls).To(Equal(2))
+	})
+
+	It("evicts the least recently used decision when full", func() {
+		for i := 0; i < 3; i++ {
+			authorizer("bearer token", fmt.Sprintf("app-%d", i))
+		}
+		Expect(calls).To(Equal(3))
+
+		authorizer("bearer token", "app-2")
+		Expect(calls).To(Equal(3))
+
+		authorizer("bearer token", "app-0")
+		Expect(calls).To(Equal(4))
+	})
+})
@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"net/http"
+	"trafficcontroller/internal/auth"
+)
+
+type mockResponseLogger struct {
+	LogResponseCalled chan bool
+	LogResponseInput  struct {
+		Req   chan *http.Request
+		Host  chan string
+		Port  chan uint32
+		Stats chan auth.ResponseStats
+	}
+	LogResponseOutput struct {
+		Ret0 chan error
+	}
+}
+
+func newMockResponseLogger() *mockResponseLogger {
+	m := &mockResponseLogger{}
+	m.LogResponseCalled = make(chan bool, 100)
+	m.LogResponseInput.Req = make(chan *http.Request, 100)
+	m.LogResponseInput.Host = make(chan string, 100)
+	m.LogResponseInput.Port = make(chan uint32, 100)
+	m.LogResponseInput.Stats = make(chan auth.ResponseStats, 100)
+	m.LogResponseOutput.Ret0 = make(chan error, 100)
+	return m
+}
+func (m *mockResponseLogger) LogResponse(req *http.Request, host string, port uint32, stats auth.ResponseStats) error {
+	m.LogResponseCalled <- true
+	m.LogResponseInput.Req <- req
+	m.LogResponseInput.Host <- host
+	m.LogResponseInput.Port <- port
+	m.LogResponseInput.Stats <- stats
+	return <-m.LogResponseOutput.Ret0
+}
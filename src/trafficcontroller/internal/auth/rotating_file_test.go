@@ -0,0 +1,99 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"trafficcontroller/internal/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RotatingFile", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "rotating-file")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "access.log")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	readFile := func(path string) string {
+		data, err := ioutil.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	It("appends to an existing file", func() {
+		Expect(ioutil.WriteFile(path, []byte("existing\n"), 0644)).To(Succeed())
+
+		f, err := auth.NewRotatingFile(path, 1024, 1)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+
+		_, err = f.Write([]byte("new\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readFile(path)).To(Equal("existing\nnew\n"))
+	})
+
+	It("rotates the file once it exceeds the max size", func() {
+		f, err := auth.NewRotatingFile(path, 10, 2)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+
+		for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			_, err = f.Write([]byte(line))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(readFile(path)).To(Equal("fourth\n"))
+		Expect(readFile(path + ".1")).To(Equal("third\n"))
+		Expect(readFile(path + ".2")).To(Equal("second\n"))
+		Expect(path + ".3").ToNot(BeAnExistingFile())
+	})
+
+	It("truncates the file when there are no backups", func() {
+		f, err := auth.NewRotatingFile(path, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+
+		for _, line := range []string{"first\n", "second\n"} {
+			_, err = f.Write([]byte(line))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(readFile(path)).To(Equal("second\n"))
+		Expect(path + ".1").ToNot(BeAnExistingFile())
+	})
+
+	It("recovers on a later write when a rotation fails", func() {
+		f, err := auth.NewRotatingFile(path, 10, 1)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+
+		_, err = f.Write([]byte("first\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755)).To(Succeed())
+		_, err = f.Write([]byte("second\n"))
+		Expect(err).To(HaveOccurred())
+
+		Expect(os.RemoveAll(path + ".1")).To(Succeed())
+		_, err = f.Write([]byte("third\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readFile(path)).To(Equal("third\n"))
+		Expect(readFile(path + ".1")).To(Equal("first\n"))
+	})
+})
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const minTokenKeysRefreshInterval = 30 * time.Second
+
+type UaaTokenKeys struct {
+	address         string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	lastRefresh time.Time
+}
+
+// NewUaaTokenKeys returns a cache of the public keys UAA uses to sign
+// tokens. Keys are fetched from the UAA token_keys endpoint every
+// refreshInterval once Start is called, and on demand when a token refers
+// to a key that is not in the cache. The client should have a timeout so
+// that an unresponsive UAA cannot block refreshes.
+func NewUaaTokenKeys(address string, refreshInterval time.Duration, client *http.Client) *UaaTokenKeys {
+	return &UaaTokenKeys{
+		address:         address,
+		refreshInterval: refreshInterval,
+		client:          client,
+		keys:            make(map[string]*rsa.PublicKey),
+	}
+}
+
+func (k *UaaTokenKeys) Start() {
+	if err := k.Refresh(); err != nil {
+		log.Printf("Failed to fetch UAA token keys: %s", err)
+	}
+
+	for range time.Tick(k.refreshInterval) {
+		if err := k.Refresh(); err != nil {
+			log.Printf("Failed to fetch UAA token keys: %s", err)
+		}
+	}
+}
+
+// Key returns the public key with the given key ID. If the key is not cached
+// the keys are refreshed from UAA, at most once every
+// minTokenKeysRefreshInterval.
+func (k *UaaTokenKeys) Key(kid string) (*rsa.PublicKey, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	lastRefresh := k.lastRefresh
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(lastRefresh) < minTokenKeysRefreshInterval {
+		return nil, fmt.Errorf("unknown token key: %s", kid)
+	}
+
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok = k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown token key: %s", kid)
+	}
+	return key, nil
+}
+
+func (k *UaaTokenKeys) Refresh() error {
+	k.mu.Lock()
+	k.lastRefresh = time.Now()
+	k.mu.Unlock()
+
+	keys, err := k.fetch()
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *UaaTokenKeys) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := k.client.Get(k.address + "/token_keys")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from UAA token_keys: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenKeys struct {
+		Keys []tokenKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &tokenKeys); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, tk := range tokenKeys.Keys {
+		key, err := tk.publicKey()
+		if err != nil {
+			log.Printf("Skipping UAA token key %s: %s", tk.Kid, err)
+			continue
+		}
+		keys[tk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable keys returned from UAA token_keys")
+	}
+	return keys, nil
+}
+
+type tokenKey struct {
+	Kid   string `json:"kid"`
+	Kty   string `json:"kty"`
+	N     string `json:"n"`
+	E     string `json:"e"`
+	Value string `json:"value"`
+}
+
+func (tk tokenKey) publicKey() (*rsa.PublicKey, error) {
+	if tk.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type: %s", tk.Kty)
+	}
+
+	if tk.N != "" && tk.E != "" {
+		n, err := base64.RawURLEncoding.DecodeString(tk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(tk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	}
+
+	block, _ := pem.Decode([]byte(tk.Value))
+	if block == nil {
+		return nil, errors.New("unable to decode PEM key value")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("key value is not an RSA public key")
+	}
+	return key, nil
+}
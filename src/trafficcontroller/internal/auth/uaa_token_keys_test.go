@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"trafficcontroller/internal/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UaaTokenKeys", func() {
+	var (
+		server   *httptest.Server
+		client   *http.Client
+		requests int64
+		keysJSON atomic.Value
+	)
+
+	BeforeEach(func() {
+		client = &http.Client{
+			Timeout: time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		}
+
+		requests = 0
+		keysJSON.Store(tokenKeysJSON("key-1", &newTokenKey().PublicKey))
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/token_keys" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			atomic.AddInt64(&requests, 1)
+			w.Write(keysJSON.Load().([]byte))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches keys from UAA on demand", func() {
+		keys := auth.NewUaaTokenKeys(server.URL, time.Hour, client)
+
+		key, err := keys.Key("key-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key).ToNot(BeNil())
+		Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(1))
+	})
+
+	It("caches keys", func() {
+		keys := auth.NewUaaTokenKeys(server.URL, time.Hour, client)
+
+		_, err := keys.Key("key-1")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = keys.Key("key-1")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(1))
+	})
+
+	It("does not refetch for unknown keys more than once per interval", func() {
+		keys := auth.NewUaaTokenKeys(server.URL, time.Hour, client)
+
+		_, err := keys.Key("unknown-key")
+		Expect(err).To(HaveOccurred())
+		_, err = keys.Key("unknown-key")
+		Expect(err).To(HaveOccurred())
+
+		Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(1))
+	})
+
+	It("picks up rotated keys on refresh", func() {
+		keys := auth.NewUaaTokenKeys(server.URL, time.Hour, client)
+		Expect(keys.Refresh()).To(Succeed())
+
+		keysJSON.Store(tokenKeysJSON("key-2", &newTokenKey().PublicKey))
+		Expect(keys.Refresh()).To(Succeed())
+
+		_, err := keys.Key("key-2")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = keys.Key("key-1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps the existing keys when UAA is unavailable", func() {
+		keys := auth.NewUaaTokenKeys(server.URL, time.Hour, client)
+		Expect(keys.Refresh()).To(Succeed())
+
+		server.Close()
+		Expect(keys.Refresh()).ToNot(Succeed())
+
+		_, err := keys.Key("key-1")
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
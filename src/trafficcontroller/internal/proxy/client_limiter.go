@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// ClientLimits are the per client quotas enforced by the DopplerProxy. A zero
+// value for any limit disables it.
+type ClientLimits struct {
+	MaxStreams        int
+	MaxFirehoses      int
+	MaxBytesPerSecond int64
+}
+
+type connectionType int
+
+const (
+	streamConnection connectionType = iota
+	firehoseConnection
+)
+
+// maxTaggedClients is the number of clients whose ID is used as the value
+// of the client_id metric tag. Metrics of any other client are tagged
+// otherClients so that the number of series is bounded.
+const (
+	maxTaggedClients = 100
+	otherClients     = "other"
+)
+
+type clientLimiter struct {
+	limits ClientLimits
+
+	mu      sync.Mutex
+	clients map[string]*clientUsage
+	tagged  map[string]struct{}
+}
+
+// clientUsage tracks the connections and bandwidth of a client. The
+// connection counts are guarded by the clientLimiter's mutex and the
+// bandwidth by the clientUsage's own mutex so that charging bytes for one
+// client does not contend with any other client.
+type clientUsage struct {
+	streams   int
+	firehoses int
+
+	mu       sync.Mutex
+	max      float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newClientLimiter(limits ClientLimits) *clientLimiter {
+	return &clientLimiter{
+		limits:  limits,
+		clients: make(map[string]*clientUsage),
+		tagged:  make(map[string]struct{}),
+	}
+}
+
+// acquire reserves a connection of the given type for the client. If the
+// client is at its limit it returns false, otherwise the returned function
+// must be called once the connection is closed.
+func (l *clientLimiter) acquire(clientID string, t connectionType) (func(), bool) {
+	if clientID == "" {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usage(clientID)
+	switch t {
+	case streamConnection:
+		if l.limits.MaxStreams > 0 && u.streams >= l.limits.MaxStreams {
+			return nil, false
+		}
+		u.streams++
+	case firehoseConnection:
+		if l.limits.MaxFirehoses > 0 && u.firehoses >= l.limits.MaxFirehoses {
+			return nil, false
+		}
+		u.firehoses++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(clientID, t)
+		})
+	}, true
+}
+
+func (l *clientLimiter) release(clientID string, t connectionType) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := l.usage(clientID)
+	switch t {
+	case streamConnection:
+		u.streams--
+	case firehoseConnection:
+		u.firehoses--
+	}
+}
+
+// bandwidth returns the bandwidth quota of a client for charging every
+// message of a connection without looking the client up again. It must
+// only be called while the client holds a connection from acquire as
+// clients without connections are pruned. It returns nil if the client's
+// bandwidth is not limited.
+func (l *clientLimiter) bandwidth(clientID string) *clientUsage {
+	if clientID == "" || l.limits.MaxBytesPerSecond <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.usage(clientID)
+}
+
+// allowBytes charges n bytes against the client's bandwidth quota. It
+// returns false if the client has exhausted its quota for the current
+// second.
+func (l *clientLimiter) allowBytes(clientID string, n int) bool {
+	if clientID == "" || l.limits.MaxBytesPerSecond <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.usage(clientID).allowBytes(n)
+}
+
+// metricTag returns the value of the client_id tag for metrics about the
+// client.
+func (l *clientLimiter) metricTag(clientID string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.tagged[clientID]; ok {
+		return clientID
+	}
+	if len(l.tagged) >= maxTaggedClients {
+		return otherClients
+	}
+	l.tagged[clientID] = struct{}{}
+	return clientID
+}
+
+// prune removes clients without open connections that have a full
+// bandwidth quota.
+func (l *clientLimiter) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, u := range l.clients {
+		if u.streams == 0 && u.firehoses == 0 && u.full() {
+			delete(l.clients, id)
+		}
+	}
+}
+
+func (l *clientLimiter) usage(clientID string) *clientUsage {
+	u, ok := l.clients[clientID]
+	if !ok {
+		u = &clientUsage{
+			max:      float64(l.limits.MaxBytesPerSecond),
+			tokens:   float64(l.limits.MaxBytesPerSecond),
+			lastFill: time.Now(),
+		}
+		l.clients[clientID] = u
+	}
+	return u
+}
+
+// allowBytes charges n bytes against the quota. It returns false if the
+// quota is exhausted for the current second. A client with remaining quota
+// may go into debt so that messages larger than the quota are still
+// delivered. A nil clientUsage allows any number of bytes.
+func (u *clientUsage) allowBytes(n int) bool {
+	if u == nil {
+		return true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.refill()
+	if u.tokens <= 0 {
+		return false
+	}
+	u.tokens -= float64(n)
+	return true
+}
+
+func (u *clientUsage) full() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.refill()
+	return u.tokens >= u.max
+}
+
+// refill must be called with u.mu held.
+func (u *clientUsage) refill() {
+	now := time.Now()
+	u.tokens += now.Sub(u.lastFill).Seconds() * u.max
+	if u.tokens > u.max {
+		u.tokens = u.max
+	}
+	u.lastFill = now
+}
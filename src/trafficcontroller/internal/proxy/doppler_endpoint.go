@@ -53,7 +53,7 @@ func HttpHandlerProvider(messages <-chan []byte) http.Handler {
 }
 
 func WebsocketHandlerProvider(messages <-chan []byte) http.Handler {
-	return NewWebsocketHandler(messages, WebsocketKeepAliveDuration)
+	return NewWebsocketHandler(messages, WebsocketKeepAliveDuration, nil)
 }
 
 func ContainerMetricHandlerProvider(messages <-chan []byte) http.Handler {
@@ -1,13 +1,16 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"metric"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"plumbing"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"trafficcontroller/internal/auth"
 
@@ -35,6 +38,10 @@ type DopplerProxy struct {
 	numFirehoses   int64
 	numAppStreams  int64
 	timeout        time.Duration
+	limiter        *clientLimiter
+	inFlight       int64
+	draining       chan struct{}
+	drainOnce      sync.Once
 }
 
 // TODO export this
@@ -50,6 +57,7 @@ func NewDopplerProxy(
 	grpcConn grpcConnector,
 	cookieDomain string,
 	timeout time.Duration,
+	limits ClientLimits,
 ) *DopplerProxy {
 	p := &DopplerProxy{
 		logAuthorize:   logAuthorize,
@@ -57,6 +65,8 @@ func NewDopplerProxy(
 		grpcConn:       grpcConn,
 		cookieDomain:   cookieDomain,
 		timeout:        timeout,
+		limiter:        newClientLimiter(limits),
+		draining:       make(chan struct{}),
 	}
 	r := mux.NewRouter()
 	p.Router = *r
@@ -71,12 +81,71 @@ func NewDopplerProxy(
 	return p
 }
 
+func (p *DopplerProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-p.draining:
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	atomic.AddInt64(&p.inFlight, 1)
+	defer atomic.AddInt64(&p.inFlight, -1)
+
+	p.Router.ServeHTTP(w, r)
+}
+
+// Drain rejects new requests and closes open websocket streams with a going
+// away close frame. It waits up to timeout for in-flight requests to
+// complete and reports whether they did.
+func (p *DopplerProxy) Drain(timeout time.Duration) bool {
+	p.drainOnce.Do(func() {
+		close(p.draining)
+	})
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&p.inFlight) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Ready returns an error once the proxy has started draining.
+func (p *DopplerProxy) Ready() error {
+	select {
+	case <-p.draining:
+		return errors.New("traffic controller is draining")
+	default:
+		return nil
+	}
+}
+
 func (p *DopplerProxy) emitMetrics() {
 	for range time.Tick(metricsInterval) {
-		// metric-documentation-v1: (dopplerProxy.firehoses) Number of open firehose streams
+		// metric-documentation-v1: (dopplerProxy.firehoses) DEPRECATED Number of
+		// open firehose streams. Replaced by
+		// loggregator.trafficcontroller.streams with type:firehose.
 		metrics.SendValue("dopplerProxy.firehoses", float64(atomic.LoadInt64(&p.numFirehoses)), "connections")
-		// metric-documentation-v1: (dopplerProxy.appStreams) Number of open app streams
+		// metric-documentation-v1: (dopplerProxy.appStreams) DEPRECATED Number of
+		// open app streams. Replaced by loggregator.trafficcontroller.streams
+		// with type:app.
 		metrics.SendValue("dopplerProxy.appStreams", float64(atomic.LoadInt64(&p.numAppStreams)), "connections")
+		// metric-documentation-v2: (loggregator.trafficcontroller.streams) Number of
+		// open streams, tagged by type (firehose or app)
+		metric.SetGauge("streams", float64(atomic.LoadInt64(&p.numFirehoses)), "connections",
+			metric.WithVersion(2, 0),
+			metric.WithTag("type", "firehose"),
+		)
+		metric.SetGauge("streams", float64(atomic.LoadInt64(&p.numAppStreams)), "connections",
+			metric.WithVersion(2, 0),
+			metric.WithTag("type", "app"),
+		)
+
+		p.limiter.prune()
 	}
 }
 
@@ -96,15 +165,21 @@ func (p *DopplerProxy) stream(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *DopplerProxy) recentlogs(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	p.serveAppLogs("recentlogs", mux.Vars(r)["appID"], w, r)
-	// metric-documentation-v1: (dopplerProxy.recentlogsLatency) USELESS metric which measures nothing of value
-	sendLatencyMetric("recentlogs", time.Now())
+	// metric-documentation-v1: (dopplerProxy.recentlogsLatency) DEPRECATED
+	// Duration of the last recent logs request. Replaced by
+	// loggregator.trafficcontroller.request with endpoint:recentlogs.
+	sendLatencyMetric("recentlogs", start)
 }
 
 func (p *DopplerProxy) containermetrics(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	p.serveAppLogs("containermetrics", mux.Vars(r)["appID"], w, r)
-	// metric-documentation-v1: (dopplerProxy.containermetricsLatency) USELESS metric which measures nothing of value
-	sendLatencyMetric("containermetrics", time.Now())
+	// metric-documentation-v1: (dopplerProxy.containermetricsLatency) DEPRECATED
+	// Duration of the last container metrics request. Replaced by
+	// loggregator.trafficcontroller.request with endpoint:containermetrics.
+	sendLatencyMetric("containermetrics", start)
 }
 
 func (p *DopplerProxy) setcookie(w http.ResponseWriter, r *http.Request) {
@@ -122,11 +197,30 @@ func (p *DopplerProxy) serveFirehose(firehoseSubscriptionId string, writer http.
 		return
 	}
 
+	var sampleRate uint64
+	if param := request.URL.Query().Get("sample_rate"); param != "" {
+		sampleRate, err = strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			http.Error(writer, "sample_rate must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	clientID := auth.ClientID(authToken)
+	auth.SetVerifiedClientID(request, clientID)
+	release, ok := p.limiter.acquire(clientID, firehoseConnection)
+	if !ok {
+		p.rejectOverQuota(writer, clientID, "firehose", "Too many concurrent firehose subscriptions")
+		return
+	}
+	defer release()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	client, err := p.grpcConn.Subscribe(ctx, &plumbing.SubscriptionRequest{
-		ShardID: firehoseSubscriptionId,
+		ShardID:    firehoseSubscriptionId,
+		SampleRate: uint32(sampleRate),
 	})
 	if err != nil {
 		writer.WriteHeader(http.StatusServiceUnavailable)
@@ -134,7 +228,7 @@ func (p *DopplerProxy) serveFirehose(firehoseSubscriptionId string, writer http.
 		return
 	}
 
-	p.serveWS(FIREHOSE_ID, firehoseSubscriptionId, writer, request, client)
+	p.serveWS(clientID, writer, request, client)
 }
 
 // "^/apps/(.*)/(recentlogs|stream|containermetrics)$"
@@ -158,6 +252,9 @@ func (p *DopplerProxy) serveAppLogs(requestPath, appID string, writer http.Respo
 		return
 	}
 
+	clientID := auth.ClientID(authToken)
+	auth.SetVerifiedClientID(request, clientID)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -174,6 +271,10 @@ func (p *DopplerProxy) serveAppLogs(requestPath, appID string, writer http.Respo
 		if ok && len(resp) > limit {
 			resp = resp[:limit]
 		}
+		if !p.limiter.allowBytes(clientID, totalBytes(resp)) {
+			p.rejectOverQuota(writer, clientID, requestPath, "Bandwidth quota exceeded")
+			return
+		}
 		p.serveMultiPartResponse(writer, resp)
 		return
 	case "containermetrics":
@@ -184,9 +285,20 @@ func (p *DopplerProxy) serveAppLogs(requestPath, appID string, writer http.Respo
 			log.Printf("containermetrics request encountered an error: %s", err)
 			return
 		}
+		if !p.limiter.allowBytes(clientID, totalBytes(resp)) {
+			p.rejectOverQuota(writer, clientID, requestPath, "Bandwidth quota exceeded")
+			return
+		}
 		p.serveMultiPartResponse(writer, resp)
 		return
 	case "stream":
+		release, ok := p.limiter.acquire(clientID, streamConnection)
+		if !ok {
+			p.rejectOverQuota(writer, clientID, requestPath, "Too many concurrent app streams")
+			return
+		}
+		defer release()
+
 		client, err := p.grpcConn.Subscribe(ctx, &plumbing.SubscriptionRequest{
 			Filter: &plumbing.Filter{
 				AppID: appID,
@@ -197,7 +309,7 @@ func (p *DopplerProxy) serveAppLogs(requestPath, appID string, writer http.Respo
 			return
 		}
 
-		p.serveWS(requestPath, appID, writer, request, client)
+		p.serveWS(clientID, writer, request, client)
 		return
 	}
 }
@@ -215,10 +327,11 @@ func limitFrom(req *http.Request) (int, bool) {
 	return value, true
 }
 
-func (p *DopplerProxy) serveWS(endpointType, streamID string, w http.ResponseWriter, r *http.Request, recv func() ([]byte, error)) {
-	dopplerEndpoint := NewDopplerEndpoint(endpointType, streamID, false)
+func (p *DopplerProxy) serveWS(clientID string, w http.ResponseWriter, r *http.Request, recv func() ([]byte, error)) {
 	data := make(chan []byte)
-	handler := dopplerEndpoint.HProvider(data)
+	handler := NewWebsocketHandler(data, WebsocketKeepAliveDuration, p.draining)
+	bandwidth := p.limiter.bandwidth(clientID)
+	var clientTag string
 
 	go func() {
 		defer close(data)
@@ -235,6 +348,19 @@ func (p *DopplerProxy) serveWS(endpointType, streamID string, w http.ResponseWri
 				continue
 			}
 
+			if !bandwidth.allowBytes(len(resp)) {
+				if clientTag == "" {
+					clientTag = p.limiter.metricTag(clientID)
+				}
+				// metric-documentation-v1: (dopplerProxy.quotaDroppedEnvelopes) Number of
+				// envelopes dropped because a client exceeded its bandwidth quota, tagged
+				// by client_id (other once 100 clients have been tagged)
+				metrics.BatchCounter("dopplerProxy.quotaDroppedEnvelopes").
+					SetTag("client_id", clientTag).
+					Increment()
+				continue
+			}
+
 			timer.Reset(5 * time.Second)
 			select {
 			case data <- resp:
@@ -242,9 +368,14 @@ func (p *DopplerProxy) serveWS(endpointType, streamID string, w http.ResponseWri
 					<-timer.C
 				}
 			case <-timer.C:
-				// metric-documentation-v1: (dopplerProxy.slowConsumer) A slow consumer of the
-				// websocket stream
+				// metric-documentation-v1: (dopplerProxy.slowConsumer) DEPRECATED A
+				// slow consumer of the websocket stream. Replaced by
+				// loggregator.trafficcontroller.slow_consumers.
 				metrics.SendValue("dopplerProxy.slowConsumer", 1, "consumer")
+				// metric-documentation-v2: (loggregator.trafficcontroller.slow_consumers)
+				// Number of websocket streams closed because the consumer did not
+				// read for 5 seconds
+				metric.IncCounter("slow_consumers", metric.WithVersion(2, 0))
 				log.Print("Doppler Proxy: Slow Consumer")
 				return
 			}
@@ -270,10 +401,38 @@ func (p *DopplerProxy) serveMultiPartResponse(rw http.ResponseWriter, messages [
 	}
 }
 
+func (p *DopplerProxy) rejectOverQuota(writer http.ResponseWriter, clientID, endpoint, reason string) {
+	// metric-documentation-v1: (dopplerProxy.quotaRejectedRequests) Number of
+	// requests rejected because a client exceeded one of its quotas, tagged by
+	// client_id (other once 100 clients have been tagged)
+	metrics.BatchCounter("dopplerProxy.quotaRejectedRequests").
+		SetTag("client_id", p.limiter.metricTag(clientID)).
+		SetTag("endpoint", endpoint).
+		Increment()
+
+	writer.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprintf(writer, "%s for client %s", reason, clientID)
+}
+
+func totalBytes(messages [][]byte) int {
+	var n int
+	for _, m := range messages {
+		n += len(m)
+	}
+	return n
+}
+
 func sendLatencyMetric(metricName string, startTime time.Time) {
-	elapsedMillisecond := float64(time.Since(startTime)) / float64(time.Millisecond)
+	stop := time.Now()
+	elapsedMillisecond := float64(stop.Sub(startTime)) / float64(time.Millisecond)
 	// metric-documentation-v1: see callers of sendLatencyMetric
 	metrics.SendValue(fmt.Sprintf("dopplerProxy.%sLatency", metricName), elapsedMillisecond, "ms")
+	// metric-documentation-v2: (loggregator.trafficcontroller.request) Timer
+	// for each recent logs and container metrics request, tagged by endpoint
+	metric.RecordTimer("request", startTime, stop,
+		metric.WithVersion(2, 0),
+		metric.WithTag("endpoint", metricName),
+	)
 }
 
 func getAuthToken(req *http.Request) string {
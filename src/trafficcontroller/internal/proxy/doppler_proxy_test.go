@@ -63,6 +63,7 @@ var _ = Describe("ServeHTTP()", func() {
 			mockGrpcConnector,
 			"cookieDomain",
 			50*time.Millisecond,
+			proxy.ClientLimits{},
 		)
 
 		recorder = httptest.NewRecorder()
@@ -393,6 +394,29 @@ var _ = Describe("ServeHTTP()", func() {
 				Eventually(mockGrpcConnector.SubscribeInput.Req).Should(BeCalled(With(expectedRequest)))
 			})
 
+			It("passes the sample rate to doppler", func() {
+				req, _ := http.NewRequest("GET", "/firehose/abc-123?sample_rate=10", nil)
+				req.Header.Add("Authorization", "token")
+
+				dopplerProxy.ServeHTTP(recorder, req)
+
+				expectedRequest := &plumbing.SubscriptionRequest{
+					ShardID:    "abc-123",
+					SampleRate: 10,
+				}
+				Eventually(mockGrpcConnector.SubscribeInput.Req).Should(BeCalled(With(expectedRequest)))
+			})
+
+			It("returns a bad request for an invalid sample rate", func() {
+				req, _ := http.NewRequest("GET", "/firehose/abc-123?sample_rate=-1", nil)
+				req.Header.Add("Authorization", "token")
+
+				dopplerProxy.ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Consistently(mockGrpcConnector.SubscribeCalled).ShouldNot(Receive())
+			})
+
 			It("returns an unauthorized status and sets the WWW-Authenticate header if authorization fails", func() {
 				adminAuth.Result = AuthorizerResult{Status: http.StatusUnauthorized, ErrorMessage: "Error: Invalid authorization"}
 
@@ -588,6 +612,211 @@ var _ = Describe("ServeHTTP()", func() {
 	})
 })
 
+var _ = Describe("Client limits", func() {
+	var (
+		dopplerProxy *proxy.DopplerProxy
+		server       *httptest.Server
+
+		mockGrpcConnector       *mockGrpcConnector
+		mockDopplerStreamClient *mockReceiver
+	)
+
+	var wsEndpoint = func(path string) string {
+		return strings.Replace(server.URL, "http", "ws", 1) + path
+	}
+
+	var dial = func(path, token string) (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial(
+			wsEndpoint(path),
+			http.Header{"Authorization": []string{token}},
+		)
+	}
+
+	var startProxy = func(limits proxy.ClientLimits) {
+		auth := LogAuthorizer{Result: AuthorizerResult{Status: http.StatusOK}}
+		adminAuth := AdminAuthorizer{Result: AuthorizerResult{Status: http.StatusOK}}
+
+		dopplerProxy = proxy.NewDopplerProxy(
+			auth.Authorize,
+			adminAuth.Authorize,
+			mockGrpcConnector,
+			"cookieDomain",
+			50*time.Millisecond,
+			limits,
+		)
+		server = httptest.NewServer(dopplerProxy)
+	}
+
+	BeforeEach(func() {
+		mockGrpcConnector = newMockGrpcConnector()
+		mockDopplerStreamClient = newMockReceiver()
+
+		for i := 0; i < 3; i++ {
+			mockGrpcConnector.SubscribeOutput.Ret0 <- mockDopplerStreamClient.Recv
+			mockGrpcConnector.SubscribeOutput.Ret1 <- nil
+		}
+		close(mockDopplerStreamClient.RecvOutput.Ret0)
+		close(mockDopplerStreamClient.RecvOutput.Ret1)
+	})
+
+	AfterEach(func() {
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	It("rejects firehose subscriptions over the limit with a 429", func() {
+		startProxy(proxy.ClientLimits{MaxFirehoses: 1})
+
+		conn, _, err := dial("/firehose/subscription-id", "token")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Eventually(mockGrpcConnector.SubscribeCalled).Should(Receive())
+
+		_, resp, err := dial("/firehose/subscription-id", "token")
+		Expect(err).To(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Consistently(mockGrpcConnector.SubscribeCalled).ShouldNot(Receive())
+	})
+
+	It("rejects app streams over the limit with a 429", func() {
+		startProxy(proxy.ClientLimits{MaxStreams: 1})
+
+		conn, _, err := dial("/apps/abc123/stream", "token")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Eventually(mockGrpcConnector.SubscribeCalled).Should(Receive())
+
+		_, resp, err := dial("/apps/abc123/stream", "token")
+		Expect(err).To(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("applies limits to each client separately", func() {
+		startProxy(proxy.ClientLimits{MaxFirehoses: 1})
+
+		conn, _, err := dial("/firehose/subscription-id", "token")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		otherConn, _, err := dial("/firehose/subscription-id", "other-token")
+		Expect(err).ToNot(HaveOccurred())
+		defer otherConn.Close()
+	})
+
+	It("rejects recent logs requests when the bandwidth quota is exhausted", func() {
+		startProxy(proxy.ClientLimits{MaxBytesPerSecond: 1})
+		mockGrpcConnector.RecentLogsOutput.Ret0 <- [][]byte{[]byte("log1")}
+		mockGrpcConnector.RecentLogsOutput.Ret0 <- [][]byte{[]byte("log2")}
+
+		req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs", nil)
+		req.Header.Add("Authorization", "token")
+
+		recorder := httptest.NewRecorder()
+		dopplerProxy.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder = httptest.NewRecorder()
+		dopplerProxy.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+	})
+})
+
+var _ = Describe("Draining", func() {
+	var (
+		dopplerProxy *proxy.DopplerProxy
+		server       *httptest.Server
+
+		mockGrpcConnector       *mockGrpcConnector
+		mockDopplerStreamClient *mockReceiver
+	)
+
+	BeforeEach(func() {
+		mockGrpcConnector = newMockGrpcConnector()
+		mockDopplerStreamClient = newMockReceiver()
+		mockGrpcConnector.SubscribeOutput.Ret0 <- mockDopplerStreamClient.Recv
+		mockGrpcConnector.SubscribeOutput.Ret1 <- nil
+
+		auth := LogAuthorizer{Result: AuthorizerResult{Status: http.StatusOK}}
+		adminAuth := AdminAuthorizer{Result: AuthorizerResult{Status: http.StatusOK}}
+
+		dopplerProxy = proxy.NewDopplerProxy(
+			auth.Authorize,
+			adminAuth.Authorize,
+			mockGrpcConnector,
+			"cookieDomain",
+			50*time.Millisecond,
+			proxy.ClientLimits{},
+		)
+		server = httptest.NewServer(dopplerProxy)
+	})
+
+	AfterEach(func() {
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	It("closes open streams with a going away close frame", func() {
+		conn, _, err := websocket.DefaultDialer.Dial(
+			strings.Replace(server.URL, "http", "ws", 1)+"/apps/abc123/stream",
+			http.Header{"Authorization": []string{"token"}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Eventually(mockGrpcConnector.SubscribeCalled).Should(Receive())
+
+		for i := 0; i < 5; i++ {
+			mockDopplerStreamClient.RecvOutput.Ret0 <- []byte(fmt.Sprintf("message %d", i))
+			mockDopplerStreamClient.RecvOutput.Ret1 <- nil
+		}
+
+		drained := make(chan bool, 1)
+		go func() {
+			drained <- dopplerProxy.Drain(time.Second)
+		}()
+
+		for i := 0; i < 5; i++ {
+			_, data, err := conn.ReadMessage()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(fmt.Sprintf("message %d", i)))
+		}
+
+		_, _, err = conn.ReadMessage()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("websocket: close 1001"))
+		Eventually(drained).Should(Receive(BeTrue()))
+	})
+
+	It("rejects new requests with a 503", func() {
+		Expect(dopplerProxy.Drain(time.Second)).To(BeTrue())
+
+		req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs", nil)
+		req.Header.Add("Authorization", "token")
+		recorder := httptest.NewRecorder()
+		dopplerProxy.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(mockGrpcConnector.RecentLogsCalled).ToNot(Receive())
+	})
+
+	It("reports when in-flight requests do not complete before the timeout", func() {
+		req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs", nil)
+		req.Header.Add("Authorization", "token")
+		go dopplerProxy.ServeHTTP(httptest.NewRecorder(), req)
+		Eventually(mockGrpcConnector.RecentLogsCalled).Should(Receive())
+
+		Expect(dopplerProxy.Drain(10 * time.Millisecond)).To(BeFalse())
+		mockGrpcConnector.RecentLogsOutput.Ret0 <- nil
+	})
+
+	It("reports that it is not ready once draining", func() {
+		Expect(dopplerProxy.Ready()).To(Succeed())
+
+		dopplerProxy.Drain(time.Second)
+
+		Expect(dopplerProxy.Ready()).To(HaveOccurred())
+	})
+})
+
 var _ = Describe("DefaultHandlerProvider", func() {
 	It("returns an HTTP handler for .../recentlogs", func() {
 		httpHandler := proxy.NewHttpHandler(make(chan []byte))
@@ -598,7 +827,7 @@ var _ = Describe("DefaultHandlerProvider", func() {
 	})
 
 	It("returns a Websocket handler for .../stream", func() {
-		wsHandler := proxy.NewWebsocketHandler(make(chan []byte), time.Minute)
+		wsHandler := proxy.NewWebsocketHandler(make(chan []byte), time.Minute, nil)
 
 		target := proxy.WebsocketHandlerProvider(make(chan []byte))
 
@@ -606,7 +835,7 @@ var _ = Describe("DefaultHandlerProvider", func() {
 	})
 
 	It("returns a Websocket handler for anything else", func() {
-		wsHandler := proxy.NewWebsocketHandler(make(chan []byte), time.Minute)
+		wsHandler := proxy.NewWebsocketHandler(make(chan []byte), time.Minute, nil)
 
 		target := proxy.WebsocketHandlerProvider(make(chan []byte))
 
@@ -8,13 +8,25 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// When done is closed, messages already on their way are still written
+// until none arrive for drainIdle or drainMax has passed.
+const (
+	drainIdle = 100 * time.Millisecond
+	drainMax  = time.Second
+)
+
 type websocketHandler struct {
 	messages  <-chan []byte
 	keepAlive time.Duration
+	done      <-chan struct{}
 }
 
-func NewWebsocketHandler(m <-chan []byte, keepAlive time.Duration) *websocketHandler {
-	return &websocketHandler{messages: m, keepAlive: keepAlive}
+// NewWebsocketHandler returns a handler that writes messages to a websocket
+// until the messages channel is closed. If done is closed the pending
+// messages are written and the websocket is closed with a going away close
+// frame so the client reconnects elsewhere.
+func NewWebsocketHandler(m <-chan []byte, keepAlive time.Duration, done <-chan struct{}) *websocketHandler {
+	return &websocketHandler{messages: m, keepAlive: keepAlive, done: done}
 }
 
 func (h *websocketHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -63,6 +75,13 @@ func (h *websocketHandler) runWebsocketUntilClosed(ws *websocket.Conn) (closeCod
 			closeCode = websocket.ClosePolicyViolation
 			closeMessage = "Client did not respond to ping before keep-alive timeout expired."
 			return
+		case <-h.done:
+			if !h.flush(ws) {
+				return
+			}
+			closeCode = websocket.CloseGoingAway
+			closeMessage = "Traffic controller is shutting down."
+			return
 		case message, ok := <-h.messages:
 			if !ok {
 				return
@@ -74,3 +93,26 @@ func (h *websocketHandler) runWebsocketUntilClosed(ws *websocket.Conn) (closeCod
 		}
 	}
 }
+
+// flush writes the messages that arrive until none arrive for drainIdle or
+// drainMax has passed. It returns false if the messages channel was closed
+// or a write failed.
+func (h *websocketHandler) flush(ws *websocket.Conn) bool {
+	timeout := time.After(drainMax)
+	for {
+		select {
+		case <-timeout:
+			return true
+		case <-time.After(drainIdle):
+			return true
+		case message, ok := <-h.messages:
+			if !ok {
+				return false
+			}
+			err := ws.WriteMessage(websocket.BinaryMessage, message)
+			if err != nil {
+				return false
+			}
+		}
+	}
+}
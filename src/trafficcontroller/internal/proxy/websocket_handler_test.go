@@ -1,6 +1,7 @@
 package proxy_test
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"time"
@@ -19,11 +20,13 @@ var _ = Describe("WebsocketHandler", func() {
 	var messagesChan chan []byte
 	var testServer *httptest.Server
 	var handlerDone chan struct{}
+	var done chan struct{}
 
 	BeforeEach(func() {
 		fakeResponseWriter = httptest.NewRecorder()
 		messagesChan = make(chan []byte, 10)
-		handler = proxy.NewWebsocketHandler(messagesChan, 100*time.Millisecond)
+		done = make(chan struct{})
+		handler = proxy.NewWebsocketHandler(messagesChan, 100*time.Millisecond, done)
 		handlerDone = make(chan struct{})
 		testServer = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 			handler.ServeHTTP(rw, r)
@@ -60,6 +63,38 @@ var _ = Describe("WebsocketHandler", func() {
 		Eventually(handlerDone).Should(BeClosed())
 	})
 
+	It("closes the websocket with a going away frame when done is closed", func() {
+		ws, _, err := websocket.DefaultDialer.Dial(httpToWs(testServer.URL), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		close(done)
+
+		_, _, err = ws.ReadMessage()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("websocket: close 1001"))
+		Eventually(handlerDone).Should(BeClosed())
+	})
+
+	It("writes pending messages before closing when done is closed", func() {
+		ws, _, err := websocket.DefaultDialer.Dial(httpToWs(testServer.URL), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 5; i++ {
+			messagesChan <- []byte(fmt.Sprintf("message %d", i))
+		}
+		close(done)
+
+		for i := 0; i < 5; i++ {
+			_, msg, err := ws.ReadMessage()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(msg)).To(Equal(fmt.Sprintf("message %d", i)))
+		}
+		_, _, err = ws.ReadMessage()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("websocket: close 1001"))
+		Eventually(handlerDone).Should(BeClosed())
+	})
+
 	It("should err when websocket upgrade fails", func() {
 		resp, err := http.Get(testServer.URL)
 		Expect(err).NotTo(HaveOccurred())